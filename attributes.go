@@ -0,0 +1,152 @@
+package gosoup
+
+import (
+	"slices"
+	"strings"
+)
+
+// The functions below modify the attributes of a node. The ones without NS suffix
+// operate on attributes without namespace, which are all the attributes of HTML
+// elements. The namespaced variants are useful for foreign elements, such as the
+// xlink:href attribute of SVG elements.
+
+// attrIndex returns the index of the attribute with the given namespace and key
+// in this node's Attrs, or -1 if there is no such attribute.
+func (node *Node) attrIndex(namespace, key string) int {
+	for i, a := range node.Attrs {
+		if a.Namespace == namespace && a.Key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// SetAttr sets the value of the given attribute, adding the attribute if this node
+// does not have it yet.
+func (node *Node) SetAttr(key, val string) {
+	node.SetAttrNS("", key, val)
+}
+
+// SetAttrNS sets the value of the attribute with the given namespace and key,
+// adding the attribute if this node does not have it yet.
+func (node *Node) SetAttrNS(namespace, key, val string) {
+	if i := node.attrIndex(namespace, key); i >= 0 {
+		node.Attrs[i].Val = val
+		return
+	}
+	node.Attrs = append(node.Attrs, Attribute{Namespace: namespace, Key: key, Val: val})
+}
+
+// RemoveAttr removes the given attribute from this node. It returns true if the
+// node had the attribute.
+func (node *Node) RemoveAttr(key string) bool {
+	return node.RemoveAttrNS("", key)
+}
+
+// RemoveAttrNS removes the attribute with the given namespace and key from this
+// node. It returns true if the node had the attribute.
+func (node *Node) RemoveAttrNS(namespace, key string) bool {
+	i := node.attrIndex(namespace, key)
+	if i < 0 {
+		return false
+	}
+	node.Attrs = append(node.Attrs[:i], node.Attrs[i+1:]...)
+	return true
+}
+
+// RenameAttr changes the key of the given attribute, keeping its value and its
+// position among the attributes. If the node already has an attribute with the
+// new key, that attribute is replaced. It returns true if the node had the
+// attribute to rename.
+func (node *Node) RenameAttr(oldKey, newKey string) bool {
+	return node.RenameAttrNS("", oldKey, newKey)
+}
+
+// RenameAttrNS is like RenameAttr, for the attributes with the given namespace.
+func (node *Node) RenameAttrNS(namespace, oldKey, newKey string) bool {
+	i := node.attrIndex(namespace, oldKey)
+	if i < 0 {
+		return false
+	}
+	if oldKey == newKey {
+		return true
+	}
+	if j := node.attrIndex(namespace, newKey); j >= 0 {
+		node.Attrs = append(node.Attrs[:j], node.Attrs[j+1:]...)
+		if j < i {
+			i--
+		}
+	}
+	node.Attrs[i].Key = newKey
+	return true
+}
+
+// Classes returns the classes of this node, as listed in its class attribute.
+func (node *Node) Classes() []string {
+	return strings.FieldsFunc(node.AttrOrDefault("class", ""), isHTMLSpace)
+}
+
+// HasClass returns true if the class attribute of this node contains the given
+// class. Unlike AttrValueContains, only whole class names match: a node with
+// class="foobar" does not have the class "foo".
+func (node *Node) HasClass(class string) bool {
+	return containsWord(node.AttrOrDefault("class", ""), class, false)
+}
+
+// AddClass adds the given classes to the class attribute of this node, unless it
+// already has them. The attribute is added if needed.
+func (node *Node) AddClass(classes ...string) {
+	list := node.Classes()
+	changed := false
+	for _, class := range classes {
+		for _, c := range strings.FieldsFunc(class, isHTMLSpace) {
+			if !slices.Contains(list, c) {
+				list = append(list, c)
+				changed = true
+			}
+		}
+	}
+	if changed {
+		node.SetAttr("class", strings.Join(list, " "))
+	}
+}
+
+// RemoveClass removes the given classes from the class attribute of this node.
+// The attribute is kept, even if it ends up empty.
+func (node *Node) RemoveClass(classes ...string) {
+	var removed []string
+	for _, class := range classes {
+		removed = append(removed, strings.FieldsFunc(class, isHTMLSpace)...)
+	}
+	list := node.Classes()
+	kept := list[:0]
+	for _, c := range list {
+		if !slices.Contains(removed, c) {
+			kept = append(kept, c)
+		}
+	}
+	if len(kept) != len(list) {
+		node.SetAttr("class", strings.Join(kept, " "))
+	}
+}
+
+// ToggleClass removes the given class from this node if it has it, and adds it
+// otherwise. It returns true if the node has the class afterwards. If class is
+// a whitespace-separated list, each class is toggled separately, and true is
+// returned if the node has all of them afterwards.
+func (node *Node) ToggleClass(class string) bool {
+	classes := strings.FieldsFunc(class, isHTMLSpace)
+	for _, c := range classes {
+		if node.HasClass(c) {
+			node.RemoveClass(c)
+		} else {
+			node.AddClass(c)
+		}
+	}
+	for _, c := range classes {
+		if !node.HasClass(c) {
+			return false
+		}
+	}
+	return len(classes) > 0
+}
@@ -0,0 +1,93 @@
+package gosoup
+
+import (
+	"testing"
+)
+
+func TestAttrMutations(t *testing.T) {
+	n := &Node{Type: ElementNode, Data: "a", Attrs: []Attribute{{Key: "href", Val: "/"}, {Key: "id", Val: "x"}}}
+
+	n.SetAttr("href", "/home")
+	n.SetAttr("title", "Home")
+	assertEquals(t, `<a href="/home" id="x" title="Home"></a>`, render(t, n))
+
+	assert(t, n.RemoveAttr("id"), "expected id to be removed")
+	assert(t, !n.RemoveAttr("id"), "expected no id to remove")
+	assertEquals(t, `<a href="/home" title="Home"></a>`, render(t, n))
+
+	assert(t, n.RenameAttr("href", "data-href"), "expected href to be renamed")
+	assert(t, !n.RenameAttr("href", "src"), "expected no href to rename")
+	assert(t, n.RenameAttr("title", "title"), "expected title to be kept")
+	assertEquals(t, `<a data-href="/home" title="Home"></a>`, render(t, n))
+
+	// renaming over an existing attribute replaces it
+	assert(t, n.RenameAttr("title", "data-href"), "expected title to be renamed")
+	assertEquals(t, `<a data-href="Home"></a>`, render(t, n))
+	assertEquals(t, 1, len(n.Attrs))
+}
+
+func TestAttrNSMutations(t *testing.T) {
+	n := &Node{Type: ElementNode, Data: "use", Namespace: "svg", Attrs: []Attribute{{Key: "href", Val: "#a"}}}
+
+	n.SetAttrNS("xlink", "href", "#b")
+	assertEquals(t, 2, len(n.Attrs))
+	assertEquals(t, "#a", n.Attrs[0].Val)
+	assertEquals(t, Attribute{Namespace: "xlink", Key: "href", Val: "#b"}, n.Attrs[1])
+
+	n.SetAttrNS("xlink", "href", "#c")
+	assertEquals(t, "#c", n.Attrs[1].Val)
+
+	assert(t, n.RenameAttrNS("xlink", "href", "title"), "expected xlink:href to be renamed")
+	assertEquals(t, "href", n.Attrs[0].Key)
+	assertEquals(t, "title", n.Attrs[1].Key)
+
+	assert(t, n.RemoveAttrNS("xlink", "title"), "expected xlink:title to be removed")
+	assert(t, !n.RemoveAttrNS("xlink", "href"), "expected no xlink:href to remove")
+	assertEquals(t, 1, len(n.Attrs))
+	assertEquals(t, "", n.Attrs[0].Namespace)
+}
+
+func TestClasses(t *testing.T) {
+	n := &Node{Type: ElementNode, Data: "div", Attrs: []Attribute{{Key: "class", Val: " foobar\tbaz\nqux "}}}
+
+	assertDatas(t, []string{"foobar", "baz", "qux"}, n.Classes())
+	assert(t, n.HasClass("baz"), "expected class baz")
+	assert(t, !n.HasClass("foo"), "class foo should not match foobar")
+	assert(t, n.AttrValueContains("class", "foo"), "expected substring match")
+	assert(t, !n.HasClass(""), "empty class should not match")
+
+	n.AddClass("baz", "foo", "a b")
+	assertEquals(t, "foobar baz qux foo a b", n.Attr("class"))
+
+	n.RemoveClass("foobar", "a", "missing")
+	assertEquals(t, "baz qux foo b", n.Attr("class"))
+
+	assert(t, !n.ToggleClass("qux"), "expected qux to be removed")
+	assert(t, n.ToggleClass("qux"), "expected qux to be added")
+	assertEquals(t, "baz foo b qux", n.Attr("class"))
+
+	assert(t, !n.ToggleClass("qux c"), "expected qux to be removed")
+	assertEquals(t, "baz foo b c", n.Attr("class"))
+	assert(t, n.ToggleClass(" qux\td "), "expected qux and d to be added")
+	assert(t, !n.ToggleClass(" "), "expected no class to be toggled")
+	assertEquals(t, "baz foo b c qux d", n.Attr("class"))
+
+	n.RemoveClass("baz foo", "b\tqux", "c d")
+	assertEquals(t, "", n.Attr("class"))
+	assertDatas(t, nil, n.Classes())
+
+	bare := &Node{Type: ElementNode, Data: "p"}
+	bare.RemoveClass("x")
+	assert(t, !bare.HasAttr("class"), "expected no class attribute")
+	bare.AddClass("x")
+	assertEquals(t, "x", bare.Attr("class"))
+}
+
+func TestByClass(t *testing.T) {
+	doc := parseBody(t, `<ul><li class="foobar">1</li><li class="item foo">2</li><li class="foo">3</li></ul>`)
+
+	assertEquals(t, 2, len(doc.DescendantsByClass("foo").All()))
+	assertEquals(t, 3, len(doc.DescendantsByAttrValueContaining("class", "foo").All()))
+	ul := doc.DescendantsByTag("ul").First()
+	assertEquals(t, 1, len(ul.ChildrenByClass("item").All()))
+}
@@ -0,0 +1,82 @@
+package gosoup
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding"
+	"io"
+)
+
+const (
+	// prescanSize is the number of bytes examined to determine the encoding of a
+	// document, as defined by the WHATWG encoding sniffing algorithm.
+	prescanSize int = 1024
+)
+
+// byteOrderMarks are the byte order marks that determine the encoding of a
+// document, and which are not part of its content.
+var byteOrderMarks = [][]byte{
+	{0xef, 0xbb, 0xbf}, // utf-8
+	{0xfe, 0xff},       // utf-16be
+	{0xff, 0xfe},       // utf-16le
+}
+
+// Charset describes the character encoding of a parsed document.
+type Charset struct {
+	// Name is the canonical name of the encoding, as defined by the WHATWG
+	// Encoding Standard, such as "utf-8" or "windows-1252".
+	Name string
+	// Certain is true if the encoding was given by a byte order mark or by the
+	// Content-Type, and false if it was found in a <meta> element or guessed from
+	// the content.
+	Certain bool
+}
+
+// ParseWithCharset returns the parse tree for the HTML from the given Reader,
+// decoding it from the given charset. The charset is identified by one of its
+// WHATWG labels, such as "latin1", "windows-1252" or "shift_jis".
+func ParseWithCharset(r io.Reader, charsetLabel string) (*Node, error) {
+	e, _ := charset.Lookup(charsetLabel)
+	if e == nil {
+		return nil, fmt.Errorf("ParseWithCharset: unsupported charset %q", charsetLabel)
+	}
+	return Parse(e.NewDecoder().Reader(r))
+}
+
+// ParseAuto returns the parse tree for the HTML from the given Reader, after
+// determining its encoding and decoding it to UTF-8. It also returns the encoding
+// that was used.
+//
+// The encoding is determined following the WHATWG encoding sniffing algorithm:
+// it is given by a byte order mark if there is one, then by the charset parameter
+// of contentType, typically the value of the Content-Type HTTP header, which may
+// be empty. Otherwise, the first 1024 bytes of the document are prescanned for a
+// <meta charset> or <meta http-equiv="Content-Type"> element. As a last resort,
+// the encoding is guessed from these bytes: UTF-8 if they have non-ASCII
+// characters forming valid UTF-8, and windows-1252 otherwise, including when they
+// are all ASCII. A guessed or prescanned encoding is reported as not certain.
+func ParseAuto(r io.Reader, contentType string) (*Node, Charset, error) {
+	br := bufio.NewReaderSize(r, prescanSize)
+	start, err := br.Peek(prescanSize)
+	if err != nil && err != io.EOF {
+		return nil, Charset{}, err
+	}
+	e, name, certain := charset.DetermineEncoding(start, contentType)
+	for _, bom := range byteOrderMarks {
+		if bytes.HasPrefix(start, bom) {
+			br.Discard(len(bom))
+			break
+		}
+	}
+	var decoded io.Reader = br
+	if e != encoding.Nop {
+		decoded = e.NewDecoder().Reader(br)
+	}
+	doc, err := Parse(decoded)
+	if err != nil {
+		return nil, Charset{}, err
+	}
+	return doc, Charset{Name: name, Certain: certain}, nil
+}
@@ -0,0 +1,78 @@
+package gosoup
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func titleText(doc *Node) string {
+	return First(Filter(doc.DescendantNodes(), predicateIsTag("title"))).Text()
+}
+
+func TestParseWithCharset(t *testing.T) {
+	doc, err := ParseWithCharset(strings.NewReader("<title>caf\xe9</title>"), "latin1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, "café", titleText(doc))
+
+	_, err = ParseWithCharset(strings.NewReader("<title></title>"), "no-such-charset")
+	assert(t, err != nil, "expected an error for an unknown charset")
+}
+
+func TestParseAuto(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       []byte
+		contentType string
+		title       string
+		charset     Charset
+	}{
+		{"default", []byte("<title>caf\xe9</title>"), "", "café", Charset{"windows-1252", false}},
+		{"utf-8 guess", []byte("<title>café</title>"), "", "café", Charset{"utf-8", false}},
+		{"ascii", []byte("<title>cafe</title>"), "", "cafe", Charset{"windows-1252", false}},
+		{"empty", []byte(""), "", "", Charset{"windows-1252", false}},
+		{"meta charset", []byte("<meta charset=\"shift_jis\"><title>\x93\xfa\x96\x7b</title>"), "", "日本", Charset{"shift_jis", false}},
+		{"meta http-equiv", []byte("<meta http-equiv=\"Content-Type\" content=\"text/html; charset=ISO-8859-15\"><title>\xa4</title>"), "", "€", Charset{"iso-8859-15", false}},
+		{"content type", []byte("<meta charset=\"utf-8\"><title>caf\xe9</title>"), "text/html; charset=latin1", "café", Charset{"windows-1252", true}},
+		{"utf-8 bom", []byte("\xef\xbb\xbf<title>café</title>"), "text/html; charset=latin1", "café", Charset{"utf-8", true}},
+		{"utf-16le bom", []byte("\xff\xfe<\x00t\x00i\x00t\x00l\x00e\x00>\x00\xe9\x00"), "", "é", Charset{"utf-16le", true}},
+	}
+	for _, test := range tests {
+		doc, charset, err := ParseAuto(bytes.NewReader(test.input), test.contentType)
+		if err != nil {
+			t.Fatal(test.name, ": ", err)
+		}
+		if test.title != "" {
+			title := titleText(doc)
+			assertEqualsWithMsg(t, test.title, title, test.name, ": expected title ", test.title, ", got ", title)
+		}
+		assertEqualsWithMsg(t, test.charset, charset, test.name, ": expected ", test.charset, ", got ", charset)
+	}
+}
+
+func TestParseAutoASCII(t *testing.T) {
+	// ASCII is not guessed as UTF-8, even if UTF-8 follows the first 1024 bytes
+	input := "<title>" + strings.Repeat("a", 1100) + "</title><p>café</p>"
+	doc, charset, err := ParseAuto(strings.NewReader(input), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, "windows-1252", charset.Name)
+	assert(t, !charset.Certain, "expected an uncertain charset")
+	p := First(Filter(doc.DescendantNodes(), predicateIsTag("p")))
+	assertEquals(t, "cafÃ©", p.Text())
+}
+
+func TestParseAutoBeyondPrescan(t *testing.T) {
+	// the encoding declaration must be in the first 1024 bytes
+	padding := "<!--" + strings.Repeat("-", 1100) + "-->"
+	input := padding + "<meta charset=\"shift_jis\"><title>caf\xe9</title>"
+	doc, charset, err := ParseAuto(strings.NewReader(input), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, Charset{"windows-1252", false}, charset)
+	assertEquals(t, "café", titleText(doc))
+}
@@ -0,0 +1,45 @@
+package gosoup
+
+// Clone returns a copy of this node. If deep is true, all the descendants of this
+// node are copied as well, otherwise the copy has no children.
+//
+// The returned node is independent from the original tree: its attributes are
+// copied, and it has nil Parent, NextSibling and PrevSibling fields. The children
+// of a deep copy are completely linked with all their fields.
+func (node *Node) Clone(deep bool) *Node {
+	if node == nil {
+		return nil
+	}
+	var current *Node
+	walk(node, func(n *Node) (bool, error) {
+		c := &Node{
+			Type:      n.Type,
+			DataAtom:  n.DataAtom,
+			Data:      n.Data,
+			Namespace: n.Namespace,
+			Attrs:     make([]Attribute, len(n.Attrs)),
+		}
+		copy(c.Attrs, n.Attrs)
+		if current != nil {
+			current.link(c, current.LastChild, nil)
+		}
+		current = c
+		return deep, nil
+	}, func(*Node) error {
+		if current.Parent != nil {
+			current = current.Parent
+		}
+		return nil
+	})
+	return current
+}
+
+// CloneInto appends a deep copy of this node to the children of the given parent,
+// and returns the copy.
+//
+// This function panics if the given parent cannot have children, like AppendChild.
+func (node *Node) CloneInto(parent *Node) *Node {
+	n := node.Clone(true)
+	parent.AppendChild(n)
+	return n
+}
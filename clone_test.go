@@ -0,0 +1,54 @@
+package gosoup
+
+import (
+	"bytes"
+	"testing"
+)
+
+func render(t *testing.T, n *Node) string {
+	var b bytes.Buffer
+	if err := Render(&b, n); err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
+
+func TestClone(t *testing.T) {
+	doc := parseBody(t, `<div id="a" class="x"><p>one <b>two</b></p><p>three</p></div><div id="b"></div>`)
+	a := byID(doc, "a")
+	original := render(t, doc)
+
+	shallow := a.Clone(false)
+	assertEquals(t, `<div id="a" class="x"></div>`, render(t, shallow))
+	assert(t, shallow.Parent == nil && shallow.NextSibling == nil, "shallow clone is attached")
+
+	deep := a.Clone(true)
+	assertConsistent(t, deep)
+	assertEquals(t, render(t, a), render(t, deep))
+	assert(t, deep.Parent == nil && deep.PrevSibling == nil && deep.NextSibling == nil, "deep clone is attached")
+
+	// modifying the clone leaves the original intact
+	deep.Attrs[0].Val = "changed"
+	deep.FirstChild.FirstChild.Data = "changed"
+	deep.LastChild.Detach()
+	deep.AppendChild(&Node{Type: ElementNode, Data: "span"})
+	assertEquals(t, original, render(t, doc))
+	assertEquals(t, `<div id="changed" class="x"><p>changed<b>two</b></p><span></span></div>`, render(t, deep))
+
+	assert(t, (*Node)(nil).Clone(true) == nil, "expected a nil clone")
+}
+
+func TestCloneInto(t *testing.T) {
+	doc := parseBody(t, `<div id="a"><p>text</p></div><div id="b"><hr></div>`)
+	a, b := byID(doc, "a"), byID(doc, "b")
+
+	c := a.FirstChild.CloneInto(b)
+	assertConsistent(t, doc)
+	assert(t, c.Parent == b && c != a.FirstChild, "expected a new child of b")
+	assertEquals(t, `<div id="a"><p>text</p></div><div id="b"><hr/><p>text</p></div>`, renderBody(t, doc))
+
+	// cloning a node into itself does not create a cycle
+	a.CloneInto(a)
+	assertConsistent(t, doc)
+	assertEquals(t, `<div id="a"><p>text</p><div id="a"><p>text</p></div></div>`, render(t, a))
+}
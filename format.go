@@ -0,0 +1,358 @@
+package gosoup
+
+import (
+	"bufio"
+	"cmp"
+	"golang.org/x/net/html"
+	"io"
+	"slices"
+	"strings"
+)
+
+// SelfClosingStyle is the way the start tags of void elements, such as <br> or
+// <img>, are ended.
+type SelfClosingStyle int
+
+const (
+	// SelfClosingSlash ends void elements with a slash, as in <br/>, like Render.
+	SelfClosingSlash SelfClosingStyle = iota
+	// SelfClosingSpaceSlash ends void elements with a space and a slash, as in
+	// <br />.
+	SelfClosingSpaceSlash
+	// SelfClosingNone ends void elements without a slash, as in <br>.
+	SelfClosingNone
+)
+
+// RenderOptions holds the options of RenderWithOptions. The zero value renders
+// the tree like Render.
+type RenderOptions struct {
+	// Indent enables pretty-printing if not empty: the block elements, such as
+	// <div>, <p> or <li>, and the elements of the <head> are put on their own
+	// lines, indented with Indent once per level of nesting, while inline content
+	// such as text or <a> elements stays on the line of the enclosing block.
+	// Whitespace is collapsed in text.
+	Indent string
+	// Minify makes the output as compact as possible: whitespace is collapsed in
+	// text and removed around block elements, the optional end tags such as </li>
+	// or </p> are omitted, and so are the quotes around the attribute values that
+	// do not need them and the empty attribute values. Indent is ignored if Minify
+	// is set.
+	Minify bool
+	// SortAttrs renders the attributes of each element sorted by namespace and
+	// key, instead of in the order of the tree.
+	SortAttrs bool
+	// SelfClosing is the way void elements are ended.
+	SelfClosing SelfClosingStyle
+}
+
+// RenderWithOptions renders the parse tree n to the given writer, like Render,
+// with the given options.
+//
+// Whatever the options, parsing the output gives a tree equivalent to the
+// rendered one, provided it is 'well-formed' (see Render): it contains the same
+// elements, attributes, comments and text, except for whitespace that does not
+// change the way the document is displayed. The content of elements in which
+// whitespace matters, such as <pre>, <textarea>, <script> or <style>, is never
+// modified.
+func RenderWithOptions(w io.Writer, n *Node, opts RenderOptions) error {
+	if opts == (RenderOptions{}) {
+		// the renderer is only needed for the options, html.Render is faster
+		err := html.Render(w, UnwrapTree(n))
+		if err != nil {
+			// the renderer fails on the same node, but tells which one it is
+			if rerr := renderTree(bufio.NewWriter(io.Discard), n, opts); rerr != nil {
+				err = rerr
+			}
+		}
+		return err
+	}
+	if opts.Minify {
+		opts.Indent = ""
+	}
+	rw, flush := newRenderWriter(w)
+	if err := renderTree(rw, n, opts); err != nil {
+		return err
+	}
+	return flush()
+}
+
+func sortedAttrs(attrs []Attribute) []Attribute {
+	sorted := slices.Clone(attrs)
+	slices.SortStableFunc(sorted, func(a, b Attribute) int {
+		return cmp.Or(cmp.Compare(a.Namespace, b.Namespace), cmp.Compare(a.Key, b.Key))
+	})
+	return sorted
+}
+
+// The layout of the output is changed as follows when pretty-printing or
+// minifying. The children of a node are laid out either inline, as is, or as
+// blocks if some of them are block elements: each block element is then put on
+// its own line, as well as each run of inline content between them. The
+// whitespace at the boundaries of the runs, and at the boundaries of the content
+// of blocks, is removed: it is not significant there. It is added back as line
+// breaks and indentation when pretty-printing. Nothing is changed in the content
+// of verbatim elements.
+
+// layoutBlockElements are the elements laid out as blocks, besides blockElements.
+var layoutBlockElements = map[string]bool{
+	"colgroup": true,
+	"head":     true,
+	"p":        true,
+	"tbody":    true,
+	"td":       true,
+	"tfoot":    true,
+	"th":       true,
+	"thead":    true,
+	"tr":       true,
+}
+
+// isLayoutBlock returns true if the given child of a node laid out as blocks is to
+// be put on its own line.
+func isLayoutBlock(n *Node) bool {
+	if n.Parent != nil && n.Parent.Type == DocumentNode {
+		return true
+	}
+	if n.Type != ElementNode || n.Namespace != "" {
+		return false
+	}
+	return blockElements[n.Data] || layoutBlockElements[n.Data] || n.Parent != nil && n.Parent.IsTag("head")
+}
+
+// isVerbatim returns true if the content of the given node must be rendered as is.
+func isVerbatim(n *Node) bool {
+	return isHTMLElement(n, preformattedElements) || n.Type == ElementNode && childTextNodesAreLiteral(n)
+}
+
+// blockLayout returns true if the children of the given node, which is being
+// rendered, are to be laid out as blocks.
+func (r *renderer) blockLayout(n *Node) bool {
+	if r.verbatim > 0 || isVerbatim(n) {
+		return false
+	}
+	if n.Type == DocumentNode {
+		return true
+	}
+	if n.Type != ElementNode || n.Namespace != "" {
+		return false
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if isLayoutBlock(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// push records the layout of the children of the given node, whose start tag was
+// just rendered.
+func (r *renderer) push(n *Node) {
+	block := r.blockLayout(n)
+	r.blocks = append(r.blocks, block)
+	if block && n.Type != DocumentNode {
+		r.level++
+	}
+	if isVerbatim(n) {
+		r.verbatim++
+	}
+}
+
+// pop forgets the layout of the children of the given node, whose end tag is
+// about to be rendered, and puts the end tag on its own line if needed.
+func (r *renderer) pop(n *Node) {
+	block := r.blocks[len(r.blocks)-1]
+	r.blocks = r.blocks[:len(r.blocks)-1]
+	if isVerbatim(n) {
+		r.verbatim--
+	}
+	if block && n.Type != DocumentNode {
+		r.level--
+		r.newLine()
+	}
+}
+
+// parentBlockLayout returns true if the siblings of the node being rendered are
+// laid out as blocks.
+func (r *renderer) parentBlockLayout() bool {
+	return len(r.blocks) > 0 && r.blocks[len(r.blocks)-1]
+}
+
+// newLine starts a new indented line when pretty-printing, unless nothing was
+// rendered yet.
+func (r *renderer) newLine() error {
+	if r.opts.Indent == "" || !r.started {
+		return nil
+	}
+	if err := r.w.WriteByte('\n'); err != nil {
+		return err
+	}
+	for range r.level {
+		if _, err := r.w.WriteString(r.opts.Indent); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// layoutNode starts a new line before the given node if needed, and renders it if
+// it is some text whose whitespace must be changed. It returns true if the node
+// was rendered or must be skipped.
+func (r *renderer) layoutNode(n *Node) (bool, error) {
+	if r.verbatim > 0 {
+		return false, nil
+	}
+	block := r.parentBlockLayout()
+	if block && isLayoutBlock(n) {
+		return false, r.newLine()
+	}
+	startsRun, endsRun := r.runBoundaries(n)
+	if n.Type != TextNode {
+		if block && startsRun {
+			return false, r.newLine()
+		}
+		return false, nil
+	}
+	text := collapseSpace(n.Data)
+	if startsRun {
+		text = strings.TrimPrefix(text, " ")
+	}
+	if endsRun {
+		text = strings.TrimSuffix(text, " ")
+	}
+	if text == "" {
+		return true, nil
+	}
+	if block && startsRun {
+		if err := r.newLine(); err != nil {
+			return true, err
+		}
+	}
+	return true, r.text(text)
+}
+
+func (r *renderer) text(s string) error {
+	_, err := r.w.WriteString(html.EscapeString(s))
+	return err
+}
+
+// runBoundaries returns whether the given inline node starts and ends a run of
+// inline content, delimited by blocks or by the boundaries of the enclosing block.
+func (r *renderer) runBoundaries(n *Node) (starts, ends bool) {
+	prev := n.PrevSibling
+	for prev != nil && prev.IsBlankText() {
+		prev = prev.PrevSibling
+	}
+	next := n.NextSibling
+	for next != nil && next.IsBlankText() {
+		next = next.NextSibling
+	}
+	edge := r.parentBlockLayout() || isLayoutBlock(n.Parent)
+	starts = prev == nil && edge || prev != nil && isLayoutBlock(prev)
+	ends = next == nil && edge || next != nil && isLayoutBlock(next)
+	return starts, ends
+}
+
+// nextRendered returns the next sibling of the given node that is rendered.
+func (r *renderer) nextRendered(n *Node) *Node {
+	next := n.NextSibling
+	for next != nil && r.verbatim == 0 && next.IsBlankText() {
+		if starts, ends := r.runBoundaries(next); !starts && !ends {
+			break
+		}
+		next = next.NextSibling
+	}
+	return next
+}
+
+// collapseSpace replaces each sequence of whitespace of the given string with a
+// single space.
+func collapseSpace(s string) string {
+	if !strings.ContainsAny(s, blank) {
+		return s
+	}
+	var b strings.Builder
+	space := false
+	for i := 0; i < len(s); i++ {
+		if isHTMLSpace(rune(s[i])) {
+			space = true
+			continue
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteByte(s[i])
+	}
+	if space {
+		b.WriteByte(' ')
+	}
+	return b.String()
+}
+
+// optionalEndTag returns true if the end tag of the given element, whose children
+// were rendered, can be omitted according to the HTML syntax. Only the rules that
+// do not depend on the following whitespace or comments are applied, and only if
+// the parent of the element is a regular container for it, since the rules
+// assume valid documents.
+func (r *renderer) optionalEndTag(n *Node) bool {
+	if n.Namespace != "" || r.verbatim > 0 {
+		return false
+	}
+	p := n.Parent
+	if p == nil || p.Type != ElementNode || p.Namespace != "" {
+		return false
+	}
+	if n.Data == "p" {
+		if !blockElements[p.Data] && !layoutBlockElements[p.Data] {
+			return false
+		}
+	} else if !optionalEndTagParents[n.Data][p.Data] {
+		return false
+	}
+	next := r.nextRendered(n)
+	if next == nil {
+		return n.Data != "dt" && n.Data != "thead"
+	}
+	if next.Type != ElementNode || next.Namespace != "" {
+		return false
+	}
+	switch n.Data {
+	case "li":
+		return next.Data == "li"
+	case "dt", "dd":
+		return next.Data == "dt" || next.Data == "dd"
+	case "rt", "rp":
+		return next.Data == "rt" || next.Data == "rp"
+	case "optgroup":
+		return next.Data == "optgroup"
+	case "option":
+		return next.Data == "option" || next.Data == "optgroup"
+	case "thead", "tbody":
+		return next.Data == "tbody" || next.Data == "tfoot"
+	case "tr":
+		return next.Data == "tr"
+	case "td", "th":
+		return next.Data == "td" || next.Data == "th"
+	case "p":
+		// a <table> does not close a paragraph in quirks mode
+		return paragraphClosers[next.Data] && next.Data != "table"
+	}
+	return false
+}
+
+// optionalEndTagParents are, for each element whose end tag can be omitted, except
+// <p>, the parents in which it can be omitted.
+var optionalEndTagParents = map[string]map[string]bool{
+	"li":       {"ul": true, "ol": true, "menu": true},
+	"dt":       {"dl": true, "div": true},
+	"dd":       {"dl": true, "div": true},
+	"rt":       {"ruby": true},
+	"rp":       {"ruby": true},
+	"optgroup": {"select": true},
+	"option":   {"select": true, "datalist": true, "optgroup": true},
+	"thead":    {"table": true},
+	"tbody":    {"table": true},
+	"tfoot":    {"table": true},
+	"tr":       {"table": true, "thead": true, "tbody": true, "tfoot": true},
+	"td":       {"tr": true},
+	"th":       {"tr": true},
+}
@@ -0,0 +1,193 @@
+package gosoup
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func renderWithOptions(t testing.TB, n *Node, opts RenderOptions) string {
+	var b bytes.Buffer
+	if err := RenderWithOptions(&b, n, opts); err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
+
+// describeTree describes the nodes of the given tree one per line, ignoring the
+// whitespace that is not significant, so that equivalent trees have the same
+// description.
+func describeTree(root *Node) string {
+	var b strings.Builder
+	for n := range root.All() {
+		if n.IsBlankText() && !verbatimContent(n) {
+			continue
+		}
+		data := n.Data
+		if n.Type == TextNode && !verbatimContent(n) {
+			data = strings.Join(strings.FieldsFunc(data, isHTMLSpace), " ")
+		}
+		fmt.Fprintf(&b, "%d %d %s %q %v\n", n.Depth(), n.Type, n.Namespace, data, sortedAttrs(n.Attrs))
+	}
+	return b.String()
+}
+
+func verbatimContent(n *Node) bool {
+	for p := n.Parent; p != nil; p = p.Parent {
+		if isVerbatim(p) {
+			return true
+		}
+	}
+	return false
+}
+
+func assertEquivalent(t *testing.T, expected, actual *Node, msg ...interface{}) {
+	e, a := describeTree(expected), describeTree(actual)
+	assert(t, e == a, append(msg, "\nexpected tree:\n", e, "actual tree:\n", a)...)
+}
+
+func TestFixturesAreEquivalent(t *testing.T) {
+	assertEquivalent(t, parseBody(t, HTML), parseBody(t, HTML_CLEANED))
+}
+
+var renderOptionsInputs = []string{
+	HTML,
+	HTML_CLEANED,
+	HTML_POSITIONS,
+	`<ul><li>a</li> <li>b <b>c</b> </li><li><ul><li>nested</li></ul></li></ul><ol><li>x</li></ol>`,
+	`<dl><dt>term</dt><dd>def</dd><dt>a</dt><dt>b</dt><dd>c <p>d</p></dd></dl>`,
+	`<table><caption>c</caption><colgroup><col span=2></colgroup><thead><tr><th>h</th></tr></thead><tbody><tr><td>1</td><td>2</td></tr></tbody><tfoot><tr><td>f</td></tr></tfoot></table>`,
+	`<select><optgroup label=g><option>1</option><option selected>2</option></optgroup><option>3</option></select>`,
+	`<ruby>漢<rp>(</rp><rt>kan</rt><rp>)</rp></ruby>`,
+	`<a href="#"><p>in a link</p></a><video><p>fallback</p></video><my-element><p>custom</p></my-element>`,
+	`<p>before table<table><tr><td>x</td></tr></table>`,
+	`<!DOCTYPE html><p>before table<table><tr><td>x</td></tr></table><p>a</p>text`,
+	"<pre>\n\n  two newlines</pre><textarea>\n  keep  </textarea><p>  a  <b> b </b>  c  </p>",
+	`<script>var s = "  <p>  ";</script><style> p  >  b { } </style><noscript><p>no</p></noscript>`,
+	`<div>a<p>b</p>c<div><span> d </span></div><!-- comment -->e</div><hr><br>`,
+	`<svg viewBox="0 0 1 1"><text> a  b </text><foreignObject><div><p>x</p></div></foreignObject></svg>`,
+	`<p>a</p><!-- between --><p>b</p><p title="" data-x='a"b' data-y="a b" data-z="=" data-w="/">c`,
+	`<form><input disabled name=a value="x y"><input value=""><img src=a.png alt=/></form>`,
+	`<details><summary>s</summary>body</details><p>line<br>break</p>`,
+	"<a>\f",
+	"<p>a\fb</p>",
+}
+
+var renderOptionsCases = []RenderOptions{
+	{},
+	{Indent: "  "},
+	{Indent: "\t", SortAttrs: true, SelfClosing: SelfClosingSpaceSlash},
+	{Minify: true},
+	{Minify: true, SortAttrs: true, SelfClosing: SelfClosingNone},
+	{Minify: true, SelfClosing: SelfClosingSpaceSlash},
+}
+
+func TestRenderWithOptionsReparses(t *testing.T) {
+	for _, input := range renderOptionsInputs {
+		doc := parseBody(t, input)
+		for _, opts := range renderOptionsCases {
+			output := renderWithOptions(t, doc, opts)
+			assertEquivalent(t, doc, parseBody(t, output), "options ", opts, "\ninput: ", input, "\noutput: ", output)
+			// rendering is stable
+			assertEquals(t, output, renderWithOptions(t, parseBody(t, output), opts))
+		}
+	}
+}
+
+func TestRenderWithOptionsDefault(t *testing.T) {
+	for _, input := range renderOptionsInputs {
+		doc := parseBody(t, input)
+		assertEquals(t, render(t, doc), renderWithOptions(t, doc, RenderOptions{}))
+	}
+}
+
+func TestRenderIndent(t *testing.T) {
+	doc := parseBody(t, `<!DOCTYPE html><html><head><title> T </title><meta charset=utf-8></head>
+<body><div class=a>Some <b>bold</b>  text<ul><li>one</li><li>two <i>2</i></li></ul></div>
+<pre>  keep
+  this</pre><p>x<br>y</p></body></html>`)
+	expected := `<!DOCTYPE html>
+<html>
+  <head>
+    <title>T</title>
+    <meta charset="utf-8"/>
+  </head>
+  <body>
+    <div class="a">
+      Some <b>bold</b> text
+      <ul>
+        <li>one</li>
+        <li>two <i>2</i></li>
+      </ul>
+    </div>
+    <pre>  keep
+  this</pre>
+    <p>x<br/>y</p>
+  </body>
+</html>`
+	assertEquals(t, expected, renderWithOptions(t, doc, RenderOptions{Indent: "  "}))
+
+	p := First(Filter(doc.DescendantNodes(), predicateIsTag("p")))
+	assertEquals(t, "<p>x<br>y</p>", renderWithOptions(t, p, RenderOptions{Indent: "  ", SelfClosing: SelfClosingNone}))
+}
+
+func TestRenderMinify(t *testing.T) {
+	doc := parseBody(t, `<ul>
+	<li class="a b">one</li>
+	<li id="x" data-empty="">two   <b>2</b> </li>
+</ul>
+<p>para</p>
+<p>last</p>
+<table><tr><td>1</td><td>2</td></tr></table>`)
+	body := First(Filter(doc.DescendantNodes(), predicateIsTag("body")))
+	assertEquals(t, `<body><ul><li class="a b">one<li id=x data-empty>two <b>2</b></ul><p>para<p>last</p><table><tbody><tr><td>1<td>2</table></body>`,
+		renderWithOptions(t, body, RenderOptions{Minify: true}))
+
+	// the end tag of the rendered node itself is kept
+	li := First(Filter(doc.DescendantNodes(), predicateIsTag("li")))
+	assertEquals(t, `<li class="a b">one</li>`, renderWithOptions(t, li, RenderOptions{Minify: true}))
+}
+
+func TestRenderAttributesOptions(t *testing.T) {
+	doc := parseBody(t, `<img src="a.png" alt="/" data-b="2" data-a="1"><input value="x">`)
+	img := First(Filter(doc.DescendantNodes(), predicateIsTag("img")))
+	assertEquals(t, `<img alt="/" data-a="1" data-b="2" src="a.png"/>`, renderWithOptions(t, img, RenderOptions{SortAttrs: true}))
+	assertEquals(t, `<img src=a.png alt=/ data-b=2 data-a=1>`, renderWithOptions(t, img, RenderOptions{Minify: true, SelfClosing: SelfClosingNone}))
+	assertEquals(t, `<img src=a.png alt=/ data-b=2 data-a="1"/>`, renderWithOptions(t, img, RenderOptions{Minify: true}))
+	assertEquals(t, `<img src=a.png alt=/ data-b=2 data-a=1 />`, renderWithOptions(t, img, RenderOptions{Minify: true, SelfClosing: SelfClosingSpaceSlash}))
+	assertEquals(t, `img`, img.Data)
+	assertEquals(t, "data-b", img.Attrs[2].Key)
+}
+
+func FuzzRenderWithOptions(f *testing.F) {
+	for _, input := range renderOptionsInputs {
+		f.Add(input)
+	}
+	f.Add(`<a>0<li>0`)
+	f.Add(`<rt></rt><rp>`)
+	f.Add(`<a = 000>`)
+	f.Add("<a>\f")
+	f.Fuzz(func(t *testing.T, input string) {
+		doc, err := Parse(strings.NewReader(input))
+		if err != nil {
+			return
+		}
+		var b bytes.Buffer
+		if err := Render(&b, doc); err != nil {
+			return
+		}
+		if reparsed, err := Parse(&b); err != nil || describeTree(reparsed) != describeTree(doc) {
+			// the tree is not 'well-formed', see Render
+			return
+		}
+		for _, opts := range renderOptionsCases {
+			output := renderWithOptions(t, doc, opts)
+			reparsed, err := Parse(strings.NewReader(output))
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertEquivalent(t, doc, reparsed, "options ", opts, "\ninput: ", input, "\noutput: ", output)
+		}
+	})
+}
@@ -0,0 +1,85 @@
+package gosoup
+
+import (
+	"errors"
+	"golang.org/x/net/html/atom"
+	"io"
+	"strings"
+)
+
+// ParseFragment parses a fragment of HTML and returns the nodes that were found,
+// as if the fragment was the content of the given context element. For instance,
+// "<tr>" elements are only kept in the context of a table. Only the type, name,
+// namespace and attributes of the context element are used, it is not modified.
+//
+// If context is nil, the fragment is parsed as a whole document, and the returned
+// slice contains its <html> element.
+//
+// The returned nodes have a nil Parent field, but they are linked to each other
+// via their PrevSibling and NextSibling fields.
+func ParseFragment(r io.Reader, context *Node) ([]*Node, error) {
+	if context != nil {
+		if context.Type != ElementNode {
+			return nil, errors.New("ParseFragment: the context node is not an element")
+		}
+		// the context is copied, so that the parser doesn't see its ancestors
+		context = &Node{
+			Type:      ElementNode,
+			DataAtom:  atom.Lookup([]byte(context.Data)),
+			Data:      context.Data,
+			Namespace: context.Namespace,
+			Attrs:     context.Attrs,
+		}
+	}
+	p, err := newFragmentParser(r, context, Options{})
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := p.parseFragment()
+	if err != nil {
+		return nil, err
+	}
+	nodes := make([]*Node, 0, len(parsed))
+	for _, n := range parsed {
+		if len(nodes) > 0 {
+			prev := nodes[len(nodes)-1]
+			prev.NextSibling = n
+			n.PrevSibling = prev
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes, nil
+}
+
+// SetInnerHTML replaces the children of this node by the nodes parsed from the
+// given HTML, using this node as context. If this node is a document, the HTML is
+// parsed as a whole document.
+//
+// This function returns an error if this node is neither an element nor a
+// document.
+func (node *Node) SetInnerHTML(s string) error {
+	var nodes []*Node
+	switch node.Type {
+	case ElementNode:
+		var err error
+		nodes, err = ParseFragment(strings.NewReader(s), node)
+		if err != nil {
+			return err
+		}
+	case DocumentNode:
+		doc, err := Parse(strings.NewReader(s))
+		if err != nil {
+			return err
+		}
+		for child := doc.FirstChild; child != nil; child = child.NextSibling {
+			nodes = append(nodes, child)
+		}
+	default:
+		return errors.New("SetInnerHTML: the node is neither an element nor a document")
+	}
+	node.RemoveChildren()
+	for _, n := range nodes {
+		node.AppendChild(n)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package gosoup
+
+import (
+	"strings"
+	"testing"
+)
+
+func renderAll(t *testing.T, nodes []*Node) string {
+	var list []string
+	for _, n := range nodes {
+		list = append(list, render(t, n))
+	}
+	return strings.Join(list, "|")
+}
+
+func TestParseFragment(t *testing.T) {
+	tests := []struct {
+		context  *Node
+		fragment string
+		expected string
+	}{
+		{&Node{Type: ElementNode, Data: "div"}, "<p>one</p>text<b>two</b>", "<p>one</p>|text|<b>two</b>"},
+		{&Node{Type: ElementNode, Data: "tbody"}, "<tr><td>1</td></tr><tr><td>2</td></tr>", "<tr><td>1</td></tr>|<tr><td>2</td></tr>"},
+		{&Node{Type: ElementNode, Data: "div"}, "<tr><td>1</td></tr>", "1"},
+		{&Node{Type: ElementNode, Data: "ul"}, "<li>a<li>b", "<li>a</li>|<li>b</li>"},
+		{&Node{Type: ElementNode, Data: "table"}, "<td>x</td>", "<tbody><tr><td>x</td></tr></tbody>"},
+		{&Node{Type: ElementNode, Data: "textarea"}, "<b>raw</b>", "&lt;b&gt;raw&lt;/b&gt;"},
+		{nil, "<title>t</title>", "<html><head><title>t</title></head><body></body></html>"},
+	}
+	for _, test := range tests {
+		nodes, err := ParseFragment(strings.NewReader(test.fragment), test.context)
+		if err != nil {
+			t.Fatal(err)
+		}
+		value := renderAll(t, nodes)
+		assertEqualsWithMsg(t, test.expected, value, "for ", test.fragment, ", got ", value)
+
+		for i, n := range nodes {
+			assert(t, n.Parent == nil, "fragment node with a parent")
+			if i > 0 {
+				assert(t, n.PrevSibling == nodes[i-1] && nodes[i-1].NextSibling == n, "fragment nodes not linked")
+			}
+		}
+	}
+
+	_, err := ParseFragment(strings.NewReader("text"), &Node{Type: TextNode, Data: "div"})
+	assert(t, err != nil, "expected an error for a text context")
+}
+
+func TestSetInnerHTML(t *testing.T) {
+	doc := parseBody(t, `<table><tbody id="rows"><tr><td>old</td></tr></tbody></table><p id="p">old</p>`)
+	rows := byID(doc, "rows")
+
+	err := rows.SetInnerHTML("<tr><td>1</td></tr><tr><td>2</td></tr>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertConsistent(t, doc)
+	assertEquals(t, `<tbody id="rows"><tr><td>1</td></tr><tr><td>2</td></tr></tbody>`, render(t, rows))
+
+	p := byID(doc, "p")
+	if err := p.SetInnerHTML(""); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, `<p id="p"></p>`, render(t, p))
+
+	if err := doc.SetInnerHTML("<!DOCTYPE html><title>new</title>"); err != nil {
+		t.Fatal(err)
+	}
+	assertConsistent(t, doc)
+	assertEquals(t, `<!DOCTYPE html><html><head><title>new</title></head><body></body></html>`, render(t, doc))
+
+	err = (&Node{Type: TextNode, Data: "text"}).SetInnerHTML("x")
+	assert(t, err != nil, "expected an error for a text node")
+}
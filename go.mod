@@ -0,0 +1,8 @@
+module github.com/joffrey-bion/gosoup
+
+go 1.25.0
+
+require (
+	golang.org/x/net v0.57.0
+	golang.org/x/text v0.40.0
+)
@@ -2,16 +2,160 @@
 GoSoup allows to parse HTML content and browse the produced tree. It wraps the
 golang.org/x/net/html package, providing helpful methods.
 
+Parsing
+
+Parse expects UTF-8 input. ParseWithCharset decodes the input from a given
+charset, and ParseAuto determines the charset the way browsers do, from a byte
+order mark, the Content-Type or a <meta> element:
+
+    doc, charset, err := ParseAuto(resp.Body, resp.Header.Get("Content-Type"))
+
+ParseWithOptions gives more control over the produced tree, for instance to drop
+comments and blank text, or to limit the size of the tree. It can also record
+the position of each node in the source, which is then available via
+Node.Position and included in error messages.
+
+ParseFragment parses snippets of HTML in the context of a given element, and
+SetInnerHTML replaces the content of a node with such a snippet.
+
+A Node has the same layout as an html.Node, so that WrapTree and UnwrapTree
+convert a tree from one type to the other in constant time, without copying it,
+to use it with other code based on the html package.
+
+The parser is a port of the one of the html package, extended to record
+positions and enforce the options while building the tree. It matches the
+version of golang.org/x/net pinned in go.mod, and must be updated along with it
+to keep building the same trees as html.Parse. The ported files are under the
+license of golang.org/x/net, given in the LICENSE-golang.org-x-net file.
+
+Streaming
+
+Documents too large to be held in memory can be read with a Stream, which
+produces events for start and end tags, text and comments without building a
+tree. The parts of interest can still be materialized as trees:
+
+    for article := range NewStream(r).SubtreesByTag("article") {
+        doStuffWith(article)
+    }
+
 Iterators
 
 The most interesting functions provided by GoSoup are the iterator functions.
 These functions return a NodeIterator object, which can then be filtered and/or
 mapped and the nodes can then be collected into a slice.
 
+Besides Children and Descendants, iterators are available on the siblings and
+the ancestors of a node, with NextSiblings, PrevSiblings, Siblings, Ancestors
+and AncestorsOrSelf, and their ...Matching and ...ByTag variants. Closest finds
+the nearest enclosing node matching a predicate:
+
+    label := input.PrevSiblingsByTag("label").First()
+    table := cell.ClosestByTag("table")
+
+TreeIteratorOrder traverses the descendants of a node in a given
+TraversalOrder: breadth-first, post-order for bottom-up processing, or reverse
+document order. Depth gives the depth of any node in its tree, and
+DescendantNodesByDepth the depth of each node in breadth-first order.
+
 Read about the NodeIterator type and its methods to get an idea of how flexible
 and powerful it is. Should that power not suffice, you can have full control of
 what happens by directly using the internals of the NodeIterator type.
 
+Sequences
+
+The same nodes are also available as iter.Seq sequences, which can be used
+directly in range loops and involve no goroutine nor channel. Breaking out of
+such a loop requires no cleanup:
+
+    for n := range doc.DescendantsSeq() {
+        if (something) {
+            break
+        }
+        doStuffWith(n)
+    }
+
+ChildrenSeq and DescendantsSeq provide the same nodes as Children and
+Descendants, NextSiblingsSeq, PrevSiblingsSeq, SiblingsSeq, AncestorsSeq and
+AncestorsOrSelfSeq the same as their iterator counterparts, while ChildNodes,
+DescendantNodes and All provide every node, including blank text. The Filter,
+Map, Take and First functions process sequences the way NodeIterator methods
+process iterators, and NodeIterator.Seq and NewNodeIterator convert between the
+two.
+
+CSS Selectors
+
+Elements can also be targeted with CSS selectors, using the Select and
+SelectFirst methods. For instance:
+
+    links, err := doc.Select(`div.article > p:nth-child(2) a[href^="https"]`)
+
+Selectors can be compiled once with CompileSelector and reused via
+SelectCompiled. Read about the Selector type for the supported syntax.
+
+XPath
+
+XPath 1.0 expressions are supported as well, via the XPath and XPathFirst
+methods for node-sets, and EvaluateXPath for expressions returning other types or
+selecting attributes, which are not nodes of the tree:
+
+    next, err := doc.XPathFirst(`//a[contains(@class, "next")]`)
+    count, err := doc.EvaluateXPath(`count(//table[@id="results"]//tr)`)
+    hrefs, err := doc.EvaluateXPath(`//a/@href`) // see XPathResult.NodeSet
+
+Text Content
+
+Text returns the raw text of a subtree, while InnerText returns the text as a
+browser would render it, with collapsed whitespace and line breaks around block
+elements.
+
+Modifying The Tree
+
+Nodes can be moved, inserted and removed with AppendChild, PrependChild,
+InsertBefore, InsertAfter, RemoveChild, ReplaceWith and Detach, which keep all
+the links of the tree consistent. NodeIterator.Detach and NodeIterator.AppendTo
+apply the same operations to all the nodes of an iterator:
+
+    doc.DescendantsByTag("script").Detach()
+
+Clone and CloneInto copy subtrees, leaving the original tree intact.
+
+Rendering
+
+Render writes a tree back as HTML, and RenderWithOptions can pretty-print or
+minify it:
+
+    err := RenderWithOptions(w, doc, RenderOptions{Indent: "  "})
+
+OuterHTML and InnerHTML return the serialization of a node as a string, with or
+without its own tags.
+
+Sanitizing
+
+A Policy removes from a tree everything that was not explicitly allowed, so that
+untrusted HTML can be rendered safely. StrictPolicy, BasicPolicy and UGCPolicy
+are ready to use, and NewPolicy starts from scratch:
+
+    clean := UGCPolicy().SanitizeString(comment)
+
+Markdown
+
+Markdown converts a tree to CommonMark with the GitHub Flavored Markdown
+extensions, and RenderMarkdown accepts options to write links as references or
+to customize the conversion of given elements.
+
+Unmarshalling
+
+Unmarshal fills a struct from a tree, following the CSS selectors given by the
+soup tags of its fields, and converting the text or attributes of the matched
+elements to the types of the fields:
+
+    var product struct {
+        Name  string  `soup:"h1"`
+        Price float64 `soup:".price"`
+        Image url.URL `soup:"img.main,attr=src"`
+    }
+    err := Unmarshal(doc, &product)
+
 Raw Use Of Iterators
 
 The NodeIterator objects contains a read-only channel to read the nodes from.
@@ -38,55 +182,82 @@ to end the caller's loop.
 package gosoup
 
 import (
-	"errors"
-	"strings"
+	"context"
 )
 
-// GetDocContentType returns the content-type string description taken from a <meta>
-// element in the <head> part of the HTML tree.
-func GetDocContentType(node *Node) (string, error) {
-	root := node.Root() // moves to the document node
-	head := root.DescendantsByTag("head").First()
-	if head == nil {
-		return "", errors.New("GetDocContentType: head not found")
-	}
-	meta := head.DescendantsByAttrValueContaining("content", "charset=").First()
-	if meta == nil {
-		return "", errors.New("GetDocContentType: no head child with 'content' attribute containing 'charset'")
-	}
-	return meta.Attr("content"), nil
+func notBlank(n *Node) bool {
+	return !n.IsBlankText()
 }
 
-// GetDocCharset returns the charset contained in the content-type string description
-// taken from a <meta> element in the <head> part of the HTML tree.
-func GetDocCharset(node *Node) (string, error) {
-	content, err := GetDocContentType(node)
-	if err != nil {
-		return "", err
-	}
-	charsetWithBullshit := strings.Split(content, "charset=")[1]
-	// trim potential trailing stuff
-	charsetWithoutBullshit := strings.Split(charsetWithBullshit, ";")[0]
-	return strings.Split(charsetWithoutBullshit, " ")[0], nil // just in case
+// Children returns an iterator on this node's direct children, except blank text
+// nodes.
+//
+// The tree is not modified: the text nodes keep their surrounding whitespace,
+// which TrimmedData leaves out.
+func (node *Node) Children() NodeIterator {
+	return NewNodeIterator(node.ChildrenSeq())
 }
 
-func notBlank(n *Node) bool {
-	return !n.IsBlankText()
+// Descendants returns an iterator on this node's descendants in depth-first order,
+// except blank text nodes.
+//
+// The tree is not modified: the text nodes keep their surrounding whitespace,
+// which TrimmedData leaves out.
+func (node *Node) Descendants() NodeIterator {
+	return NewNodeIterator(node.DescendantsSeq())
 }
 
-func clean(n *Node) *Node {
-	n.TrimTextData()
-	return n
+// NextSiblings returns an iterator on the siblings following this node, in
+// document order, except blank text nodes.
+func (node *Node) NextSiblings() NodeIterator {
+	return NewNodeIterator(node.NextSiblingsSeq())
 }
 
-// Children returns an iterator on this node's direct children.
-func (node *Node) Children() NodeIterator {
-	return node.TreeIterator(false).Filter(notBlank).Map(clean)
+// PrevSiblings returns an iterator on the siblings preceding this node, starting
+// with the closest one, except blank text nodes.
+func (node *Node) PrevSiblings() NodeIterator {
+	return NewNodeIterator(node.PrevSiblingsSeq())
 }
 
-// Descendants returns an iterator on this node's descendants, in depth-first order.
-func (node *Node) Descendants() NodeIterator {
-	return node.TreeIterator(true).Filter(notBlank).Map(clean)
+// Siblings returns an iterator on the other children of this node's parent, in
+// document order, except blank text nodes.
+func (node *Node) Siblings() NodeIterator {
+	return NewNodeIterator(node.SiblingsSeq())
+}
+
+// Ancestors returns an iterator on the ancestors of this node, starting with its
+// parent and ending with the root of the tree.
+func (node *Node) Ancestors() NodeIterator {
+	return NewNodeIterator(node.AncestorsSeq())
+}
+
+// AncestorsOrSelf returns an iterator on this node followed by its ancestors.
+func (node *Node) AncestorsOrSelf() NodeIterator {
+	return NewNodeIterator(node.AncestorsOrSelfSeq())
+}
+
+// Closest returns the closest node matching the given predicate among this node
+// and its ancestors, or nil if there is none.
+func (node *Node) Closest(predicate func(node *Node) bool) *Node {
+	return First(Filter(node.AncestorsOrSelfSeq(), predicate))
+}
+
+// ClosestByTag returns the closest element with the specified tag name among this
+// node and its ancestors, or nil if there is none.
+func (node *Node) ClosestByTag(tagName string) *Node {
+	return node.Closest(predicateIsTag(tagName))
+}
+
+// ChildrenContext is like Children, but the returned iterator is bound to the
+// given context.
+func (node *Node) ChildrenContext(ctx context.Context) NodeIterator {
+	return NewNodeIteratorContext(ctx, node.ChildrenSeq())
+}
+
+// DescendantsContext is like Descendants, but the returned iterator is bound to
+// the given context.
+func (node *Node) DescendantsContext(ctx context.Context) NodeIterator {
+	return NewNodeIteratorContext(ctx, node.DescendantsSeq())
 }
 
 // ChildrenMatching returns an iterator on this node's direct children that match
@@ -101,6 +272,30 @@ func (node *Node) DescendantsMatching(predicate func(node *Node) bool) NodeItera
 	return node.Descendants().Filter(predicate)
 }
 
+// NextSiblingsMatching returns an iterator on the siblings following this node that
+// match the given predicate, in document order.
+func (node *Node) NextSiblingsMatching(predicate func(node *Node) bool) NodeIterator {
+	return node.NextSiblings().Filter(predicate)
+}
+
+// PrevSiblingsMatching returns an iterator on the siblings preceding this node that
+// match the given predicate, starting with the closest one.
+func (node *Node) PrevSiblingsMatching(predicate func(node *Node) bool) NodeIterator {
+	return node.PrevSiblings().Filter(predicate)
+}
+
+// SiblingsMatching returns an iterator on the siblings of this node that match the
+// given predicate, in document order.
+func (node *Node) SiblingsMatching(predicate func(node *Node) bool) NodeIterator {
+	return node.Siblings().Filter(predicate)
+}
+
+// AncestorsMatching returns an iterator on the ancestors of this node that match
+// the given predicate, starting with the closest one.
+func (node *Node) AncestorsMatching(predicate func(node *Node) bool) NodeIterator {
+	return node.Ancestors().Filter(predicate)
+}
+
 func predicateIsTag(tagName string) func(node *Node) bool {
 	return func(node *Node) bool {
 		return node.IsTag(tagName)
@@ -119,6 +314,30 @@ func (node *Node) DescendantsByTag(tagName string) NodeIterator {
 	return node.DescendantsMatching(predicateIsTag(tagName))
 }
 
+// NextSiblingsByTag returns an iterator on the siblings following this node with
+// the specified tag name, in document order.
+func (node *Node) NextSiblingsByTag(tagName string) NodeIterator {
+	return node.NextSiblingsMatching(predicateIsTag(tagName))
+}
+
+// PrevSiblingsByTag returns an iterator on the siblings preceding this node with
+// the specified tag name, starting with the closest one.
+func (node *Node) PrevSiblingsByTag(tagName string) NodeIterator {
+	return node.PrevSiblingsMatching(predicateIsTag(tagName))
+}
+
+// SiblingsByTag returns an iterator on the siblings of this node with the
+// specified tag name, in document order.
+func (node *Node) SiblingsByTag(tagName string) NodeIterator {
+	return node.SiblingsMatching(predicateIsTag(tagName))
+}
+
+// AncestorsByTag returns an iterator on the ancestors of this node with the
+// specified tag name, starting with the closest one.
+func (node *Node) AncestorsByTag(tagName string) NodeIterator {
+	return node.AncestorsMatching(predicateIsTag(tagName))
+}
+
 func predicateAttrValueContains(attrKey, match string) func(node *Node) bool {
 	return func(node *Node) bool {
 		return node.AttrValueContains(attrKey, match)
@@ -136,3 +355,21 @@ func (node *Node) ChildrenByAttrValueContaining(attrKey, match string) NodeItera
 func (node *Node) DescendantsByAttrValueContaining(attrKey, match string) NodeIterator {
 	return node.DescendantsMatching(predicateAttrValueContains(attrKey, match))
 }
+
+func predicateHasClass(class string) func(node *Node) bool {
+	return func(node *Node) bool {
+		return node.HasClass(class)
+	}
+}
+
+// ChildrenByClass returns an iterator on this node's direct children that have the
+// given class.
+func (node *Node) ChildrenByClass(class string) NodeIterator {
+	return node.ChildrenMatching(predicateHasClass(class))
+}
+
+// DescendantsByClass returns an iterator on this node's descendants that have the
+// given class, in depth-first order.
+func (node *Node) DescendantsByClass(class string) NodeIterator {
+	return node.DescendantsMatching(predicateHasClass(class))
+}
@@ -56,22 +56,22 @@ const (
 
 func assert(t *testing.T, test bool, msg ...interface{}) {
 	if !test {
-		t.Fatal(msg)
+		t.Fatal(msg...)
 	}
 }
 
 func assertEqualsWithMsg(t *testing.T, expected interface{}, value interface{}, msg ...interface{}) {
-	assert(t, value == expected, msg)
+	assert(t, value == expected, msg...)
 }
 
 func assertEquals(t *testing.T, expected interface{}, value interface{}) {
-	assertEqualsWithMsg(t, value == expected, "assert failed: value is ", value, " expected: ", expected)
+	assertEqualsWithMsg(t, expected, value, "assert failed: value is ", value, " expected: ", expected)
 }
 
 func assertNodeWithData(t *testing.T, ch <-chan *Node, data string) *Node {
 	node, ok := <-ch
 	assert(t, ok, "no node, expected '", data, "'")
-	assertEqualsWithMsg(t, data, node.Data, "expected node '"+data+"', got '"+node.Data+"'")
+	assertEqualsWithMsg(t, data, node.TrimmedData(), "expected node '"+data+"', got '"+node.TrimmedData()+"'")
 	return node
 }
 
@@ -226,3 +226,82 @@ func TestDescendantsMatching(t *testing.T) {
 	assertNodeWithData(t, ch, "hr")
 	assertNoMoreNodes(t, ch)
 }
+
+func TestTraversalDoesNotModifyTree(t *testing.T) {
+	doc, err := Parse(strings.NewReader(HTML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := render(t, doc)
+
+	for _, n := range doc.Descendants().All() {
+		n.Children().All()
+	}
+	doc.DescendantsByTag("p").All()
+	assertEquals(t, before, render(t, doc))
+
+	text := doc.DescendantsByTag("title").First().Children().First()
+	assertEquals(t, "Your Title Here", text.Data)
+	sentence := doc.DescendantsByTag("h2").First().NextSibling
+	assertEquals(t, "\n\t\tSend me mail at ", sentence.Data)
+	assertEquals(t, "Send me mail at", sentence.TrimmedData())
+	assertEquals(t, "h2", sentence.PrevSibling.TrimmedData())
+}
+
+func TestSiblings(t *testing.T) {
+	doc, err := Parse(strings.NewReader(HTML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h1 := doc.DescendantsByTag("h1").First()
+
+	ch := h1.NextSiblings().Nodes
+	assertNodeWithData(t, ch, "h2")
+	assertNodeWithData(t, ch, "Send me mail at")
+	assertNodeWithData(t, ch, "a")
+	assertNodeWithData(t, ch, ".")
+	assertNodeWithData(t, ch, "p")
+	assertNodeWithData(t, ch, "p")
+	assertNodeWithData(t, ch, "hr")
+	assertNoMoreNodes(t, ch)
+
+	ch = h1.PrevSiblings().Nodes
+	assertNodeWithData(t, ch, "is a link to another nifty site")
+	assertNodeWithData(t, ch, "a")
+	assertNodeWithData(t, ch, "hr")
+	assertNodeWithData(t, ch, "aside")
+	assertNoMoreNodes(t, ch)
+
+	assertEquals(t, 11, len(h1.Siblings().All()))
+	assertDatas(t, []string{"hr", "hr"}, datas(h1.SiblingsByTag("hr").Seq()))
+	assertDatas(t, []string{"p", "p"}, datas(h1.NextSiblingsByTag("p").Seq()))
+	assertDatas(t, []string{"aside"}, datas(h1.PrevSiblingsMatching(func(n *Node) bool {
+		return n.HasAttr("src") || n.IsTag("aside")
+	}).Seq()))
+	assertEquals(t, "http://somegreatsite.com", h1.PrevSiblingsByTag("a").First().Attr("href"))
+
+	assert(t, doc.Siblings().First() == nil, "expected no siblings for the document")
+	assert(t, doc.NextSiblings().First() == nil, "expected no next siblings for the document")
+}
+
+func TestAncestors(t *testing.T) {
+	doc, err := Parse(strings.NewReader(`<table id="outer"><tr><td><table id="inner"><tr><td><b>x</b></td></tr></table></td></tr></table>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := doc.DescendantsByTag("b").First()
+	text := b.FirstChild
+
+	assertDatas(t, []string{"b", "td", "tr", "tbody", "table", "td", "tr", "tbody", "table", "body", "html", ""},
+		datas(text.Ancestors().Seq()))
+	assertDatas(t, []string{"x", "b", "td"}, datas(Take(text.AncestorsOrSelfSeq(), 3)))
+	assertDatas(t, []string{"table", "table"}, datas(b.AncestorsByTag("table").Seq()))
+	assertEquals(t, "inner", b.AncestorsMatching(func(n *Node) bool { return n.HasAttr("id") }).First().Attr("id"))
+	assert(t, doc.Ancestors().First() == nil, "expected no ancestors for the document")
+
+	assertEquals(t, "inner", text.ClosestByTag("table").Attr("id"))
+	assertEquals(t, b, b.ClosestByTag("b"))
+	outer := text.Closest(func(n *Node) bool { return n.AttrOrDefault("id", "") == "outer" })
+	assertEquals(t, "table", outer.Data)
+	assert(t, b.ClosestByTag("ul") == nil, "expected no enclosing list")
+}
@@ -1,5 +1,11 @@
 package gosoup
 
+import (
+	"context"
+	"iter"
+	"sync"
+)
+
 const (
 	nodeBufferSize int = 20
 )
@@ -11,11 +17,48 @@ const (
 //
 // The caller should close the iterator via the Close() method when no more nodes
 // are going to be read, unless he exhausts the iterator's Nodes channel. This
-// unblocks internal goroutines and allows their garbage collection.
+// unblocks internal goroutines and allows their garbage collection. Closing an
+// iterator also closes the iterators it was derived from, so closing the last
+// stage of a pipeline is enough to stop all of its goroutines.
+//
+// An iterator may also be bound to a context, in which case cancelling the context
+// stops the whole pipeline and closes the Nodes channel. The cause of the
+// cancellation is then available via the Err() method.
 type NodeIterator struct {
-	Nodes  <-chan *Node
-	exit   chan interface{}
-	closed bool
+	Nodes <-chan *Node
+	ctx   context.Context
+	state *iteratorState
+}
+
+// iteratorState is shared by all the copies of a NodeIterator.
+type iteratorState struct {
+	exit     chan struct{} // closed when the iterator is closed
+	once     sync.Once
+	upstream *iteratorState // the state of the iterator this one is derived from
+
+	mu  sync.Mutex
+	err error // written by the goroutine before closing Nodes
+}
+
+func (s *iteratorState) setErr(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+func (s *iteratorState) getErr() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// close closes the exit channel of this state and of all upstream states.
+func (s *iteratorState) close() {
+	for ; s != nil; s = s.upstream {
+		s.once.Do(func() {
+			close(s.exit)
+		})
+	}
 }
 
 // Close notifies this NodeIterator that no more nodes will be read from it.
@@ -24,10 +67,13 @@ type NodeIterator struct {
 // This function should be called when the caller stops reading nodes while the
 // channel is not exhausted. When the channel is exhausted, there is no need to
 // call Close().
+//
+// Close may be called several times, and from several goroutines. It does not
+// wait for the internal goroutines to exit, but they do so without sending any
+// more nodes.
 func (i NodeIterator) Close() {
-	if !i.closed {
-		i.exit <- true
-		i.closed = true
+	if i.state != nil {
+		i.state.close()
 	}
 }
 
@@ -71,85 +117,145 @@ func (i NodeIterator) Apply(f func(n *Node)) {
 	}
 }
 
-// Filter returns a new iterator that only iterates on the nodes of this iterator
-// that match the given predicate.
-func (i NodeIterator) Filter(predicate func(*Node) bool) NodeIterator {
-	c := make(chan *Node)
-	filtered := NodeIterator{c, i.exit, false}
-	go func() {
+// Err returns the cause of the cancellation of this iterator's context if the
+// iterator stopped because of it, and nil otherwise. It should be called after
+// the Nodes channel is closed.
+func (i NodeIterator) Err() error {
+	if i.state == nil {
+		return nil
+	}
+	return i.state.getErr()
+}
+
+// Seq returns a sequence of the nodes of this iterator. Breaking out of a loop
+// over the sequence closes this iterator.
+func (i NodeIterator) Seq() iter.Seq[*Node] {
+	return func(yield func(*Node) bool) {
 		for node := range i.Nodes {
-			if predicate(node) {
-				c <- node
+			if !yield(node) {
+				i.Close()
+				return
 			}
 		}
-		close(c)
-	}()
-	return filtered
+	}
+}
+
+// Filter returns a new iterator that only iterates on the nodes of this iterator
+// that match the given predicate.
+func (i NodeIterator) Filter(predicate func(*Node) bool) NodeIterator {
+	return i.then(Filter(i.Seq(), predicate))
 }
 
 // Map returns a new iterator that iterates on the nodes produced by applying the
 // given mapping function on each node of this iterator.
 func (i NodeIterator) Map(mapper func(*Node) *Node) NodeIterator {
-	c := make(chan *Node)
-	filtered := NodeIterator{c, i.exit, false}
-	go func() {
-		for node := range i.Nodes {
-			c <- mapper(node)
-		}
-		close(c)
-	}()
-	return filtered
+	return i.then(Map(i.Seq(), mapper))
 }
 
 // Limit returns a new iterator that automatically stops if it has read the given
 // maximum number of Nodes.
 func (i NodeIterator) Limit(max int) NodeIterator {
-	c := make(chan *Node)
-	limited := NodeIterator{c, i.exit, false}
+	return i.then(Take(i.Seq(), max))
+}
+
+// then returns a new iterator on the given sequence, derived from the nodes of
+// this iterator and bound to the same context.
+func (i NodeIterator) then(seq iter.Seq[*Node]) NodeIterator {
+	ctx := i.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return newNodeIterator(ctx, seq, i.state)
+}
+
+// NewNodeIterator returns an iterator on the nodes of the given sequence. The
+// sequence is consumed by an internal goroutine, which stops when the iterator is
+// closed or exhausted.
+func NewNodeIterator(seq iter.Seq[*Node]) NodeIterator {
+	return newNodeIterator(context.Background(), seq, nil)
+}
+
+// NewNodeIteratorContext is like NewNodeIterator, but the internal goroutine also
+// stops when the given context is cancelled.
+func NewNodeIteratorContext(ctx context.Context, seq iter.Seq[*Node]) NodeIterator {
+	return newNodeIterator(ctx, seq, nil)
+}
+
+// emptyNodeIterator returns an iterator without nodes. It is returned along with
+// errors, so that a caller ignoring the error does not block on the iterator.
+func emptyNodeIterator() NodeIterator {
+	return NewNodeIterator(func(func(*Node) bool) {})
+}
+
+// newNodeIterator returns an iterator on the given sequence, bound to ctx. If the
+// sequence is derived from an upstream iterator, closing the new iterator closes
+// the upstream one, and the new iterator reports the errors of the upstream one.
+func newNodeIterator(ctx context.Context, seq iter.Seq[*Node], upstream *iteratorState) NodeIterator {
+	out := make(chan *Node, nodeBufferSize)
+	state := &iteratorState{exit: make(chan struct{}), upstream: upstream}
 	go func() {
-		count := 0
-		for node := range i.Nodes {
-			c <- node
-			count++
-			if count > max {
-				i.Close()
-				break
+		defer close(out)
+		for node := range seq {
+			// check for closing first, select chooses randomly among ready cases
+			select {
+			case <-state.exit:
+				return
+			case <-ctx.Done():
+				state.setErr(context.Cause(ctx))
+				return
+			default:
 			}
+			select {
+			case <-state.exit:
+				// the caller will not read any more nodes, so
+				// don't try to send to avoid blocking forever
+				return
+			case <-ctx.Done():
+				state.setErr(context.Cause(ctx))
+				return
+			case out <- node:
+			}
+		}
+		if upstream != nil {
+			state.setErr(upstream.getErr())
 		}
-		close(c)
 	}()
-	return limited
+	return NodeIterator{out, ctx, state}
 }
 
 // TreeIterator returns an iterator on this node's descendants in depth-first order.
 // If recursive is false, only direct children are considered.
 func (node *Node) TreeIterator(recursive bool) NodeIterator {
+	return node.TreeIteratorContext(context.Background(), recursive)
+}
+
+// TreeIteratorContext is like TreeIterator, but the returned iterator is bound to
+// the given context: cancelling it stops the iterator and all the iterators
+// derived from it via Filter, Map and Limit.
+func (node *Node) TreeIteratorContext(ctx context.Context, recursive bool) NodeIterator {
 	if node == nil {
 		panic("iterateOnDescendants: null input node")
 	}
-	out := make(chan *Node, nodeBufferSize)
-	exit := make(chan interface{}, 1)
-	go func() {
-		node.recIterateOnDescendants(recursive, out, exit)
-		close(out)
-	}()
-	return NodeIterator{out, exit, false}
-}
-
-func (node *Node) recIterateOnDescendants(recursive bool, out chan<- *Node, exit chan interface{}) {
-	for child := node.FirstChild; child != nil; child = child.NextSibling {
-		select {
-		case <-exit:
-			// the caller will not read any more nodes, so
-			// don't try to send to avoid blocking forever
-			exit <- true // to exit all calls in the recursive stack
-			return
-		default:
-			out <- child
-			if recursive {
-				// browse the child's children
-				child.recIterateOnDescendants(recursive, out, exit)
-			}
-		}
+	if recursive {
+		return NewNodeIteratorContext(ctx, node.DescendantNodes())
+	}
+	return NewNodeIteratorContext(ctx, node.ChildNodes())
+}
+
+// TreeIteratorOrder returns an iterator on this node's descendants in the given
+// order, including blank text nodes. The depth of each node can be obtained with
+// Depth, or more efficiently with DescendantNodesByDepth in breadth-first order.
+//
+// This function panics if the order is unknown.
+func (node *Node) TreeIteratorOrder(order TraversalOrder) NodeIterator {
+	return node.TreeIteratorOrderContext(context.Background(), order)
+}
+
+// TreeIteratorOrderContext is like TreeIteratorOrder, but the returned iterator is
+// bound to the given context.
+func (node *Node) TreeIteratorOrderContext(ctx context.Context, order TraversalOrder) NodeIterator {
+	if node == nil {
+		panic("TreeIteratorOrder: null input node")
 	}
+	return NewNodeIteratorContext(ctx, node.DescendantNodesInOrder(order))
 }
@@ -0,0 +1,181 @@
+package gosoup
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// bigDocument returns a document with enough nodes to keep iterators busy.
+func bigDocument(t *testing.T, paragraphs int) *Node {
+	html := "<html><body>" + strings.Repeat("<p>text <b>bold</b></p>", paragraphs) + "</body></html>"
+	doc, err := Parse(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+// iteratorGoroutines returns the stacks of the running goroutines started by
+// iterators.
+func iteratorGoroutines() []string {
+	buf := make([]byte, 1<<20)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	var leaked []string
+	for _, g := range strings.Split(string(buf), "\n\n") {
+		if strings.Contains(g, "gosoup.newNodeIterator") {
+			leaked = append(leaked, g)
+		}
+	}
+	return leaked
+}
+
+// assertNoIteratorGoroutines waits for all the goroutines started by iterators
+// to exit, and fails the test if they don't in a reasonable time.
+func assertNoIteratorGoroutines(t *testing.T) {
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		leaked := iteratorGoroutines()
+		if len(leaked) == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal(len(leaked), " iterator goroutines leaked:\n", strings.Join(leaked, "\n\n"))
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestTreeIteratorContextCancel(t *testing.T) {
+	doc := bigDocument(t, 1000)
+	assertNoIteratorGoroutines(t)
+
+	cause := errors.New("job aborted")
+	ctx, cancel := context.WithCancelCause(context.Background())
+	it := doc.TreeIteratorContext(ctx, true).
+		Filter(notBlank).
+		Map(func(n *Node) *Node { return n }).
+		Limit(100000)
+
+	assert(t, it.Next() != nil, "expected a first node")
+	cancel(cause)
+
+	count := 0
+	for range it.Nodes {
+		count++
+	}
+	assert(t, count < 5000, "too many nodes read after cancellation: ", count)
+	assert(t, errors.Is(it.Err(), cause), "expected the cancellation cause, got ", it.Err())
+	assertNoIteratorGoroutines(t)
+}
+
+func TestIteratorContextDeadline(t *testing.T) {
+	doc := bigDocument(t, 1000)
+	assertNoIteratorGoroutines(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	it := doc.DescendantsContext(ctx).Filter(predicateIsTag("b"))
+	<-ctx.Done()
+	for range it.Nodes {
+	}
+	assert(t, errors.Is(it.Err(), context.DeadlineExceeded), "expected deadline exceeded, got ", it.Err())
+	assertNoIteratorGoroutines(t)
+}
+
+func TestIteratorContextNotCancelled(t *testing.T) {
+	doc := bigDocument(t, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	it := doc.ChildrenContext(ctx).Filter(notBlank)
+	nodes := it.All()
+	cancel()
+	assertEquals(t, 1, len(nodes))
+	assert(t, it.Err() == nil, "expected no error, got ", it.Err())
+	assert(t, doc.Children().Err() == nil, "expected no error")
+}
+
+func TestIteratorClose(t *testing.T) {
+	doc := bigDocument(t, 1000)
+	assertNoIteratorGoroutines(t)
+
+	it := doc.Descendants()
+	assert(t, it.Next() != nil, "expected a first node")
+	it.Close()
+	it.Close()
+	assertNoIteratorGoroutines(t)
+
+	// closing a copy closes the original
+	it = doc.Descendants()
+	copied := it
+	copied.Close()
+	for range it.Nodes {
+	}
+	assertNoIteratorGoroutines(t)
+
+	// closing from several goroutines
+	it = doc.Descendants()
+	done := make(chan bool)
+	for i := 0; i < 10; i++ {
+		go func() {
+			it.Close()
+			done <- true
+		}()
+	}
+	for i := 0; i < 10; i++ {
+		<-done
+	}
+	assertNoIteratorGoroutines(t)
+
+	// closing an iterator that was never created by a constructor
+	NodeIterator{}.Close()
+}
+
+func TestIteratorClosePropagation(t *testing.T) {
+	doc := bigDocument(t, 1000)
+	assertNoIteratorGoroutines(t)
+
+	// closing the last stage stops all stages
+	it := doc.TreeIterator(true).
+		Filter(notBlank).
+		Map(func(n *Node) *Node { return n }).
+		Filter(predicateIsTag("b")).
+		Limit(500)
+	assert(t, it.Next() != nil, "expected a first node")
+	it.Close()
+	it.Close()
+	assertNoIteratorGoroutines(t)
+
+	// a filter that never matches keeps its upstream busy without sending
+	it = doc.TreeIterator(true).Filter(func(*Node) bool { return false }).Map(func(n *Node) *Node { return n })
+	it.Close()
+	assertNoIteratorGoroutines(t)
+
+	// First closes the whole pipeline
+	b := doc.Descendants().Filter(predicateIsTag("b")).Limit(10).First()
+	assert(t, b != nil && b.IsTag("b"), "expected a <b> node")
+	assertNoIteratorGoroutines(t)
+
+	// Limit stops its upstream once reached, without closing its own output early
+	nodes := doc.Descendants().Filter(predicateIsTag("p")).Limit(3).All()
+	assertEquals(t, 3, len(nodes))
+	assertNoIteratorGoroutines(t)
+
+	// closing an upstream stage ends the downstream ones
+	upstream := doc.Descendants()
+	downstream := upstream.Filter(notBlank)
+	upstream.Close()
+	for range downstream.Nodes {
+	}
+	assert(t, downstream.Err() == nil, "expected no error, got ", downstream.Err())
+	assertNoIteratorGoroutines(t)
+}
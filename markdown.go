@@ -0,0 +1,627 @@
+package gosoup
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// LinkStyle is the way links are written in Markdown.
+type LinkStyle int
+
+const (
+	// InlineLinks puts the URL of links right after their text:
+	// [text](https://example.com).
+	InlineLinks LinkStyle = iota
+	// ReferenceLinks numbers the links, [text][1], and lists their URLs at the
+	// end of the document: [1]: https://example.com.
+	ReferenceLinks
+)
+
+// MarkdownHook converts an element to Markdown, in place of the default
+// conversion. It receives the Markdown of the content of the element, and returns
+// the Markdown of the whole element, or false to fall back to the default
+// conversion.
+//
+// Leading and trailing newlines in the result separate it from what surrounds
+// it: blocks such as paragraphs are surrounded by two newlines, inline content by
+// none.
+type MarkdownHook func(n *Node, content string) (string, bool)
+
+// MarkdownOptions controls the conversion of HTML to Markdown.
+type MarkdownOptions struct {
+	// LinkStyle is the way links are written, inline by default.
+	LinkStyle LinkStyle
+	// Hooks customize the conversion of elements, by tag name.
+	Hooks map[string]MarkdownHook
+}
+
+// Markdown returns the Markdown for this node, like RenderMarkdown with the
+// default options.
+func (node *Node) Markdown() string {
+	c := &markdownConverter{opts: MarkdownOptions{}}
+	return c.convert(node)
+}
+
+// RenderMarkdown writes the Markdown for the given node, following the CommonMark
+// specification with the GitHub Flavored Markdown extensions:
+//
+//   - headings are written in the ATX style: ## Heading
+//   - <b> and <strong> are written **strong**, <i> and <em> *emphasized*, and
+//     <s> and <del> ~~struck through~~
+//   - links and images are written inline, or as references if the options say
+//     so
+//   - lists, including nested ones, ordered lists starting at their start
+//     attribute, and checkboxes as task list items
+//   - <code> elements as code spans, and <pre> elements as fenced code blocks,
+//     with the language given by a class="language-x" on the <pre> element or on
+//     its <code> child
+//   - blockquotes, horizontal rules and line breaks
+//   - tables, with the first row as header and the alignment of its cells
+//
+// Characters that Markdown would interpret are escaped in text. Hidden elements
+// such as <script>, <style> or <head>, and comments, are skipped. Other elements
+// are replaced by their content, on their own lines for block elements.
+func RenderMarkdown(w io.Writer, n *Node, opts MarkdownOptions) error {
+	c := &markdownConverter{opts: opts}
+	_, err := io.WriteString(w, c.convert(n))
+	return err
+}
+
+// markdownConverter converts a tree to Markdown, bottom-up: the Markdown of the
+// content of each element is accumulated in a buffer, which is then converted
+// when the element is left and appended to the buffer of its parent.
+type markdownConverter struct {
+	opts    MarkdownOptions
+	buffers [][]byte
+	nodes   []*Node           // the nodes whose content is in the buffers
+	pre     int               // number of enclosing <pre> elements
+	cells   map[*Node]string  // the content of table cells, until their table is converted
+	items   map[*Node]int     // the number of the current item of the <ol> elements
+	refs    []string          // the link reference definitions
+	refIDs  map[string]string // the IDs of the references, by definition
+}
+
+func (c *markdownConverter) convert(n *Node) string {
+	c.buffers = [][]byte{nil}
+	c.nodes = []*Node{nil}
+	_ = walk(n, c.enter, c.leave)
+	s := strings.Trim(string(c.buffers[0]), " \n")
+	if len(c.refs) > 0 {
+		s += "\n\n" + strings.Join(c.refs, "\n")
+	}
+	if s == "" {
+		return ""
+	}
+	return s + "\n"
+}
+
+func (c *markdownConverter) top() *[]byte {
+	return &c.buffers[len(c.buffers)-1]
+}
+
+func (c *markdownConverter) enter(n *Node) (bool, error) {
+	if n.Type == TextNode {
+		c.text(n.Data)
+		return false, nil
+	}
+	c.buffers = append(c.buffers, nil)
+	c.nodes = append(c.nodes, n)
+	switch n.Type {
+	case DocumentNode:
+		return true, nil
+	case ElementNode:
+	default:
+		return false, nil
+	}
+	if n.Namespace != "" {
+		return true, nil
+	}
+	c.countItem(n)
+	if c.opts.Hooks[n.Data] != nil {
+		if n.Data == "pre" {
+			c.pre++
+		}
+		return true, nil
+	}
+	switch n.Data {
+	case "code":
+		return c.pre > 0, nil
+	case "pre", "img", "br", "hr", "input":
+		return false, nil
+	}
+	return !isHiddenElement(n), nil
+}
+
+func (c *markdownConverter) leave(n *Node) error {
+	if n.Type == TextNode {
+		return nil
+	}
+	content := string(*c.top())
+	c.buffers = c.buffers[:len(c.buffers)-1]
+	c.nodes = c.nodes[:len(c.nodes)-1]
+	switch n.Type {
+	case DocumentNode:
+	case ElementNode:
+		content = c.element(n, content)
+	default:
+		content = ""
+	}
+	*c.top() = appendMarkdown(*c.top(), content)
+	return nil
+}
+
+// appendMarkdown appends s to the Markdown in buf. The leading newlines of s and
+// the trailing newlines of buf are merged, since both mean that a line break or a
+// blank line separates them. A "!" ending buf is escaped if s starts with a link,
+// which would otherwise become an image.
+func appendMarkdown(buf []byte, s string) []byte {
+	if s == "" {
+		return buf
+	}
+	if s[0] == '[' && len(buf) > 0 && buf[len(buf)-1] == '!' {
+		buf = append(buf[:len(buf)-1], `\!`...)
+	}
+	trimmed := strings.TrimLeft(s, "\n")
+	if lead := len(s) - len(trimmed); lead > 0 {
+		buf = bytes.TrimRight(buf, " ")
+		trail := len(buf) - len(bytes.TrimRight(buf, "\n"))
+		if len(buf) > trail {
+			for range lead - trail {
+				buf = append(buf, '\n')
+			}
+		}
+	}
+	return append(buf, trimmed...)
+}
+
+// text appends the given text to the current buffer, with collapsed whitespace
+// and escaped Markdown syntax.
+func (c *markdownConverter) text(s string) {
+	buf := c.top()
+	if c.pre > 0 {
+		*buf = append(*buf, s...)
+		return
+	}
+	s = collapseSpace(s)
+	last := c.lastByte()
+	lineStart := last == 0 || last == '\n'
+	if strings.HasPrefix(s, " ") && (lineStart || last == ' ') {
+		s = s[1:]
+	}
+	*buf = append(*buf, escapeMarkdown(s, lineStart)...)
+}
+
+// lastByte returns the last byte of the Markdown preceding the current position,
+// looking into the buffers of the enclosing inline elements, or 0 at the start
+// of a block.
+func (c *markdownConverter) lastByte() byte {
+	for i := len(c.buffers) - 1; i >= 0; i-- {
+		if buf := c.buffers[i]; len(buf) > 0 {
+			return buf[len(buf)-1]
+		}
+		if n := c.nodes[i]; n == nil || n.Type != ElementNode || startsMarkdownBlock(n) {
+			break
+		}
+	}
+	return 0
+}
+
+// startsMarkdownBlock returns true if the content of the given element starts on
+// a new line.
+func startsMarkdownBlock(n *Node) bool {
+	switch n.Data {
+	case "p", "li", "td", "th":
+		return true
+	}
+	return blockElements[n.Data] || layoutBlockElements[n.Data]
+}
+
+// markdownEscaper escapes the characters that have a meaning in Markdown text,
+// wherever they are.
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"`", "\\`",
+	`*`, `\*`,
+	`_`, `\_`,
+	`~`, `\~`,
+	`[`, `\[`,
+	`]`, `\]`,
+	`<`, `\<`,
+)
+
+// escapeMarkdown escapes the given text so that Markdown renders it as is. If the
+// text starts a line, what would start a block is escaped as well.
+func escapeMarkdown(s string, lineStart bool) string {
+	s = markdownEscaper.Replace(s)
+	if i := strings.IndexByte(s, '&'); i >= 0 {
+		// only what looks like an entity needs escaping
+		var b strings.Builder
+		for i >= 0 {
+			b.WriteString(s[:i])
+			if i+1 < len(s) && (isASCIILetter(s[i+1]) || s[i+1] == '#') {
+				b.WriteByte('\\')
+			}
+			b.WriteByte('&')
+			s = s[i+1:]
+			i = strings.IndexByte(s, '&')
+		}
+		b.WriteString(s)
+		s = b.String()
+	}
+	if !lineStart || s == "" {
+		return s
+	}
+	switch s[0] {
+	case '#', '+', '-', '=', '>', '|':
+		return `\` + s
+	}
+	// ordered list items
+	digits := 0
+	for digits < len(s) && digits < 10 && '0' <= s[digits] && s[digits] <= '9' {
+		digits++
+	}
+	if digits > 0 && digits < len(s) && (s[digits] == '.' || s[digits] == ')') {
+		return s[:digits] + `\` + s[digits:]
+	}
+	return s
+}
+
+func isASCIILetter(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// element returns the Markdown for the given element, whose content was
+// converted already.
+func (c *markdownConverter) element(n *Node, content string) string {
+	if n.Namespace != "" {
+		return content
+	}
+	if hook := c.opts.Hooks[n.Data]; hook != nil {
+		if n.Data == "pre" {
+			c.pre--
+		}
+		if s, ok := hook(n, content); ok {
+			return s
+		}
+	}
+	switch n.Data {
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		content = strings.Join(strings.Fields(content), " ")
+		if content == "" {
+			return ""
+		}
+		return "\n\n" + strings.Repeat("#", int(n.Data[1]-'0')) + " " + content + "\n\n"
+	case "b", "strong":
+		return delimit(content, "**")
+	case "i", "em":
+		return delimit(content, "*")
+	case "s", "del", "strike":
+		return delimit(content, "~~")
+	case "code":
+		if c.pre > 0 {
+			return content
+		}
+		return codeSpan(n.Text())
+	case "pre":
+		return codeBlock(n)
+	case "a":
+		return c.link(n, content)
+	case "img":
+		return image(n)
+	case "br":
+		return "  \n"
+	case "hr":
+		return "\n\n---\n\n"
+	case "input":
+		if strings.EqualFold(n.AttrOrDefault("type", ""), "checkbox") {
+			if n.HasAttr("checked") {
+				return "[x] "
+			}
+			return "[ ] "
+		}
+		return ""
+	case "ul", "ol":
+		content = strings.Trim(content, " \n")
+		if n.Parent != nil && n.Parent.IsTag("li") {
+			// nested lists stay tight
+			return "\n" + content + "\n"
+		}
+		return "\n\n" + content + "\n\n"
+	case "li":
+		marker := "- "
+		if number, ok := c.items[n.Parent]; ok {
+			marker = strconv.Itoa(number) + ". "
+		}
+		return listItem(marker, content)
+	case "blockquote":
+		return "\n\n" + prefixLines(strings.Trim(content, " \n"), "> ", ">") + "\n\n"
+	case "td", "th":
+		if n.ClosestByTag("table") == nil {
+			return content
+		}
+		if c.cells == nil {
+			c.cells = map[*Node]string{}
+		}
+		c.cells[n] = content
+		return ""
+	case "table":
+		return c.table(n, content)
+	}
+	if startsMarkdownBlock(n) {
+		return "\n\n" + strings.Trim(content, " \n") + "\n\n"
+	}
+	return content
+}
+
+// delimit surrounds the given inline content with the given delimiter, leaving
+// its leading and trailing whitespace outside of the delimiters as Markdown
+// requires.
+func delimit(content, delimiter string) string {
+	trimmed := strings.TrimSpace(content)
+	if trimmed == "" {
+		return content
+	}
+	start := strings.Index(content, trimmed)
+	return content[:start] + delimiter + trimmed + delimiter + content[start+len(trimmed):]
+}
+
+// longestRun returns the length of the longest run of the given byte in s.
+func longestRun(s string, c byte) int {
+	longest, run := 0, 0
+	for i := 0; i < len(s); i++ {
+		if s[i] == c {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	return longest
+}
+
+// codeSpan returns the Markdown code span for the given code, delimited by enough
+// backticks not to be closed by those of the code.
+func codeSpan(code string) string {
+	code = strings.ReplaceAll(code, "\n", " ")
+	if code == "" {
+		return ""
+	}
+	fence := strings.Repeat("`", longestRun(code, '`')+1)
+	if strings.HasPrefix(code, "`") || strings.HasSuffix(code, "`") ||
+		strings.HasPrefix(code, " ") && strings.HasSuffix(code, " ") && strings.Trim(code, " ") != "" {
+		// a single space on both sides is removed by Markdown
+		code = " " + code + " "
+	}
+	return fence + code + fence
+}
+
+// codeLanguage returns the language of the given <pre> element, given by a
+// language-x class on itself or on its <code> child.
+func codeLanguage(pre *Node) string {
+	nodes := []*Node{pre}
+	if code := First(Filter(pre.ChildrenSeq(), predicateIsTag("code"))); code != nil {
+		nodes = append(nodes, code)
+	}
+	for _, n := range nodes {
+		for _, class := range n.Classes() {
+			if lang, ok := strings.CutPrefix(class, "language-"); ok {
+				return lang
+			}
+		}
+	}
+	return ""
+}
+
+// codeBlock returns the Markdown fenced code block for the given <pre> element.
+func codeBlock(pre *Node) string {
+	code := strings.TrimSuffix(pre.Text(), "\n")
+	fence := strings.Repeat("`", max(3, longestRun(code, '`')+1))
+	return "\n\n" + fence + codeLanguage(pre) + "\n" + code + "\n" + fence + "\n\n"
+}
+
+// linkDestination returns the given URL as a Markdown link destination.
+func linkDestination(url string) string {
+	if url == "" || strings.ContainsAny(url, " \t\n()<>") {
+		return "<" + strings.NewReplacer("<", `\<`, ">", `\>`, "\n", "").Replace(url) + ">"
+	}
+	return url
+}
+
+// linkTitle returns the given title as a Markdown link title, with a leading
+// space, or an empty string if there is no title.
+func linkTitle(title string) string {
+	if title == "" {
+		return ""
+	}
+	return ` "` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(title) + `"`
+}
+
+func (c *markdownConverter) link(a *Node, content string) string {
+	href := a.AttrOrDefault("href", "")
+	text := strings.TrimSpace(content)
+	if !a.HasAttr("href") || text == "" {
+		return content
+	}
+	start := strings.Index(content, text)
+	target := "(" + linkDestination(href) + linkTitle(a.AttrOrDefault("title", "")) + ")"
+	if c.opts.LinkStyle == ReferenceLinks {
+		definition := linkDestination(href) + linkTitle(a.AttrOrDefault("title", ""))
+		id, ok := c.refIDs[definition]
+		if !ok {
+			if c.refIDs == nil {
+				c.refIDs = map[string]string{}
+			}
+			id = strconv.Itoa(len(c.refs) + 1)
+			c.refIDs[definition] = id
+			c.refs = append(c.refs, "["+id+"]: "+definition)
+		}
+		target = "[" + id + "]"
+	}
+	return content[:start] + "[" + text + "]" + target + content[start+len(text):]
+}
+
+func image(img *Node) string {
+	if !img.HasAttr("src") {
+		return ""
+	}
+	alt := escapeMarkdown(collapseSpace(img.AttrOrDefault("alt", "")), false)
+	return "![" + alt + "](" + linkDestination(img.AttrOrDefault("src", "")) + linkTitle(img.AttrOrDefault("title", "")) + ")"
+}
+
+// prefixLines prefixes each line of s with the given prefix, or with emptyPrefix
+// if the line is empty.
+func prefixLines(s, prefix, emptyPrefix string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if line == "" {
+			lines[i] = emptyPrefix
+		} else {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// countItem keeps the number of the current item of the <ol> elements up to date,
+// as their children are entered.
+func (c *markdownConverter) countItem(n *Node) {
+	list := n.Parent
+	switch {
+	case n.Data == "ol":
+		c.startList(n)
+	case n.Data == "li" && list != nil && list.IsTag("ol"):
+		if _, ok := c.items[list]; !ok {
+			// the conversion started at this item: the previous ones are counted once
+			c.startList(list)
+			for prev := range n.PrevSiblingsSeq() {
+				if prev.IsTag("li") {
+					c.items[list]++
+				}
+			}
+		}
+		c.items[list]++
+	}
+}
+
+// startList records the number before the first item of the given <ol> element.
+func (c *markdownConverter) startList(ol *Node) {
+	start, err := strconv.Atoi(strings.TrimSpace(ol.AttrOrDefault("start", "")))
+	if err != nil {
+		start = 1
+	}
+	if c.items == nil {
+		c.items = map[*Node]int{}
+	}
+	c.items[ol] = start - 1
+}
+
+func listItem(marker, content string) string {
+	content = strings.Trim(content, " \n")
+	// the continuation lines are indented to the content of the first line
+	lines := strings.Split(content, "\n")
+	for i := 1; i < len(lines); i++ {
+		if lines[i] != "" {
+			lines[i] = strings.Repeat(" ", len(marker)) + lines[i]
+		}
+	}
+	return "\n" + marker + strings.Join(lines, "\n") + "\n"
+}
+
+func isTableCell(n *Node) bool {
+	return n.IsTag("td") || n.IsTag("th")
+}
+
+// tableRows returns the rows of the given table, in document order.
+func tableRows(table *Node) []*Node {
+	var rows []*Node
+	for n := range table.ChildrenSeq() {
+		switch {
+		case n.IsTag("tr"):
+			rows = append(rows, n)
+		case n.IsTag("thead") || n.IsTag("tbody") || n.IsTag("tfoot"):
+			for tr := range Filter(n.ChildrenSeq(), predicateIsTag("tr")) {
+				rows = append(rows, tr)
+			}
+		}
+	}
+	return rows
+}
+
+// tableCellEscaper puts the content of a table cell on a single line, and escapes
+// the pipes that would end it.
+var tableCellEscaper = strings.NewReplacer("\n", " ", "|", `\|`)
+
+// table returns the Markdown table for the given <table> element, whose content
+// is the Markdown of its caption, if any.
+func (c *markdownConverter) table(table *Node, content string) string {
+	var rows [][]string
+	var aligns []string
+	for i, tr := range tableRows(table) {
+		var row []string
+		for cell := range tr.ChildrenSeq() {
+			if !isTableCell(cell) {
+				continue
+			}
+			text := strings.Join(strings.Fields(tableCellEscaper.Replace(c.cells[cell])), " ")
+			delete(c.cells, cell)
+			row = append(row, text)
+			if i == 0 {
+				switch strings.ToLower(cell.AttrOrDefault("align", "")) {
+				case "left":
+					aligns = append(aligns, ":---")
+				case "center":
+					aligns = append(aligns, ":---:")
+				case "right":
+					aligns = append(aligns, "---:")
+				default:
+					aligns = append(aligns, "---")
+				}
+			}
+		}
+		rows = append(rows, row)
+	}
+	columns := 0
+	for _, row := range rows {
+		columns = max(columns, len(row))
+	}
+	caption := strings.Trim(content, " \n")
+	if table.Parent != nil && table.Parent.Closest(isTableCell) != nil {
+		// a table cannot be nested in another one, its cells are flattened
+		var cells []string
+		for _, row := range rows {
+			cells = append(cells, row...)
+		}
+		return " " + strings.Join(append([]string{caption}, cells...), " ") + " "
+	}
+	if columns == 0 {
+		return "\n\n" + caption + "\n\n"
+	}
+	for len(aligns) < columns {
+		aligns = append(aligns, "---")
+	}
+	var b strings.Builder
+	b.WriteString("\n\n")
+	if caption != "" {
+		b.WriteString(caption + "\n\n")
+	}
+	writeRow := func(row []string) {
+		b.WriteString("|")
+		for i := range columns {
+			cell := ""
+			if i < len(row) {
+				cell = row[i]
+			}
+			b.WriteString(" " + cell + " |")
+		}
+		b.WriteString("\n")
+	}
+	writeRow(rows[0])
+	writeRow(aligns)
+	for _, row := range rows[1:] {
+		writeRow(row)
+	}
+	b.WriteString("\n")
+	return b.String()
+}
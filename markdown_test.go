@@ -0,0 +1,161 @@
+package gosoup
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func markdownBody(t *testing.T, html string, opts MarkdownOptions) string {
+	doc := parseBody(t, html)
+	body := First(Filter(doc.DescendantNodes(), predicateIsTag("body")))
+	var b bytes.Buffer
+	if err := RenderMarkdown(&b, body, opts); err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
+
+func TestMarkdown(t *testing.T) {
+	cases := []struct {
+		html, markdown string
+	}{
+		{`<h1>Title</h1><h3>Sub <em>title</em></h3><p>Text</p>`, "# Title\n\n### Sub *title*\n\nText\n"},
+		{`<p>  Some
+		   <b>bold</b>,<i> italic </i>and <del>struck</del> text.  </p>`, "Some **bold**, *italic* and ~~struck~~ text.\n"},
+		{`<p>a<br>b</p><hr><div>c<p>d</p>e</div>`, "a  \nb\n\n---\n\nc\n\nd\n\ne\n"},
+		{`<p><b></b><em> </em></p>`, ""},
+		{`<p>Use <code>a</code>, <code>x` + "`" + `y</code> or <code>` + "`" + `z</code>.</p>`, "Use `a`, ``x`y`` or `` `z ``.\n"},
+		{`<pre>line 1
+
+  line 2
+</pre>`, "```\nline 1\n\n  line 2\n```\n"},
+		{`<pre><code class="hl language-go">fmt.Println("` + "```" + `")</code></pre>`, "````go\nfmt.Println(\"```\")\n````\n"},
+		{`<pre class="language-sh">ls *</pre>`, "```sh\nls *\n```\n"},
+		{`<blockquote><p>a</p><blockquote>b</blockquote>c</blockquote>`, "> a\n>\n> > b\n>\n> c\n"},
+		{`<ul><li>a</li><li>b<ul><li>c</li><li>d<ol><li>e</li></ol></li></ul></li></ul>`, "- a\n- b\n  - c\n  - d\n    1. e\n"},
+		{`<ol start="9"><li>a</li><li><p>b</p><p>c</p></li></ol>`, "9. a\n10. b\n\n    c\n"},
+		{`<ol start="x"><li>a</li></ol><ul><li>b</li></ul>`, "1. a\n\n- b\n"},
+		{`<ol><li>a<ol start="5"><li>b</li><li>c</li></ol></li><li>d</li></ol>`, "1. a\n   5. b\n   6. c\n2. d\n"},
+		{`<ul><li><input type="checkbox" checked> done</li><li><input type="checkbox"> todo</li></ul>`, "- [x] done\n- [ ] todo\n"},
+		{`<ul><li><pre>code</pre></li></ul>`, "- ```\n  code\n  ```\n"},
+		{`<p><img src="a.png" alt="An [image]" title="Title"><img alt="no source"></p>`, "![An \\[image\\]](a.png \"Title\")\n"},
+		{`<script>alert(1)</script><style>p {}</style><!-- comment --><template>t</template>text`, "text\n"},
+	}
+	for _, c := range cases {
+		assertEqualsWithMsg(t, c.markdown, markdownBody(t, c.html, MarkdownOptions{}), c.html, ":\n", markdownBody(t, c.html, MarkdownOptions{}))
+	}
+}
+
+func TestMarkdownEscaping(t *testing.T) {
+	cases := []struct {
+		html, markdown string
+	}{
+		{`<p>*a* _b_ ` + "`c`" + ` [d](e) ~~f~~ &lt;g&gt; \h</p>`, "\\*a\\* \\_b\\_ \\`c\\` \\[d\\](e) \\~\\~f\\~\\~ \\<g> \\\\h\n"},
+		{`<p>&amp;amp; &amp;#39; a & b</p>`, "\\&amp; \\&#39; a & b\n"},
+		{`<p># not a heading</p><p>- not a list</p><p>1. not a list</p><p>2) neither</p><p>> nor a quote</p>`,
+			"\\# not a heading\n\n\\- not a list\n\n1\\. not a list\n\n2\\) neither\n\n\\> nor a quote\n"},
+		{`<p>a # b - c 1. d</p>`, "a # b - c 1. d\n"},
+		{`<p><em>a</em>1. b</p>`, "*a*1. b\n"},
+		{`a<div>- b</div><span>c<b>- d</b></span>`, "a\n\n\\- b\n\nc**- d**\n"},
+		{`<p>Wow!<a href="x">link</a></p>`, "Wow\\![link](x)\n"},
+		{`<p><i>Hey!</i><a href="x">y</a> and <span>wow!</span><span><a href="x">link</a></span> ![not](an image)</p>`,
+			"*Hey!*[y](x) and wow\\![link](x) !\\[not\\](an image)\n"},
+	}
+	for _, c := range cases {
+		assertEqualsWithMsg(t, c.markdown, markdownBody(t, c.html, MarkdownOptions{}), c.html, ":\n", markdownBody(t, c.html, MarkdownOptions{}))
+	}
+}
+
+func TestMarkdownLinks(t *testing.T) {
+	html := `<p><a href="https://example.com">Example</a>, <a href="/a b" title='say "hi"'> spaced </a>,` +
+		` <a href="https://example.com">again</a>, <a name="x">anchor</a> and <a href="/empty"></a>.</p>`
+
+	assertEquals(t, `[Example](https://example.com), [spaced](</a b> "say \"hi\"") ,`+
+		` [again](https://example.com), anchor and .`+"\n", markdownBody(t, html, MarkdownOptions{}))
+
+	assertEquals(t, `[Example][1], [spaced][2] , [again][1], anchor and .`+"\n\n"+
+		"[1]: https://example.com\n"+
+		`[2]: </a b> "say \"hi\""`+"\n", markdownBody(t, html, MarkdownOptions{LinkStyle: ReferenceLinks}))
+
+	assertEquals(t, "[![logo](logo.png)](/)\n", markdownBody(t, `<a href="/"><img src="logo.png" alt="logo"></a>`, MarkdownOptions{}))
+}
+
+func TestMarkdownTables(t *testing.T) {
+	html := `<table>
+		<caption>Results</caption>
+		<thead><tr><th align="left">Name</th><th align="center">Score</th><th align="right">Rank</th></tr></thead>
+		<tbody>
+			<tr><td><b>Alice</b></td><td>1 | 2</td><td>1</td></tr>
+			<tr><td>Bob<br>Jr</td><td>3</td></tr>
+		</tbody>
+	</table>`
+	assertEquals(t, "Results\n\n"+
+		"| Name | Score | Rank |\n"+
+		"| :--- | :---: | ---: |\n"+
+		"| **Alice** | 1 \\| 2 | 1 |\n"+
+		"| Bob Jr | 3 |  |\n", markdownBody(t, html, MarkdownOptions{}))
+
+	// the first row is the header, even without <th> cells
+	assertEquals(t, "a\n\n| b | c |\n| --- | --- |\n| d | e |\n\nf\n",
+		markdownBody(t, `a<table><tr><td>b</td><td>c</td></tr><tr><td>d</td><td>e</td></tr></table>f`, MarkdownOptions{}))
+
+	// nested tables are flattened in their cell
+	assertEquals(t, "| a |\n| --- |\n| b c |\n",
+		markdownBody(t, `<table><tr><td>a</td></tr><tr><td><table><tr><td>b</td><td>c</td></tr></table></td></tr></table>`, MarkdownOptions{}))
+}
+
+func TestMarkdownHooks(t *testing.T) {
+	opts := MarkdownOptions{Hooks: map[string]MarkdownHook{
+		"mark": func(n *Node, content string) (string, bool) {
+			return "==" + content + "==", true
+		},
+		"aside": func(n *Node, content string) (string, bool) {
+			return "", true
+		},
+		"h2": func(n *Node, content string) (string, bool) {
+			// setext headings for titles on a single line only
+			if strings.Contains(content, "\n") {
+				return "", false
+			}
+			return "\n\n" + content + "\n" + strings.Repeat("-", len(content)) + "\n\n", true
+		},
+		"pre": func(n *Node, content string) (string, bool) {
+			if !n.HasClass("indented") {
+				return "", false
+			}
+			return "\n\n" + prefixLines(content, "    ", "") + "\n\n", true
+		},
+	}}
+	html := `<h2>Title</h2><h2>Multi<br>line</h2><p>Some <mark>marked</mark> text</p><aside>ignored</aside>` +
+		`<pre class="indented"><code>a *
+b</code></pre><pre>c</pre>`
+	assertEquals(t, "Title\n-----\n\n## Multi line\n\nSome ==marked== text\n\n    a *\n    b\n\n```\nc\n```\n",
+		markdownBody(t, html, opts))
+}
+
+func TestMarkdownNodes(t *testing.T) {
+	doc := parseBody(t, `<!DOCTYPE html><title>Title</title><p>a <b>b</b></p>`)
+	assertEquals(t, "a **b**\n", doc.Markdown())
+	b := First(Filter(doc.DescendantNodes(), predicateIsTag("b")))
+	assertEquals(t, "**b**\n", b.Markdown())
+	assertEquals(t, "b\n", b.FirstChild.Markdown())
+	assertEquals(t, "", (&Node{Type: ElementNode, Data: "div"}).Markdown())
+
+	// list items keep their number
+	items := parseBody(t, `<ol start="3"><li>a</li><script></script><li>b</li></ol>`)
+	li := First(Filter(items.DescendantNodes(), predicateIsTag("li")))
+	assertEquals(t, "4. b\n", li.NextSibling.NextSibling.Markdown())
+
+	// orphan table cells keep their content
+	td := &Node{Type: ElementNode, Data: "td"}
+	td.AppendChild(&Node{Type: TextNode, Data: "cell"})
+	assertEquals(t, "cell\n", td.Markdown())
+}
+
+func TestMarkdownDeepTree(t *testing.T) {
+	depth := 100000
+	html := strings.Repeat("<div><span>", depth) + "x"
+	root := First(NewStream(strings.NewReader(html)).SubtreesByTag("div"))
+	assertEquals(t, "x\n", root.Markdown())
+}
@@ -0,0 +1,134 @@
+package gosoup
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"strings"
+)
+
+var (
+	// ErrNoHead is returned when the document has no <head> element.
+	ErrNoHead = errors.New("gosoup: no head element in the document")
+	// ErrNoContentType is returned when the <head> of the document declares no
+	// content type.
+	ErrNoContentType = errors.New("gosoup: no content type declared in the document head")
+	// ErrNoCharset is returned when the <head> of the document declares no
+	// charset.
+	ErrNoCharset = errors.New("gosoup: no charset declared in the document head")
+)
+
+// ContentType is a media type with its parameters, as found in a Content-Type
+// HTTP header or in the equivalent <meta> element.
+type ContentType struct {
+	// MediaType is the lowercase type and subtype, such as "text/html".
+	MediaType string
+	// Params holds the parameters of the media type, with lowercase names and
+	// unquoted values.
+	Params map[string]string
+}
+
+// ParseContentType parses a Content-Type value as defined by RFC 7231, such as
+// `text/html; charset="UTF-8"`.
+func ParseContentType(s string) (ContentType, error) {
+	mediaType, params, err := mime.ParseMediaType(s)
+	if err != nil {
+		return ContentType{}, fmt.Errorf("ParseContentType: %w", err)
+	}
+	return ContentType{MediaType: mediaType, Params: params}, nil
+}
+
+// Charset returns the charset parameter of this content type, or an empty string
+// if it has none.
+func (c ContentType) Charset() string {
+	return c.Params["charset"]
+}
+
+// String formats this content type as a Content-Type value, quoting the
+// parameter values if needed.
+func (c ContentType) String() string {
+	return mime.FormatMediaType(c.MediaType, c.Params)
+}
+
+// GetDocContentType returns the content-type string description taken from a
+// <meta> element in the <head> part of the HTML tree. Both the
+// <meta http-equiv="Content-Type" content="..."> form and the HTML5
+// <meta charset="..."> form are supported, the latter being equivalent to
+// "text/html; charset=...". The first of these elements in document order is
+// used.
+//
+// It returns ErrNoHead if the document has no <head>, and ErrNoContentType if
+// none of these elements is found.
+func GetDocContentType(node *Node) (string, error) {
+	metas, err := headMetas(node)
+	if err != nil {
+		return "", err
+	}
+	for _, meta := range metas {
+		if charset := metaCharset(meta); charset != "" {
+			return mime.FormatMediaType("text/html", map[string]string{"charset": charset}), nil
+		}
+		if content, ok := metaContentType(meta); ok {
+			return content, nil
+		}
+	}
+	return "", ErrNoContentType
+}
+
+// GetDocCharset returns the charset declared by a <meta> element in the <head>
+// part of the HTML tree, either via its charset attribute, or via the charset
+// parameter of its content, if it is a <meta http-equiv="Content-Type">. The
+// first element declaring a charset in document order is used.
+//
+// It returns ErrNoHead if the document has no <head>, and ErrNoCharset if no
+// charset is declared.
+func GetDocCharset(node *Node) (string, error) {
+	metas, err := headMetas(node)
+	if err != nil {
+		return "", err
+	}
+	for _, meta := range metas {
+		if charset := metaCharset(meta); charset != "" {
+			return charset, nil
+		}
+		if content, ok := metaContentType(meta); ok {
+			contentType, err := ParseContentType(content)
+			if err == nil && contentType.Charset() != "" {
+				return contentType.Charset(), nil
+			}
+		}
+	}
+	return "", ErrNoCharset
+}
+
+// headMetas returns the <meta> elements of the <head> of the document containing
+// the given node.
+func headMetas(node *Node) ([]*Node, error) {
+	root := node.Root() // moves to the document node
+	head := First(Filter(root.DescendantNodes(), predicateIsTag("head")))
+	if head == nil {
+		return nil, ErrNoHead
+	}
+	var metas []*Node
+	for n := range Filter(head.DescendantNodes(), predicateIsTag("meta")) {
+		metas = append(metas, n)
+	}
+	return metas, nil
+}
+
+// metaCharset returns the value of the charset attribute of the given <meta>
+// element, or an empty string if it has none.
+func metaCharset(meta *Node) string {
+	charset, _ := lookupAttr(meta, "charset")
+	return strings.TrimFunc(charset, isHTMLSpace)
+}
+
+// metaContentType returns the content of the given <meta> element if it is a
+// <meta http-equiv="Content-Type">.
+func metaContentType(meta *Node) (string, bool) {
+	httpEquiv, _ := lookupAttr(meta, "http-equiv")
+	if !strings.EqualFold(strings.TrimFunc(httpEquiv, isHTMLSpace), "content-type") {
+		return "", false
+	}
+	return lookupAttr(meta, "content")
+}
@@ -0,0 +1,79 @@
+package gosoup
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseContentType(t *testing.T) {
+	contentType, err := ParseContentType(`Text/HTML; Charset="UTF-8"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, "text/html", contentType.MediaType)
+	assertEquals(t, "UTF-8", contentType.Charset())
+	assertEquals(t, "text/html; charset=UTF-8", contentType.String())
+
+	contentType, err = ParseContentType("text/plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, "", contentType.Charset())
+
+	_, err = ParseContentType("text/html; charset")
+	assert(t, err != nil, "expected an error for an invalid parameter")
+}
+
+func TestGetDocCharset(t *testing.T) {
+	tests := []struct {
+		head        string
+		contentType string
+		charset     string
+		err         error
+	}{
+		{`<meta charset="utf-8">`, "text/html; charset=utf-8", "utf-8", nil},
+		{`<meta CHARSET=" Shift_JIS ">`, "text/html; charset=Shift_JIS", "Shift_JIS", nil},
+		{`<meta http-equiv="Content-Type" content="text/html; charset=ISO-8859-1">`, "text/html; charset=ISO-8859-1", "ISO-8859-1", nil},
+		{`<meta http-equiv="content-type" content='text/html; Charset="UTF-8"'>`, `text/html; Charset="UTF-8"`, "UTF-8", nil},
+		{`<meta http-equiv="Content-Type" content="text/html">`, "text/html", "", ErrNoCharset},
+		{`<meta name="description" content="charset=utf-8"><meta charset="latin1">`, "text/html; charset=latin1", "latin1", nil},
+		{`<meta http-equiv="Content-Type" content="text/html"><meta charset="latin1">`, "text/html", "latin1", nil},
+		{`<meta name="viewport" content="width=device-width">`, "", "", ErrNoContentType},
+	}
+	for _, test := range tests {
+		doc := parseBody(t, "<html><head>"+test.head+"</head><body><p>text</p></body></html>")
+		p := doc.DescendantsByTag("p").First()
+
+		contentType, err := GetDocContentType(p)
+		if test.err == ErrNoContentType {
+			assert(t, errors.Is(err, ErrNoContentType), test.head, ": expected ErrNoContentType, got ", err)
+		} else {
+			assert(t, err == nil, test.head, ": unexpected error ", err)
+			assertEqualsWithMsg(t, test.contentType, contentType, test.head, ": got content type ", contentType)
+		}
+
+		charset, err := GetDocCharset(p)
+		if test.err != nil {
+			assert(t, errors.Is(err, ErrNoCharset), test.head, ": expected ErrNoCharset, got ", err)
+		} else {
+			assert(t, err == nil, test.head, ": unexpected error ", err)
+			assertEqualsWithMsg(t, test.charset, charset, test.head, ": got charset ", charset)
+		}
+	}
+}
+
+func TestGetDocCharsetWithoutHead(t *testing.T) {
+	doc, err := Parse(strings.NewReader("<p>text</p>"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the parser always creates a head element
+	_, err = GetDocCharset(doc)
+	assert(t, errors.Is(err, ErrNoCharset), "expected ErrNoCharset, got ", err)
+
+	_, err = GetDocCharset(&Node{Type: ElementNode, Data: "p"})
+	assert(t, errors.Is(err, ErrNoHead), "expected ErrNoHead, got ", err)
+	_, err = GetDocContentType(&Node{Type: ElementNode, Data: "p"})
+	assert(t, errors.Is(err, ErrNoHead), "expected ErrNoHead, got ", err)
+}
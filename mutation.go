@@ -0,0 +1,207 @@
+package gosoup
+
+// The mutation functions below keep the links of the tree consistent: a node is
+// always among the children of its Parent, and its siblings are linked both ways.
+//
+// Inserting a node that is already part of a tree moves it: it is first detached
+// from its current position, which may be in another document. These functions
+// panic if the operation would create a cycle, or if the new parent cannot have
+// children.
+
+// checkInsert panics if child cannot be inserted as a child of parent.
+func checkInsert(funcName string, parent, child *Node) {
+	if child == nil {
+		panic(funcName + ": nil child node")
+	}
+	switch parent.Type {
+	case TextNode, CommentNode, DoctypeNode:
+		panic(funcName + ": parent node cannot have children")
+	}
+	if child.Type == DocumentNode {
+		panic(funcName + ": node cannot be inserted as a child")
+	}
+	for p := parent; p != nil; p = p.Parent {
+		if p == child {
+			panic(funcName + ": node is an ancestor of the parent, this would create a cycle")
+		}
+	}
+}
+
+// link inserts child (which must be detached) between prev and next, as a child of
+// this node. Either prev or next may be nil.
+func (node *Node) link(child, prev, next *Node) {
+	child.Parent = node
+	child.PrevSibling = prev
+	child.NextSibling = next
+	if prev != nil {
+		prev.NextSibling = child
+	} else {
+		node.FirstChild = child
+	}
+	if next != nil {
+		next.PrevSibling = child
+	} else {
+		node.LastChild = child
+	}
+}
+
+// Detach removes this node from its parent and siblings. The node keeps its own
+// children. Detaching a node that is not attached does nothing.
+func (node *Node) Detach() {
+	if p := node.Parent; p != nil {
+		if p.FirstChild == node {
+			p.FirstChild = node.NextSibling
+		}
+		if p.LastChild == node {
+			p.LastChild = node.PrevSibling
+		}
+	}
+	if node.PrevSibling != nil {
+		node.PrevSibling.NextSibling = node.NextSibling
+	}
+	if node.NextSibling != nil {
+		node.NextSibling.PrevSibling = node.PrevSibling
+	}
+	node.Parent = nil
+	node.PrevSibling = nil
+	node.NextSibling = nil
+}
+
+// AppendChild adds the given node as the last child of this node, moving it from
+// its current position if it is already attached.
+func (node *Node) AppendChild(child *Node) {
+	checkInsert("AppendChild", node, child)
+	child.Detach()
+	node.link(child, node.LastChild, nil)
+}
+
+// PrependChild adds the given node as the first child of this node, moving it
+// from its current position if it is already attached.
+func (node *Node) PrependChild(child *Node) {
+	checkInsert("PrependChild", node, child)
+	child.Detach()
+	node.link(child, nil, node.FirstChild)
+}
+
+// InsertBefore inserts newChild as a child of this node, immediately before
+// oldChild, moving it from its current position if it is already attached. If
+// oldChild is nil, newChild is added as the last child.
+//
+// This function panics if oldChild is not nil and is not a child of this node.
+func (node *Node) InsertBefore(newChild, oldChild *Node) {
+	if oldChild == nil {
+		node.AppendChild(newChild)
+		return
+	}
+	if oldChild.Parent != node {
+		panic("InsertBefore: reference node is not a child of this node")
+	}
+	checkInsert("InsertBefore", node, newChild)
+	if newChild == oldChild {
+		return
+	}
+	newChild.Detach()
+	node.link(newChild, oldChild.PrevSibling, oldChild)
+}
+
+// InsertAfter inserts newChild as a child of this node, immediately after
+// oldChild, moving it from its current position if it is already attached. If
+// oldChild is nil, newChild is added as the first child.
+//
+// This function panics if oldChild is not nil and is not a child of this node.
+func (node *Node) InsertAfter(newChild, oldChild *Node) {
+	if oldChild == nil {
+		node.PrependChild(newChild)
+		return
+	}
+	if oldChild.Parent != node {
+		panic("InsertAfter: reference node is not a child of this node")
+	}
+	checkInsert("InsertAfter", node, newChild)
+	if newChild == oldChild {
+		return
+	}
+	newChild.Detach()
+	node.link(newChild, oldChild, oldChild.NextSibling)
+}
+
+// RemoveChild removes the given child from this node.
+//
+// This function panics if the given node is not a child of this node.
+func (node *Node) RemoveChild(child *Node) {
+	if child == nil || child.Parent != node {
+		panic("RemoveChild: node is not a child of this node")
+	}
+	child.Detach()
+}
+
+// RemoveChildren removes all the children of this node.
+func (node *Node) RemoveChildren() {
+	for node.FirstChild != nil {
+		node.FirstChild.Detach()
+	}
+}
+
+// ReplaceWith puts the given node at the position of this node in the tree, and
+// detaches this node. The replacement is moved from its current position if it
+// is already attached.
+//
+// This function panics if this node has no parent.
+func (node *Node) ReplaceWith(replacement *Node) {
+	parent := node.Parent
+	if parent == nil {
+		panic("ReplaceWith: node has no parent")
+	}
+	checkInsert("ReplaceWith", parent, replacement)
+	if replacement == node {
+		return
+	}
+	parent.InsertBefore(replacement, node)
+	node.Detach()
+}
+
+// NormalizeWhitespace rewrites the text nodes of the subtree of this node: blank
+// text nodes are removed, and leading and trailing whitespace is trimmed from the
+// other ones. The resulting tree contains the nodes provided by Descendants, with
+// the data given by TrimmedData.
+//
+// Note that this changes the rendering of the tree, since whitespace between
+// inline elements is significant in HTML.
+func (node *Node) NormalizeWhitespace() {
+	var blanks []*Node
+	for n := range node.All() {
+		if n.IsBlankText() && n != node {
+			blanks = append(blanks, n)
+		} else {
+			n.TrimTextData()
+		}
+	}
+	for _, n := range blanks {
+		n.Detach()
+	}
+}
+
+// Detach detaches all the nodes of this iterator from their tree, and returns them.
+//
+// The nodes are all read before any of them is detached, so that the tree is not
+// modified while the iterator is running.
+func (i NodeIterator) Detach() []*Node {
+	nodes := i.All()
+	for _, n := range nodes {
+		n.Detach()
+	}
+	return nodes
+}
+
+// AppendTo moves all the nodes of this iterator to the end of the children of the
+// given parent, in order, and returns them.
+//
+// The nodes are all read before any of them is moved, so that the tree is not
+// modified while the iterator is running.
+func (i NodeIterator) AppendTo(parent *Node) []*Node {
+	nodes := i.All()
+	for _, n := range nodes {
+		parent.AppendChild(n)
+	}
+	return nodes
+}
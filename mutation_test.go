@@ -0,0 +1,153 @@
+package gosoup
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// assertConsistent checks the links of all the nodes of the given tree.
+func assertConsistent(t *testing.T, root *Node) {
+	for n := range root.All() {
+		var prev *Node
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			assert(t, c.Parent == n, "bad parent link for ", c.Data)
+			assert(t, c.PrevSibling == prev, "bad previous sibling link for ", c.Data)
+			prev = c
+		}
+		assert(t, n.LastChild == prev, "bad last child link for ", n.Data)
+	}
+}
+
+func renderBody(t *testing.T, doc *Node) string {
+	var b bytes.Buffer
+	body := First(Filter(doc.DescendantNodes(), predicateIsTag("body")))
+	for c := range body.ChildNodes() {
+		if err := Render(&b, c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return b.String()
+}
+
+func parseBody(t *testing.T, html string) *Node {
+	doc, err := Parse(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func byID(doc *Node, id string) *Node {
+	return First(Filter(doc.DescendantNodes(), func(n *Node) bool {
+		return n.AttrOrDefault("id", "") == id
+	}))
+}
+
+func TestMutations(t *testing.T) {
+	doc := parseBody(t, `<div id="a"><p id="p1"></p><p id="p2"></p></div><div id="b"></div>`)
+	a, b := byID(doc, "a"), byID(doc, "b")
+	p1, p2 := byID(doc, "p1"), byID(doc, "p2")
+
+	b.AppendChild(p1)
+	assertConsistent(t, doc)
+	assertEquals(t, `<div id="a"><p id="p2"></p></div><div id="b"><p id="p1"></p></div>`, renderBody(t, doc))
+
+	b.PrependChild(p2)
+	assertConsistent(t, doc)
+	assertEquals(t, `<div id="a"></div><div id="b"><p id="p2"></p><p id="p1"></p></div>`, renderBody(t, doc))
+
+	span := &Node{Type: ElementNode, Data: "span"}
+	b.InsertBefore(span, p1)
+	assertConsistent(t, doc)
+	assertEquals(t, `<div id="a"></div><div id="b"><p id="p2"></p><span></span><p id="p1"></p></div>`, renderBody(t, doc))
+
+	b.InsertAfter(p2, p1)
+	assertConsistent(t, doc)
+	assertEquals(t, `<div id="a"></div><div id="b"><span></span><p id="p1"></p><p id="p2"></p></div>`, renderBody(t, doc))
+
+	b.InsertBefore(span, span)
+	b.InsertAfter(span, nil)
+	b.InsertBefore(a, nil)
+	assertConsistent(t, doc)
+	assertEquals(t, `<div id="b"><span></span><p id="p1"></p><p id="p2"></p><div id="a"></div></div>`, renderBody(t, doc))
+
+	p1.ReplaceWith(&Node{Type: TextNode, Data: "text"})
+	assertConsistent(t, doc)
+	assert(t, p1.Parent == nil && p1.PrevSibling == nil && p1.NextSibling == nil, "replaced node still attached")
+	assertEquals(t, `<div id="b"><span></span>text<p id="p2"></p><div id="a"></div></div>`, renderBody(t, doc))
+
+	b.RemoveChild(span)
+	a.Detach()
+	a.Detach()
+	assertConsistent(t, doc)
+	assertEquals(t, `<div id="b">text<p id="p2"></p></div>`, renderBody(t, doc))
+
+	b.RemoveChildren()
+	assertConsistent(t, doc)
+	assertEquals(t, `<div id="b"></div>`, renderBody(t, doc))
+}
+
+func TestMoveBetweenDocuments(t *testing.T) {
+	doc1 := parseBody(t, `<ul id="l1"><li id="i1">1</li><li id="i2">2</li></ul>`)
+	doc2 := parseBody(t, `<ul id="l2"></ul>`)
+
+	byID(doc2, "l2").AppendChild(byID(doc1, "i2"))
+	assertConsistent(t, doc1)
+	assertConsistent(t, doc2)
+	assertEquals(t, `<ul id="l1"><li id="i1">1</li></ul>`, renderBody(t, doc1))
+	assertEquals(t, `<ul id="l2"><li id="i2">2</li></ul>`, renderBody(t, doc2))
+	assert(t, byID(doc2, "i2").Root() == doc2, "moved node has the wrong root")
+}
+
+func TestIteratorBulkMutations(t *testing.T) {
+	doc := parseBody(t, `<div id="a"><p>1</p><script>x</script><p>2</p><script>y</script></div><div id="b"></div>`)
+
+	removed := doc.DescendantsByTag("script").Detach()
+	assertEquals(t, 2, len(removed))
+	assertConsistent(t, doc)
+	assertEquals(t, `<div id="a"><p>1</p><p>2</p></div><div id="b"></div>`, renderBody(t, doc))
+
+	moved := byID(doc, "a").ChildrenByTag("p").AppendTo(byID(doc, "b"))
+	assertEquals(t, 2, len(moved))
+	assertConsistent(t, doc)
+	assertEquals(t, `<div id="a"></div><div id="b"><p>1</p><p>2</p></div>`, renderBody(t, doc))
+}
+
+func assertPanics(t *testing.T, f func(), msg ...interface{}) {
+	defer func() {
+		assert(t, recover() != nil, msg...)
+	}()
+	f()
+}
+
+func TestMutationPanics(t *testing.T) {
+	doc := parseBody(t, `<div id="a"><div id="b"><p id="c">text</p></div></div>`)
+	a, b, c := byID(doc, "a"), byID(doc, "b"), byID(doc, "c")
+	text := c.FirstChild
+
+	assertPanics(t, func() { b.AppendChild(a) }, "expected a panic for a cycle")
+	assertPanics(t, func() { c.PrependChild(c) }, "expected a panic for a self cycle")
+	assertPanics(t, func() { b.ReplaceWith(a) }, "expected a panic for a cycle")
+	assertPanics(t, func() { text.AppendChild(&Node{Type: ElementNode, Data: "b"}) }, "expected a panic for a text parent")
+	assertPanics(t, func() { a.AppendChild(doc) }, "expected a panic for a document child")
+	assertPanics(t, func() { a.AppendChild(nil) }, "expected a panic for a nil child")
+	assertPanics(t, func() { a.InsertBefore(&Node{}, c) }, "expected a panic for a non-child reference")
+	assertPanics(t, func() { a.RemoveChild(c) }, "expected a panic for a non-child")
+	assertPanics(t, func() { doc.ReplaceWith(&Node{}) }, "expected a panic for a parentless node")
+	assertConsistent(t, doc)
+}
+
+func TestNormalizeWhitespace(t *testing.T) {
+	doc := parseBody(t, "<div id=\"a\">\n  <p>  one  </p>\n  <p>two <b>three</b></p>\n</div>")
+	a := byID(doc, "a")
+	var expected []string
+	for n := range a.DescendantsSeq() {
+		expected = append(expected, n.TrimmedData())
+	}
+
+	a.NormalizeWhitespace()
+	assertConsistent(t, doc)
+	assertEquals(t, `<div id="a"><p>one</p><p>two<b>three</b></p></div>`, render(t, a))
+	assertDatas(t, expected, datas(a.DescendantNodes()))
+}
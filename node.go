@@ -7,7 +7,8 @@ import (
 )
 
 const (
-	blank string = " \t\n\r"
+	// blank is the ASCII whitespace as defined by the HTML spec, see isHTMLSpace.
+	blank string = " \t\n\f\r"
 )
 
 type Attribute html.Attribute
@@ -23,6 +24,12 @@ const (
 	DoctypeNode
 )
 
+// Node is a node of a parse tree.
+//
+// It has the same fields as html.Node, in the same order, so that both types have
+// the same memory layout and a tree can be viewed as one type or the other without
+// copying it, see WrapTree. No other field may be added to it: the data attached
+// to nodes, like their positions, is stored outside of them.
 type Node struct {
 	Parent, FirstChild, LastChild, PrevSibling, NextSibling *Node
 
@@ -41,6 +48,20 @@ func (node *Node) Root() *Node {
 	return node
 }
 
+// Depth returns the number of ancestors of this node: 0 for the root of a tree, 1
+// for its children, and so on.
+//
+// Depth walks up to the root, which takes a time proportional to the depth of the
+// node. To get the depth of all the nodes of a tree, DescendantNodesByDepth
+// provides it while traversing them.
+func (node *Node) Depth() int {
+	depth := 0
+	for n := node.Parent; n != nil; n = n.Parent {
+		depth++
+	}
+	return depth
+}
+
 // HasAttr returns true if this node has the specified attribute.
 func (node *Node) HasAttr(attrKey string) bool {
 	for _, a := range node.Attrs {
@@ -60,7 +81,7 @@ func (node *Node) Attr(attrKey string) string {
 			return a.Val
 		}
 	}
-	panic("no such attribute '" + attrKey + "'")
+	panic("no such attribute '" + attrKey + "' on " + describeNode(node))
 }
 
 // AttrOrDefault returns the value of the given attribute, or defaultValue if this
@@ -85,6 +106,16 @@ func (node *Node) IsTag(name string) bool {
 	return node.Type == ElementNode && node.Data == name
 }
 
+// TrimmedData returns the data of this node, without leading and trailing
+// whitespace if this node is a TextNode. Unlike TrimTextData, it does not modify
+// the node.
+func (node *Node) TrimmedData() string {
+	if node.Type == TextNode {
+		return strings.Trim(node.Data, blank)
+	}
+	return node.Data
+}
+
 // TrimTextData trims leading and trailing whitespace if this node is a TextNode.
 func (node *Node) TrimTextData() {
 	if node.Type == TextNode {
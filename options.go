@@ -0,0 +1,247 @@
+package gosoup
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+)
+
+var (
+	// ErrMaxDepth is returned by ParseWithOptions when the tree is deeper than
+	// allowed by Options.MaxDepth.
+	ErrMaxDepth = errors.New("gosoup: maximum tree depth exceeded")
+	// ErrMaxNodes is returned by ParseWithOptions when the tree has more nodes
+	// than allowed by Options.MaxNodes.
+	ErrMaxNodes = errors.New("gosoup: maximum number of nodes exceeded")
+)
+
+// Options configures ParseWithOptions. The zero value parses the same way as
+// Parse, and new fields are always added so that their zero value keeps the
+// existing behaviour.
+type Options struct {
+	// DropComments removes the comment nodes from the tree. They are dropped as
+	// the tree is built, so that the text on both sides of a comment ends up in a
+	// single text node.
+	DropComments bool
+	// DropBlankText removes the text nodes made only of whitespace from the tree,
+	// including those inside <pre> elements, once the tree is built.
+	DropBlankText bool
+	// PreserveAttrCase keeps the attribute names of HTML elements as they are
+	// written in the source, instead of lowercasing them. This is a best effort:
+	// the attributes of elements created by the parser itself, such as an implied
+	// <tbody>, are still lowercase.
+	PreserveAttrCase bool
+	// MaxDepth is the maximum depth of the nodes of the tree, the document node
+	// being at depth 0 and the <html> element at depth 1. Zero means no limit.
+	MaxDepth int
+	// MaxNodes is the maximum number of nodes created by the parser, including
+	// the document node and excluding the dropped nodes. Zero means no limit.
+	MaxNodes int
+	// RecordPositions records the position of each node in the source, which is
+	// then available via Node.Position and included in error messages. It makes
+	// parsing slower and uses more memory.
+	RecordPositions bool
+}
+
+// ParseWithOptions returns the parse tree for the HTML from the given Reader,
+// configured by the given options. The input is assumed to be UTF-8 encoded.
+//
+// ParseWithOptions returns ErrMaxDepth or ErrMaxNodes if the tree exceeds the
+// limits given in the options. These limits are checked while the tree is built,
+// which stops as soon as one is exceeded. The input is read as it is parsed, but
+// its size is not limited, nor the size of each node: reading untrusted input
+// should also be bounded, for instance with io.LimitReader.
+func ParseWithOptions(r io.Reader, opts Options) (*Node, error) {
+	doc, err := newParser(r, opts).parseDocument()
+	if err != nil {
+		return nil, err
+	}
+	if opts.MaxDepth > 0 {
+		if err := checkTreeDepth(doc, opts.MaxDepth); err != nil {
+			return nil, err
+		}
+	}
+	return doc, nil
+}
+
+// skip returns true if the given node is to be dropped according to the options.
+func skip(n *Node, opts Options) bool {
+	switch n.Type {
+	case CommentNode:
+		return opts.DropComments
+	case TextNode:
+		return opts.DropBlankText && strings.Trim(n.Data, blank) == ""
+	}
+	return false
+}
+
+// countNode counts n, a node created by the parser, unless it is to be dropped,
+// and records ErrMaxNodes if there are too many nodes.
+func (p *parser) countNode(n *Node) {
+	if p.opts.MaxNodes <= 0 || skip(n, p.opts) {
+		return
+	}
+	p.nodes++
+	if p.nodes > p.opts.MaxNodes && p.err == nil {
+		p.err = ErrMaxNodes
+	}
+}
+
+// checkDepth records ErrMaxDepth if n, a node inserted in the tree, is too deep.
+// Only the ancestors up to the maximum depth are visited.
+func (p *parser) checkDepth(n *Node) {
+	if p.opts.MaxDepth <= 0 {
+		return
+	}
+	depth := 0
+	for ancestor := n.Parent; ancestor != nil; ancestor = ancestor.Parent {
+		depth++
+		if depth > p.opts.MaxDepth {
+			if p.err == nil {
+				p.err = ErrMaxDepth
+			}
+			return
+		}
+	}
+}
+
+// keepBlankText remembers n, a text node inserted in the tree, if it is to be
+// dropped. Unlike comments, blank text cannot be dropped as soon as it is
+// created: more text may be appended to it, or it may be moved along with its
+// siblings before that, so it is only dropped by dropBlankText.
+func (p *parser) keepBlankText(n *Node) {
+	if skip(n, p.opts) {
+		p.blankText = append(p.blankText, n)
+	}
+}
+
+// dropBlankText removes the text nodes remembered by keepBlankText which are
+// still blank, once the tree is built.
+func (p *parser) dropBlankText() {
+	for _, n := range p.blankText {
+		if skip(n, p.opts) {
+			n.Detach()
+			delete(p.positions, n)
+		}
+	}
+	p.blankText = nil
+}
+
+// checkTreeDepth returns ErrMaxDepth if a node of the tree of doc is deeper than
+// maxDepth. The parser checks the depth of the nodes as it inserts them, but it
+// can increase it when it moves nodes, for instance to fix misnested formatting
+// elements.
+func checkTreeDepth(doc *Node, maxDepth int) error {
+	depth := 0 // the depth of the next node to visit
+	return walk(doc, func(n *Node) (bool, error) {
+		if depth > maxDepth {
+			return false, ErrMaxDepth
+		}
+		depth++
+		return true, nil
+	}, func(n *Node) error {
+		depth--
+		return nil
+	})
+}
+
+// restoreAttrCase gives their original case back to the attribute names of the
+// given element.
+func restoreAttrCase(n *Node, names []string) {
+	for i, a := range n.Attrs {
+		if a.Namespace != "" {
+			continue
+		}
+		for _, name := range names {
+			if strings.EqualFold(name, a.Key) {
+				n.Attrs[i].Key = name
+				break
+			}
+		}
+	}
+}
+
+// rawTagNameEnd returns the index of the end of the tag name in the given raw
+// start tag, following the rules of the html tokenizer.
+func rawTagNameEnd(raw []byte) int {
+	for i := 1; i < len(raw); i++ {
+		switch raw[i] {
+		case ' ', '\n', '\r', '\t', '\f', '/', '>':
+			return i
+		}
+	}
+	return len(raw)
+}
+
+// rawAttrNames returns the attribute names found in the given raw attributes of a
+// start tag, following the rules of the html tokenizer. Duplicate names are
+// ignored, like the tokenizer does.
+func rawAttrNames(raw []byte) []string {
+	var names []string
+	i := 0
+	skipSpace := func() {
+		for i < len(raw) && isHTMLSpace(rune(raw[i])) {
+			i++
+		}
+	}
+	for {
+		skipSpace()
+		if i >= len(raw) || raw[i] == '>' {
+			return names
+		}
+		// attribute name
+		start := i
+		for ; i < len(raw); i++ {
+			c := raw[i]
+			if c == '=' && i == start {
+				continue
+			}
+			if c == '=' || c == '/' || c == '>' || isHTMLSpace(rune(c)) {
+				break
+			}
+		}
+		if name := string(raw[start:i]); name != "" && !containsFold(names, name) {
+			names = append(names, name)
+		}
+		// attribute value
+		skipSpace()
+		if i >= len(raw) {
+			return names
+		}
+		if raw[i] == '/' {
+			i++
+			continue
+		}
+		if raw[i] != '=' {
+			continue
+		}
+		i++
+		skipSpace()
+		if i >= len(raw) {
+			return names
+		}
+		switch quote := raw[i]; quote {
+		case '>':
+		case '\'', '"':
+			end := bytes.IndexByte(raw[i+1:], quote)
+			if end < 0 {
+				return names
+			}
+			i += end + 2
+		default:
+			for i < len(raw) && raw[i] != '>' && !isHTMLSpace(rune(raw[i])) {
+				i++
+			}
+		}
+	}
+}
+
+func containsFold(list []string, s string) bool {
+	for _, e := range list {
+		if strings.EqualFold(e, s) {
+			return true
+		}
+	}
+	return false
+}
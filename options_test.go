@@ -0,0 +1,145 @@
+package gosoup
+
+import (
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseWithDefaultOptions(t *testing.T) {
+	doc, err := ParseWithOptions(strings.NewReader(HTML), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := Parse(strings.NewReader(HTML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, render(t, expected), render(t, doc))
+	assertConsistent(t, doc)
+}
+
+func TestParseWithDropOptions(t *testing.T) {
+	input := "<div>\n  <!-- note -->\n  <p> text </p>\n  <pre> </pre>\n</div>"
+
+	doc, err := ParseWithOptions(strings.NewReader(input), Options{DropComments: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, "<html><head></head><body><div>\n  \n  <p> text </p>\n  <pre> </pre>\n</div></body></html>", render(t, doc))
+
+	doc, err = ParseWithOptions(strings.NewReader(input), Options{DropBlankText: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, "<html><head></head><body><div><!-- note --><p> text </p><pre></pre></div></body></html>", render(t, doc))
+	assertConsistent(t, doc)
+}
+
+func TestParseWithDropOptionsAtParseTime(t *testing.T) {
+	// the text around a dropped comment is a single node
+	doc, err := ParseWithOptions(strings.NewReader("<p>a<!-- c -->b</p>"), Options{DropComments: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := First(Filter(doc.DescendantNodes(), predicateIsTag("p")))
+	assertEquals(t, "ab", p.FirstChild.Data)
+	assert(t, p.FirstChild == p.LastChild, "expected a single text node")
+
+	// blank text is only dropped if it is still blank once the tree is built,
+	// even if it was moved in the meantime
+	inputs := append([]string{"<p> </foo>x</p>", "<p><b><div>  </b>x", "<table> <tr>x</table>"}, parserInputs...)
+	for _, input := range inputs {
+		doc, err := ParseWithOptions(strings.NewReader(input), Options{DropBlankText: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertConsistent(t, doc)
+		expected := parseBody(t, input)
+		for _, n := range slices.Collect(Filter(expected.All(), (*Node).IsBlankText)) {
+			n.Detach()
+		}
+		assertEqualsWithMsg(t, dumpTree(expected), dumpTree(doc), "input: ", input)
+	}
+}
+
+func TestParseWithPreservedAttrCase(t *testing.T) {
+	input := `<DIV ID="a" dataValue=1 Checked data-X='y' id="dup"><svg viewBox="0 0 1 1" xlink:href="#x"></svg><INPUT Type=text></DIV>`
+
+	doc, err := ParseWithOptions(strings.NewReader(input), Options{PreserveAttrCase: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertConsistent(t, doc)
+	assertEquals(t, `<html><head></head><body><div ID="a" dataValue="1" Checked="" data-X="y"><svg viewBox="0 0 1 1" xlink:href="#x"></svg><input Type="text"/></div></body></html>`, render(t, doc))
+
+	// the elements created by the parser are linked to the same token
+	doc, err = ParseWithOptions(strings.NewReader(`<p><B Class="x">one<p>two`), Options{PreserveAttrCase: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, `<html><head></head><body><p><b Class="x">one</b></p><p><b Class="x">two</b></p></body></html>`, render(t, doc))
+
+	// the Noah's Ark clause compares the attributes of the parsed elements
+	for _, input := range []string{`<p><b><b><b><b>x<p>y`, `<p><B Class="x"><b class="x"><b CLASS="x"><b class="x">x<p>y`} {
+		doc, err = ParseWithOptions(strings.NewReader(input), Options{PreserveAttrCase: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEquals(t, render(t, parseBody(t, input)), strings.ToLower(render(t, doc)))
+		p := doc.FirstChild.LastChild.LastChild
+		assertEquals(t, 3, len(p.Descendants().Filter(predicateIsTag("b")).All()))
+	}
+}
+
+func TestParseWithLimits(t *testing.T) {
+	input := "<div><div><div><p>text</p></div></div></div>"
+
+	// document > html > body > div > div > div > p > text
+	_, err := ParseWithOptions(strings.NewReader(input), Options{MaxDepth: 7})
+	assert(t, err == nil, "unexpected error ", err)
+	_, err = ParseWithOptions(strings.NewReader(input), Options{MaxDepth: 6})
+	assert(t, errors.Is(err, ErrMaxDepth), "expected ErrMaxDepth, got ", err)
+
+	// 9 nodes, including the implied head
+	_, err = ParseWithOptions(strings.NewReader(input), Options{MaxNodes: 9})
+	assert(t, err == nil, "unexpected error ", err)
+	_, err = ParseWithOptions(strings.NewReader(input), Options{MaxNodes: 8})
+	assert(t, errors.Is(err, ErrMaxNodes), "expected ErrMaxNodes, got ", err)
+
+	// the dropped nodes are not counted, unless they are merged with other text
+	input = "<p> <!-- c --> </p><p> </foo>x</p>"
+	_, err = ParseWithOptions(strings.NewReader(input), Options{MaxNodes: 7, DropComments: true, DropBlankText: true})
+	assert(t, err == nil, "unexpected error ", err)
+	_, err = ParseWithOptions(strings.NewReader(input), Options{MaxNodes: 6, DropComments: true, DropBlankText: true})
+	assert(t, errors.Is(err, ErrMaxNodes), "expected ErrMaxNodes, got ", err)
+
+	// the parser stops as soon as a limit is exceeded
+	for _, c := range []struct {
+		input string
+		opts  Options
+		err   error
+	}{
+		{strings.Repeat("<p>text", 100000), Options{MaxNodes: 1000}, ErrMaxNodes},
+		{strings.Repeat("<div>", 100000), Options{MaxDepth: 100}, ErrMaxDepth},
+	} {
+		r := &countingReader{r: strings.NewReader(c.input)}
+		_, err = ParseWithOptions(r, c.opts)
+		assert(t, errors.Is(err, c.err), "expected ", c.err, ", got ", err)
+		assert(t, r.n < len(c.input)/10, "read ", r.n, " bytes of ", len(c.input))
+	}
+}
+
+// countingReader counts the bytes read from r.
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += n
+	return n, err
+}
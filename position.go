@@ -0,0 +1,263 @@
+package gosoup
+
+import (
+	"fmt"
+	"golang.org/x/net/html"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unicode/utf8"
+	"weak"
+)
+
+// Location is a point in the source HTML.
+type Location struct {
+	Offset int // the offset in bytes, starting at 0
+	Line   int // the line number, starting at 1
+	Column int // the column number in characters, starting at 1
+}
+
+// String returns this location as "line:column".
+func (l Location) String() string {
+	return fmt.Sprintf("%d:%d", l.Line, l.Column)
+}
+
+// Position is the range of the source HTML a node was parsed from. The End
+// location is exclusive.
+//
+// The range of an element goes from the start of its start tag to the end of its
+// end tag, if it has one, and otherwise to the end of its last descendant.
+type Position struct {
+	Start, End Location
+}
+
+// String returns the start of this position as "line:column".
+func (p Position) String() string {
+	return p.Start.String()
+}
+
+// Position returns the position of this node in the source HTML, and whether it
+// is known.
+//
+// Positions are only recorded by ParseWithOptions, if Options.RecordPositions is
+// set. The nodes created by the parser without a counterpart in the source, such
+// as an implied <tbody>, have no position, and the elements it reopens, such as a
+// <b> spanning two paragraphs, share the start of the original element. The
+// offsets are relative to the UTF-8 input given to the parser.
+//
+// The positions are stored along with the document node, so that a node only has
+// a position while it belongs to the tree of the document it was parsed in: the
+// nodes returned by ParseFragment, the copies made by Clone and the nodes detached
+// from their tree have none. Finding the document takes a time proportional to
+// the depth of the node. The positions do not keep the nodes removed from the
+// tree in memory.
+func (node *Node) Position() (Position, bool) {
+	if positions := documentPositions(node.Root()); positions != nil {
+		pos, ok := positions[weak.Make(node)]
+		return pos, ok
+	}
+	return Position{}, false
+}
+
+// positionTable holds the positions of the nodes of a document built by the
+// parser. The nodes are referenced weakly, so that the table does not keep alive
+// the nodes removed from the tree.
+type positionTable map[weak.Pointer[Node]]Position
+
+// documents maps the document nodes built by the parser, referenced weakly, to the
+// positionTable of their tree. Node has no room for the table, see WrapTree. The
+// entry of a document is removed once it is garbage collected, and
+// positionedDocuments counts the entries, so that the nodes of other trees are not
+// looked up while there are none.
+var (
+	documents           sync.Map
+	positionedDocuments atomic.Int64
+)
+
+// registerDocument makes the given positions of the nodes of doc, a document
+// built by the parser, available via Node.Position.
+func registerDocument(doc *Node, positions map[*Node]Position) {
+	table := make(positionTable, len(positions))
+	for n, pos := range positions {
+		table[weak.Make(n)] = pos
+	}
+	key := weak.Make(doc)
+	documents.Store(key, table)
+	positionedDocuments.Add(1)
+	runtime.AddCleanup(doc, func(key weak.Pointer[Node]) {
+		documents.Delete(key)
+		positionedDocuments.Add(-1)
+	}, key)
+}
+
+// documentPositions returns the positions of the nodes of the tree of the given
+// root if it is a document built by the parser with its positions, and nil
+// otherwise.
+func documentPositions(root *Node) positionTable {
+	if root.Type != DocumentNode || positionedDocuments.Load() == 0 {
+		return nil
+	}
+	if table, ok := documents.Load(weak.Make(root)); ok {
+		return table.(positionTable)
+	}
+	return nil
+}
+
+// describeNode returns a short description of the given node for error messages,
+// including its position if it is known.
+func describeNode(n *Node) string {
+	var desc string
+	switch n.Type {
+	case ElementNode:
+		desc = "<" + n.Data + ">"
+	case TextNode:
+		desc = "text node"
+	case DocumentNode:
+		desc = "document"
+	case CommentNode:
+		desc = "comment"
+	case DoctypeNode:
+		desc = "doctype"
+	default:
+		desc = "node"
+	}
+	if pos, ok := n.Position(); ok {
+		desc += " at " + pos.String()
+	}
+	return desc
+}
+
+// advance returns the location following the given source text, which starts at
+// the location l.
+func advance[T string | []byte](l Location, text T) Location {
+	l.Offset += len(text)
+	lineStart := 0
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			l.Line++
+			l.Column = 1
+			lineStart = i + 1
+		}
+	}
+	switch text := any(text[lineStart:]).(type) {
+	case string:
+		l.Column += utf8.RuneCountInString(text)
+	case []byte:
+		l.Column += utf8.RuneCount(text)
+	}
+	return l
+}
+
+// setPosition records pos as the position of n, a node created by the parser,
+// unless the position is unknown.
+func (p *parser) setPosition(n *Node, pos Position) {
+	if p.positions != nil && pos.Start.Line > 0 {
+		p.positions[n] = pos
+	}
+}
+
+// fromToken counts n, a node created from the current token, and records the
+// position of the token as its position, before returning it. Elements span their
+// start tag until their end is known. It returns nil if n is to be dropped
+// according to the options, which addChild and appendChild ignore.
+func (p *parser) fromToken(n *Node) *Node {
+	if skip(n, p.opts) {
+		return nil
+	}
+	p.countNode(n)
+	p.setPosition(n, Position{p.tok.start, p.tok.end})
+	if p.attrNames != nil && p.tok.attrNames != nil && n.Type == ElementNode {
+		p.attrNames[n] = p.tok.attrNames
+	}
+	return n
+}
+
+// textPosition returns the position of the given text, the next part of the
+// current text token to be added to the tree, or the zero Position if it is
+// unknown. When the text cannot be found in the source, for instance because it
+// was unescaped, the position of the whole token is returned.
+func (p *parser) textPosition(text string) Position {
+	t := &p.tok
+	if t.start.Line == 0 {
+		return Position{}
+	}
+	if i := strings.Index(t.source[t.consumed:], text); i >= 0 {
+		start := t.consumed + i
+		t.consumed = start + len(text)
+		return Position{advance(t.start, t.source[:start]), advance(t.start, t.source[:t.consumed])}
+	}
+	return Position{t.start, t.end}
+}
+
+// closedByCurrentToken returns the open element which the current token closes if
+// it is an end tag matching it, or nil. It is called before the token is parsed,
+// and also returns the index of the element in the stack of open elements and the
+// number of shifts of the stack so far.
+func (p *parser) closedByCurrentToken() (*Node, int, int) {
+	if p.tok.Type != html.EndTagToken || p.tok.start.Line == 0 {
+		return nil, -1, p.shifts
+	}
+	for i := len(p.oe) - 1; i >= 0; i-- {
+		if strings.EqualFold(p.oe[i].Data, p.tok.Data) {
+			return p.oe[i], i, p.shifts
+		}
+	}
+	return nil, -1, p.shifts
+}
+
+// endTagParsed extends the position of the given element, returned by
+// closedByCurrentToken, to the end of the current token if parsing the token
+// closed the element. Unless the stack of open elements was shifted, the element
+// is still open only at the same index, which avoids scanning a deep stack.
+func (p *parser) endTagParsed(n *Node, i, shifts int) {
+	pos, ok := p.positions[n]
+	if !ok {
+		return
+	}
+	open := i < len(p.oe) && p.oe[i] == n
+	if !open && shifts != p.shifts {
+		open = p.oe.index(n) != -1
+	}
+	if !open {
+		pos.End = p.tok.end
+		p.positions[n] = pos
+	}
+}
+
+// finish completes the tree once all the tokens are parsed: the blank text is
+// dropped if requested, the positions of the elements are extended to their last
+// descendant and recorded, and the original case of the attribute names is
+// restored if requested.
+func (p *parser) finish() {
+	p.dropBlankText()
+	for n, names := range p.attrNames {
+		restoreAttrCase(n, names)
+	}
+	if p.positions == nil {
+		return
+	}
+	p.setPosition(p.doc, Position{Location{Line: 1, Column: 1}, p.loc})
+	// ends holds the end of the positioned nodes of the subtree being visited,
+	// for each ancestor of the current node
+	var ends []Location
+	walk(p.doc, func(n *Node) (bool, error) {
+		ends = append(ends, Location{})
+		return true, nil
+	}, func(n *Node) error {
+		end := ends[len(ends)-1]
+		ends = ends[:len(ends)-1]
+		if pos, ok := p.positions[n]; ok {
+			if n.Type == ElementNode && end.Offset > pos.End.Offset {
+				pos.End = end
+				p.positions[n] = pos
+			}
+			end = pos.End
+		}
+		if len(ends) > 0 && end.Offset > ends[len(ends)-1].Offset {
+			ends[len(ends)-1] = end
+		}
+		return nil
+	})
+	registerDocument(p.doc, p.positions)
+}
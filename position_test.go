@@ -0,0 +1,259 @@
+package gosoup
+
+import (
+	"fmt"
+	"golang.org/x/net/html"
+	"regexp"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+const HTML_POSITIONS string = "<!DOCTYPE html>\n" +
+	"<html>\n" +
+	"<body class=\"x\">\n" +
+	"  <p id=\"p1\">héllo <b>big</b> world</p>\n" +
+	"  <!-- note -->\n" +
+	"  <ul><li id=\"li1\">one<li id=\"li2\">two</ul>\n" +
+	"  <table><tr id=\"tr\"><td>cell</td></tr></table>\n" +
+	"  <pre id=\"pre\">\ncode</pre><br>\n" +
+	"</body>\n" +
+	"</html>\n"
+
+func parseWithPositions(t *testing.T, html string) *Node {
+	doc, err := ParseWithOptions(strings.NewReader(html), Options{RecordPositions: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+// source returns the part of the input covered by the position of the node.
+func sourceOf(t *testing.T, input string, n *Node) string {
+	pos, ok := n.Position()
+	assert(t, ok, "no position for ", describeNode(n))
+	return input[pos.Start.Offset:pos.End.Offset]
+}
+
+// assertPositions checks that the known positions of the nodes of doc are valid
+// ranges of the input.
+func assertPositions(t *testing.T, input string, doc *Node) {
+	for n := range doc.All() {
+		pos, ok := n.Position()
+		if !ok {
+			continue
+		}
+		assert(t, 0 <= pos.Start.Offset && pos.Start.Offset <= pos.End.Offset && pos.End.Offset <= len(input),
+			"bad range ", pos.Start.Offset, "-", pos.End.Offset, " for ", describeNode(n))
+		for _, l := range []Location{pos.Start, pos.End} {
+			before := input[:l.Offset]
+			lineStart := strings.LastIndexByte(before, '\n') + 1
+			expected := Location{l.Offset, strings.Count(before, "\n") + 1, utf8.RuneCountInString(before[lineStart:]) + 1}
+			assertEqualsWithMsg(t, expected, l, "location of ", describeNode(n))
+		}
+		if n.Type == ElementNode {
+			assert(t, input[pos.Start.Offset] == '<', "bad start for ", describeNode(n))
+		}
+	}
+}
+
+func TestPositions(t *testing.T) {
+	input := HTML_POSITIONS
+	doc := parseWithPositions(t, input)
+	p1, li1, li2 := byID(doc, "p1"), byID(doc, "li1"), byID(doc, "li2")
+
+	assertEquals(t, input, sourceOf(t, input, doc))
+	assertEquals(t, "<!DOCTYPE html>", sourceOf(t, input, doc.FirstChild))
+	assertEquals(t, `<p id="p1">héllo <b>big</b> world</p>`, sourceOf(t, input, p1))
+	assertEquals(t, "héllo ", sourceOf(t, input, p1.FirstChild))
+	assertEquals(t, "<b>big</b>", sourceOf(t, input, p1.FirstChild.NextSibling))
+	assertEquals(t, " world", sourceOf(t, input, p1.LastChild))
+	assertEquals(t, "<!-- note -->", sourceOf(t, input, p1.NextSibling.NextSibling))
+
+	// elements closed implicitly end with their last descendant
+	assertEquals(t, `<li id="li1">one`, sourceOf(t, input, li1))
+	assertEquals(t, `<li id="li2">two`, sourceOf(t, input, li2))
+	assertEquals(t, `<ul><li id="li1">one<li id="li2">two</ul>`, sourceOf(t, input, li1.Parent))
+
+	// the parser drops the first newline of <pre>
+	pre := byID(doc, "pre")
+	assertEquals(t, "code", sourceOf(t, input, pre.FirstChild))
+	assertEquals(t, "<br>", sourceOf(t, input, pre.NextSibling))
+
+	// implied elements have no position
+	tbody := byID(doc, "tr").Parent
+	assertEquals(t, "tbody", tbody.Data)
+	_, ok := tbody.Position()
+	assert(t, !ok, "expected no position for an implied element")
+	assertEquals(t, "<table><tr id=\"tr\"><td>cell</td></tr></table>", sourceOf(t, input, tbody.Parent))
+
+	pos, _ := p1.Position()
+	assertEquals(t, Location{Offset: 42, Line: 4, Column: 3}, pos.Start)
+	assertEquals(t, Location{Offset: 80, Line: 4, Column: 40}, pos.End)
+	assertEquals(t, "4:3", pos.String())
+	pos, _ = p1.LastChild.Position()
+	assertEquals(t, "4:30", pos.String())
+}
+
+var endTags = regexp.MustCompile("</[a-z]+>")
+
+func TestPositionsOfAllNodes(t *testing.T) {
+	input := HTML_POSITIONS
+	doc := parseWithPositions(t, input)
+	for n := range doc.All() {
+		if n.Type == TextNode {
+			// text after </body> is moved into the body, and spans the end tags
+			source := endTags.ReplaceAllString(sourceOf(t, input, n), "")
+			assert(t, source == n.Data || source == "\n"+n.Data, "expected text ", n.Data, ", got ", source, " at ", describeNode(n))
+		}
+		if n.Type == ElementNode && n.HasAttr("id") {
+			assert(t, strings.HasPrefix(sourceOf(t, input, n), "<"+n.Data), "bad position for ", describeNode(n))
+		}
+	}
+
+	for n := range parseBody(t, input).All() {
+		_, ok := n.Position()
+		assert(t, !ok, "unexpected position without RecordPositions")
+	}
+}
+
+func TestPositionsOfParserInputs(t *testing.T) {
+	for _, input := range parserInputs {
+		assertPositions(t, input, parseWithPositions(t, input))
+	}
+
+	// the elements reopened by the parser share the start of the original ones
+	input := "<p><b><b><b><b>x<p>y"
+	doc := parseWithPositions(t, input)
+	p := doc.FirstChild.LastChild.LastChild
+	assertEquals(t, "<b><b><b>", render(t, p)[3:12])
+	for n := p.FirstChild; n.Type == ElementNode; n = n.FirstChild {
+		pos, ok := n.Position()
+		assert(t, ok, "no position for a reopened element")
+		assert(t, pos.Start.Offset < 15 && input[pos.Start.Offset:pos.Start.Offset+3] == "<b>", "bad start ", pos.Start)
+		assertEquals(t, len(input), pos.End.Offset)
+	}
+}
+
+func TestPositionsInErrors(t *testing.T) {
+	doc := parseWithPositions(t, HTML_POSITIONS)
+	p1 := byID(doc, "p1")
+
+	_, err := p1.XPath("count(*)")
+	assert(t, err != nil, "expected an error")
+	assert(t, strings.Contains(err.Error(), "evaluated on <p> at 4:3"), "no position in ", err)
+
+	_, err = p1.XPath("substring-before(., 'x', 'y', 'z')")
+	assert(t, err != nil, "expected an error")
+
+	_, err = p1.SelectFirst("b[")
+	assert(t, err != nil, "expected an error")
+	assert(t, strings.HasSuffix(err.Error(), "applied to <p> at 4:3"), "no position in ", err)
+
+	br := byID(doc, "pre").NextSibling
+	br.AppendChild(&Node{Type: TextNode, Data: "x"})
+	err = Render(&strings.Builder{}, br)
+	assert(t, err != nil && strings.Contains(err.Error(), "<br> at 9:11"), "no position in ", err)
+
+	defer func() {
+		r := recover()
+		assert(t, strings.Contains(fmt.Sprint(r), "no such attribute 'href' on <p> at 4:3"), "no position in ", r)
+	}()
+	p1.Attr("href")
+}
+
+func TestPositionsWithMovedNodes(t *testing.T) {
+	input := "<table id=\"t\"><tr><td>1</td></tr>moved</table><p id=\"p\">a</foo>b</p>"
+	doc := parseWithPositions(t, input)
+
+	// foster parenting moves the text before the table
+	table := byID(doc, "t")
+	assertEquals(t, "moved", table.PrevSibling.Data)
+	assertEquals(t, "moved", sourceOf(t, input, table.PrevSibling))
+	assertEquals(t, "<td>1</td>", sourceOf(t, input, table.FirstChild.FirstChild.FirstChild))
+
+	// the ignored end tag is part of the text node
+	p := byID(doc, "p")
+	assertEquals(t, "ab", p.FirstChild.Data)
+	assertEquals(t, "a</foo>b", sourceOf(t, input, p.FirstChild))
+	assertEquals(t, "<p id=\"p\">a</foo>b</p>", sourceOf(t, input, p))
+}
+
+func TestPositionsBelongToTheDocument(t *testing.T) {
+	doc := parseWithPositions(t, HTML_POSITIONS)
+	p1 := byID(doc, "p1")
+	body := p1.Parent
+	expected, _ := p1.Position()
+
+	_, ok := p1.Clone(true).Position()
+	assert(t, !ok, "unexpected position for a copy")
+	p1.Detach()
+	_, ok = p1.Position()
+	assert(t, !ok, "unexpected position for a detached node")
+	body.AppendChild(p1)
+	pos, ok := p1.Position()
+	assert(t, ok, "expected the position to be back")
+	assertEquals(t, expected, pos)
+
+	other := parseWithPositions(t, "<p>x</p>")
+	p1.Detach()
+	other.FirstChild.LastChild.AppendChild(p1)
+	_, ok = p1.Position()
+	assert(t, !ok, "unexpected position in another document")
+
+	nodes, err := ParseFragment(strings.NewReader("<p>x</p>"), body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, ok = nodes[0].Position()
+	assert(t, !ok, "unexpected position for a fragment")
+	h, err := html.Parse(strings.NewReader("<p>x</p>"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, ok = WrapTree(h).Position()
+	assert(t, !ok, "unexpected position for a tree of the html package")
+}
+
+func TestPositionsOfCollectedDocuments(t *testing.T) {
+	doc := parseWithPositions(t, HTML_POSITIONS)
+	p1 := byID(doc, "p1")
+	expected, _ := p1.Position()
+
+	const parsed = 1000
+	before := positionedDocuments.Load()
+	for range parsed {
+		parseWithPositions(t, "<p>x</p>")
+	}
+	// the cleanups of the collected documents run in the background
+	for i := 0; i < 100 && positionedDocuments.Load()-before >= parsed/2; i++ {
+		runtime.GC()
+		time.Sleep(time.Millisecond)
+	}
+	registered := positionedDocuments.Load() - before
+	assert(t, registered < parsed/2, "expected the collected documents to be removed, got ", registered)
+	pos, ok := p1.Position()
+	assert(t, ok, "expected the position to be kept")
+	assertEquals(t, expected, pos)
+}
+
+func TestPositionsDoNotKeepDetachedNodes(t *testing.T) {
+	doc := parseWithPositions(t, HTML_POSITIONS)
+	p1 := byID(doc, "p1")
+	collected := make(chan struct{})
+	runtime.AddCleanup(p1, func(chan struct{}) { close(collected) }, collected)
+	p1.Detach()
+	p1 = nil
+	for i := 0; i < 100; i++ {
+		runtime.GC()
+		select {
+		case <-collected:
+			runtime.KeepAlive(doc)
+			return
+		case <-time.After(time.Millisecond):
+		}
+	}
+	t.Fatal("expected the detached node to be collected")
+}
@@ -0,0 +1,398 @@
+package gosoup
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"golang.org/x/net/html"
+	"io"
+	"strings"
+)
+
+// The renderer below writes a tree of Node in the same way as html.Render, with
+// the options of RenderWithOptions. Without options, Render uses html.Render,
+// which is faster, and the renderer is only used by OuterHTML and InnerHTML, to
+// skip what cannot be rendered.
+
+// renderWriter is the interface of the writers the renderer writes to.
+type renderWriter interface {
+	io.Writer
+	io.ByteWriter
+	io.StringWriter
+}
+
+// errPlaintextAbort is returned when a <plaintext> element has been rendered. No
+// more end tags should be rendered after that.
+var errPlaintextAbort = errors.New("gosoup: internal error (plaintext abort)")
+
+// renderer renders a tree of Node with some options.
+type renderer struct {
+	w    renderWriter
+	opts RenderOptions
+	root *Node
+
+	// inner renders only the content of root, not its tags. lenient skips what
+	// cannot be rendered, instead of returning an error.
+	inner, lenient bool
+
+	// the fields below are only used if the layout is changed, see format.go
+	layout   bool   // whether Indent or Minify is set
+	blocks   []bool // for each open node, whether its children are laid out as blocks
+	verbatim int    // the number of open elements whose content is rendered as is
+	level    int    // the current level of indentation
+	started  bool   // whether some node was rendered already
+}
+
+// renderTree renders the tree of root. The tree is walked without recursion, so
+// that deeply nested trees can be rendered as well.
+func renderTree(w renderWriter, root *Node, opts RenderOptions) error {
+	r := &renderer{
+		w:      w,
+		opts:   opts,
+		root:   root,
+		layout: opts.Minify || opts.Indent != "",
+	}
+	return r.render()
+}
+
+func (r *renderer) render() error {
+	err := walk(r.root, r.enter, r.leave)
+	if err == errPlaintextAbort {
+		err = nil
+	}
+	return err
+}
+
+// OuterHTML returns the HTML serialization of this node and its descendants, the
+// way Render writes it. Since a string cannot fail to be written, the nodes that
+// cannot be rendered, such as ErrorNode nodes, are skipped instead of causing an
+// error.
+func (node *Node) OuterHTML() string {
+	if node == nil {
+		return ""
+	}
+	var b strings.Builder
+	r := &renderer{w: &b, root: node, lenient: true}
+	r.render()
+	return b.String()
+}
+
+// InnerHTML returns the HTML serialization of the children of this node, the way
+// Render writes them, without the tags of this node. Unlike rendering the
+// children separately, the text of raw text elements such as <script> or <style>
+// is not escaped. As for OuterHTML, the nodes that cannot be rendered are skipped.
+//
+// Setting the result with SetInnerHTML gives back equivalent children.
+func (node *Node) InnerHTML() string {
+	if node == nil {
+		return ""
+	}
+	var b strings.Builder
+	r := &renderer{w: &b, root: node, inner: true, lenient: true}
+	r.render()
+	return b.String()
+}
+
+func (r *renderer) enter(n *Node) (bool, error) {
+	if r.layout && n != r.root {
+		if done, err := r.layoutNode(n); done || err != nil {
+			return false, err
+		}
+	}
+	if r.inner && n == r.root {
+		return n.Type == DocumentNode || n.Type == ElementNode, nil
+	}
+	descend, err := r.start(n)
+	if n.Type != DocumentNode {
+		r.started = true
+	}
+	if descend && r.layout {
+		r.push(n)
+	}
+	return descend, err
+}
+
+func (r *renderer) leave(n *Node) error {
+	if r.inner && n == r.root {
+		return nil
+	}
+	if r.layout && hasContent(n) {
+		r.pop(n)
+	}
+	return r.end(n)
+}
+
+// hasContent returns true if the children of the given node are rendered.
+func hasContent(n *Node) bool {
+	return n.Type == DocumentNode || n.Type == ElementNode && !voidElements[n.Data]
+}
+
+// start renders the given node, or only its start tag if it is an element, and
+// returns true if its children must be rendered.
+func (r *renderer) start(n *Node) (bool, error) {
+	switch n.Type {
+	case ErrorNode:
+		if r.lenient {
+			return false, nil
+		}
+		return false, errors.New("Render: cannot render an ErrorNode node")
+	case TextNode:
+		data := n.Data
+		if n == r.root || !childTextNodesAreLiteral(n.Parent) {
+			data = html.EscapeString(data)
+		}
+		_, err := r.w.WriteString(data)
+		return false, err
+	case DocumentNode:
+		return true, nil
+	case ElementNode:
+		return r.startTag(n)
+	case CommentNode:
+		return false, writeStrings(r.w, "<!--", escapeComment(n.Data), "-->")
+	case DoctypeNode:
+		return false, r.doctype(n)
+	}
+	if r.lenient {
+		return false, nil
+	}
+	return false, errors.New("Render: unknown node type")
+}
+
+// startTag renders the start tag of the given element, and returns false if it is
+// a void element, whose children must not be rendered.
+func (r *renderer) startTag(n *Node) (bool, error) {
+	w := r.w
+	if err := writeStrings(w, "<", n.Data); err != nil {
+		return false, err
+	}
+	void := voidElements[n.Data]
+	attrs := n.Attrs
+	if r.opts.SortAttrs {
+		attrs = sortedAttrs(attrs)
+	}
+	for i, a := range attrs {
+		if err := w.WriteByte(' '); err != nil {
+			return false, err
+		}
+		// an unquoted value would include a slash following it, and an empty
+		// value cannot be omitted if the next attribute would be read as a value
+		var unquoted, omitted bool
+		if r.opts.Minify {
+			last := i == len(attrs)-1
+			unquoted = !last || !void || r.opts.SelfClosing != SelfClosingSlash
+			omitted = last || !strings.HasPrefix(attrs[i+1].Key, "=")
+		}
+		if err := r.attr(a, unquoted, omitted); err != nil {
+			return false, err
+		}
+	}
+	if void {
+		if n.FirstChild != nil && !r.lenient {
+			return false, fmt.Errorf("Render: void element %s has child nodes", describeNode(n))
+		}
+		_, err := w.WriteString(selfClosingEnds[r.opts.SelfClosing])
+		return false, err
+	}
+	if err := w.WriteByte('>'); err != nil {
+		return false, err
+	}
+
+	// add an initial newline where there is danger of a newline being ignored
+	if c := n.FirstChild; c != nil && c.Type == TextNode && strings.HasPrefix(c.Data, "\n") {
+		switch n.Data {
+		case "pre", "listing", "textarea":
+			if err := w.WriteByte('\n'); err != nil {
+				return false, err
+			}
+		}
+	}
+	return true, nil
+}
+
+// selfClosingEnds are the ends of the start tags of void elements, for each
+// SelfClosingStyle.
+var selfClosingEnds = map[SelfClosingStyle]string{
+	SelfClosingSlash:      "/>",
+	SelfClosingSpaceSlash: " />",
+	SelfClosingNone:       ">",
+}
+
+// attr renders the given attribute, with its value. If allowed, the value is
+// rendered without quotes when possible, and an empty value is omitted.
+func (r *renderer) attr(a Attribute, unquoted, omitted bool) error {
+	if a.Namespace != "" {
+		if err := writeStrings(r.w, a.Namespace, ":"); err != nil {
+			return err
+		}
+	}
+	if err := writeStrings(r.w, a.Key); err != nil {
+		return err
+	}
+	if a.Val == "" && omitted {
+		return nil
+	}
+	escaped := html.EscapeString(a.Val)
+	if unquoted && a.Val != "" && !strings.ContainsAny(escaped, unquotedAttrForbidden) {
+		return writeStrings(r.w, "=", escaped)
+	}
+	return writeStrings(r.w, `="`, escaped, `"`)
+}
+
+// unquotedAttrForbidden are the characters that cannot appear in unquoted
+// attribute values, once escaped.
+const unquotedAttrForbidden = " \t\n\f\r=`"
+
+// end renders the end tag of the given node, if it is an element that has one.
+func (r *renderer) end(n *Node) error {
+	if n.Type != ElementNode || voidElements[n.Data] {
+		return nil
+	}
+	if n.Data == "plaintext" && childTextNodesAreLiteral(n) {
+		// <plaintext> must be the last element, with no end tag
+		return errPlaintextAbort
+	}
+	if r.opts.Minify && n != r.root && r.optionalEndTag(n) {
+		return nil
+	}
+	return writeStrings(r.w, "</", n.Data, ">")
+}
+
+func (r *renderer) doctype(n *Node) error {
+	var public, system string
+	for _, a := range n.Attrs {
+		switch a.Key {
+		case "public":
+			public = a.Val
+		case "system":
+			system = a.Val
+		}
+	}
+	if doctypeQuote(public) == "" || doctypeQuote(system) == "" {
+		if !r.lenient {
+			return errors.New("Render: doctype contains both quote types, cannot be safely rendered")
+		}
+		public, system = "", ""
+	}
+	w := r.w
+	if err := writeStrings(w, "<!DOCTYPE ", html.EscapeString(n.Data)); err != nil {
+		return err
+	}
+	if public != "" {
+		if err := writeStrings(w, " PUBLIC ", doctypeQuoted(public)); err != nil {
+			return err
+		}
+		if system != "" {
+			if err := writeStrings(w, " ", doctypeQuoted(system)); err != nil {
+				return err
+			}
+		}
+	} else if system != "" {
+		if err := writeStrings(w, " SYSTEM ", doctypeQuoted(system)); err != nil {
+			return err
+		}
+	}
+	return w.WriteByte('>')
+}
+
+// childTextNodesAreLiteral returns true if the text children of the given element
+// must be rendered as is, which is the case for the raw text elements of the HTML
+// namespace, unless they were moved into foreign content.
+func childTextNodesAreLiteral(n *Node) bool {
+	if n.Namespace != "" {
+		return false
+	}
+	switch n.Data {
+	case "iframe", "noembed", "noframes", "noscript", "plaintext", "script", "style", "xmp":
+		for p := n.Parent; p != nil; p = p.Parent {
+			if p.Namespace != "" {
+				return htmlIntegrationPoint(p)
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// htmlIntegrationPoint returns true if the given foreign element may contain HTML
+// elements.
+func htmlIntegrationPoint(n *Node) bool {
+	if n.Type != ElementNode {
+		return false
+	}
+	switch n.Namespace {
+	case "math":
+		if n.Data == "annotation-xml" {
+			for _, a := range n.Attrs {
+				if a.Key == "encoding" && (strings.EqualFold(a.Val, "text/html") || strings.EqualFold(a.Val, "application/xhtml+xml")) {
+					return true
+				}
+			}
+		}
+	case "svg":
+		switch n.Data {
+		case "desc", "foreignObject", "title":
+			return true
+		}
+	}
+	return false
+}
+
+// escapeComment escapes the '&' characters of a comment, as well as the '>'
+// characters that could end it.
+func escapeComment(s string) string {
+	if !strings.ContainsAny(s, "&>") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '&':
+			b.WriteString("&amp;")
+		case s[i] == '>' && (i == 0 || s[i-1] == '!' || s[i-1] == '-'):
+			b.WriteString("&gt;")
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// doctypeQuote returns the quote to use around the given doctype identifier:
+// double quotes unless it contains some, or nothing if it contains both types of
+// quotes and thus cannot be rendered.
+func doctypeQuote(s string) string {
+	switch {
+	case !strings.Contains(s, `"`):
+		return `"`
+	case !strings.Contains(s, `'`):
+		return `'`
+	}
+	return ""
+}
+
+// doctypeQuoted returns the given doctype identifier surrounded by quotes. The '>'
+// characters are escaped, they would end the doctype.
+func doctypeQuoted(s string) string {
+	q := doctypeQuote(s)
+	return q + strings.ReplaceAll(s, ">", "&gt;") + q
+}
+
+// writeStrings writes the given strings in order.
+func writeStrings(w renderWriter, strs ...string) error {
+	for _, s := range strs {
+		if _, err := w.WriteString(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newRenderWriter returns a renderWriter writing to w, and a function to call
+// once done.
+func newRenderWriter(w io.Writer) (renderWriter, func() error) {
+	if rw, ok := w.(renderWriter); ok {
+		return rw, func() error { return nil }
+	}
+	buf := bufio.NewWriter(w)
+	return buf, buf.Flush
+}
@@ -0,0 +1,71 @@
+package gosoup
+
+import (
+	"testing"
+)
+
+func TestOuterAndInnerHTML(t *testing.T) {
+	doc := parseBody(t, `<div id="d" class="a&quot;b">x &lt; y &amp; <b>bold</b><br><!-- c --></div>`+
+		`<script id="s">if (a < b && c) { x = "</p>" }</script><style id="st">p > b { }</style>`+
+		`<p id="t">line 1<textarea id="ta">a &lt; b</textarea></p>`)
+	div := byID(doc, "d")
+	assertEquals(t, `<div id="d" class="a&#34;b">x &lt; y &amp; <b>bold</b><br/><!-- c --></div>`, div.OuterHTML())
+	assertEquals(t, `x &lt; y &amp; <b>bold</b><br/><!-- c -->`, div.InnerHTML())
+	assertEquals(t, render(t, div), div.OuterHTML())
+
+	// the text of raw text elements is not escaped
+	script := byID(doc, "s")
+	assertEquals(t, `<script id="s">if (a < b && c) { x = "</p>" }</script>`, script.OuterHTML())
+	assertEquals(t, `if (a < b && c) { x = "</p>" }`, script.InnerHTML())
+	assertEquals(t, `p > b { }`, byID(doc, "st").InnerHTML())
+	assertEquals(t, `a &lt; b`, byID(doc, "ta").InnerHTML())
+
+	// the subtree is serialized as is, without any wrapping
+	text := &Node{Type: TextNode, Data: "a < b"}
+	assertEquals(t, "a &lt; b", text.OuterHTML())
+	assertEquals(t, "", text.InnerHTML())
+	assertEquals(t, "<!-- c -->", div.LastChild.OuterHTML())
+
+	html := First(Filter(doc.DescendantNodes(), predicateIsTag("html")))
+	assertEquals(t, render(t, doc), doc.OuterHTML())
+	assertEquals(t, render(t, doc), doc.InnerHTML())
+	assertEquals(t, render(t, html), html.OuterHTML())
+
+	var nilNode *Node
+	assertEquals(t, "", nilNode.OuterHTML())
+	assertEquals(t, "", nilNode.InnerHTML())
+}
+
+func TestInnerHTMLRoundTrip(t *testing.T) {
+	for _, input := range []string{
+		`<p>a &amp; <b>b</b> &lt;c&gt;</p><ul><li>1</li></ul>`,
+		`<table><tbody><tr><td>1</td></tr></tbody></table>`,
+		`<svg><circle r="1"></circle><text>a &lt; b</text></svg>`,
+		"<pre>\n\nx</pre><script>1 < 2</script>",
+	} {
+		doc := parseBody(t, input)
+		body := First(Filter(doc.DescendantNodes(), predicateIsTag("body")))
+		inner := body.InnerHTML()
+		assertEquals(t, input, inner)
+
+		clone := body.Clone(false)
+		if err := clone.SetInnerHTML(inner); err != nil {
+			t.Fatal(err)
+		}
+		assertEquals(t, inner, clone.InnerHTML())
+	}
+}
+
+func TestOuterHTMLWithoutErrors(t *testing.T) {
+	img := &Node{Type: ElementNode, Data: "img"}
+	img.AppendChild(&Node{Type: TextNode, Data: "x"})
+	assertEquals(t, "<img/>", img.OuterHTML())
+
+	div := parseBody(t, `<div id="d">a</div>`)
+	d := byID(div, "d")
+	d.AppendChild(&Node{Type: ErrorNode, Data: "error"})
+	assertEquals(t, `<div id="d">a</div>`, d.OuterHTML())
+
+	doctype := &Node{Type: DoctypeNode, Data: "html", Attrs: []Attribute{{Key: "system", Val: `a"b'c`}}}
+	assertEquals(t, "<!DOCTYPE html>", doctype.OuterHTML())
+}
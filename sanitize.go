@@ -0,0 +1,405 @@
+package gosoup
+
+import (
+	"golang.org/x/net/html/atom"
+	"strings"
+)
+
+// Policy describes which parts of an HTML tree are kept by Sanitize. It is an
+// allowlist: the elements, attributes and URL schemes that were not explicitly
+// allowed are removed.
+//
+// Elements that are not allowed are replaced by their content, except the ones
+// whose content is not meant to be displayed, such as <script> or <style>, which
+// are removed along with their content. Comments, and foreign elements such as
+// <svg> or <math> along with their content, are always removed.
+//
+// Some elements and attributes can never be allowed, because they can execute
+// script or load active content: <script>, <style>, <iframe>, <object> and the
+// like, as well as the on* event handler attributes and the style and srcdoc
+// attributes. This guarantees that rendering the content of a sanitized node
+// produces HTML that cannot execute script, whatever the policy. The sanitized
+// node itself is kept as is, but if it is such an element, or a foreign one, its
+// content is removed entirely.
+//
+// The methods of a Policy return the policy itself, so that calls can be chained:
+//
+//	p := NewPolicy().
+//	    AllowElements("p", "b", "i").
+//	    AllowAttrs("a", "href", "title").
+//	    AllowURLSchemes("https")
+//
+// A Policy must not be modified while it is used by Sanitize, but it can be used
+// concurrently once set up.
+type Policy struct {
+	elements    map[string]map[string]bool // allowed elements, and their allowed attributes
+	globalAttrs map[string]bool
+	dropped     map[string]bool
+	schemes     map[string]bool
+	noRelative  bool
+	hooks       []func(n *Node) bool
+}
+
+// forbiddenElements are the elements that can execute script, load active content
+// or change how the rest of the document is interpreted. They are always removed
+// along with their content, and cannot be allowed.
+var forbiddenElements = map[string]bool{
+	"applet":    true,
+	"base":      true,
+	"embed":     true,
+	"frame":     true,
+	"frameset":  true,
+	"iframe":    true,
+	"link":      true,
+	"math":      true,
+	"meta":      true,
+	"noembed":   true,
+	"noframes":  true,
+	"noscript":  true,
+	"object":    true,
+	"param":     true,
+	"plaintext": true,
+	"script":    true,
+	"style":     true,
+	"svg":       true,
+	"template":  true,
+	"xmp":       true,
+}
+
+// forbiddenAttrs are the attributes that can never be allowed, in addition to the
+// on* event handlers.
+var forbiddenAttrs = map[string]bool{
+	"srcdoc": true,
+	"style":  true,
+}
+
+// urlAttrs are the attributes holding a URL, whose scheme is checked against the
+// policy.
+var urlAttrs = map[string]bool{
+	"action":     true,
+	"background": true,
+	"cite":       true,
+	"codebase":   true,
+	"data":       true,
+	"dynsrc":     true,
+	"formaction": true,
+	"href":       true,
+	"longdesc":   true,
+	"lowsrc":     true,
+	"manifest":   true,
+	"ping":       true,
+	"poster":     true,
+	"src":        true,
+	"usemap":     true,
+}
+
+// NewPolicy returns an empty policy, which only keeps text. Relative URLs are
+// allowed, but no URL scheme is.
+func NewPolicy() *Policy {
+	return &Policy{
+		elements:    map[string]map[string]bool{},
+		globalAttrs: map[string]bool{},
+		dropped:     map[string]bool{"head": true, "title": true},
+		schemes:     map[string]bool{},
+	}
+}
+
+func isForbiddenAttr(name string) bool {
+	return forbiddenAttrs[name] || strings.HasPrefix(name, "on")
+}
+
+// AllowElements allows the HTML elements with the given tag names.
+//
+// This function panics if one of the elements can never be allowed.
+func (p *Policy) AllowElements(tagNames ...string) *Policy {
+	for _, name := range tagNames {
+		name = strings.ToLower(name)
+		if forbiddenElements[name] {
+			panic("AllowElements: <" + name + "> elements cannot be allowed")
+		}
+		if p.elements[name] == nil {
+			p.elements[name] = map[string]bool{}
+		}
+	}
+	return p
+}
+
+// AllowAttrs allows the given attributes on the elements with the given tag name,
+// which are allowed as well.
+//
+// This function panics if the element or one of the attributes can never be
+// allowed.
+func (p *Policy) AllowAttrs(tagName string, attrs ...string) *Policy {
+	tagName = strings.ToLower(tagName)
+	if forbiddenElements[tagName] {
+		panic("AllowAttrs: <" + tagName + "> elements cannot be allowed")
+	}
+	p.AllowElements(tagName)
+	for _, attr := range attrs {
+		attr = strings.ToLower(attr)
+		if isForbiddenAttr(attr) {
+			panic("AllowAttrs: " + attr + " attributes cannot be allowed")
+		}
+		p.elements[tagName][attr] = true
+	}
+	return p
+}
+
+// AllowGlobalAttrs allows the given attributes on all the allowed elements.
+//
+// This function panics if one of the attributes can never be allowed.
+func (p *Policy) AllowGlobalAttrs(attrs ...string) *Policy {
+	for _, attr := range attrs {
+		attr = strings.ToLower(attr)
+		if isForbiddenAttr(attr) {
+			panic("AllowGlobalAttrs: " + attr + " attributes cannot be allowed")
+		}
+		p.globalAttrs[attr] = true
+	}
+	return p
+}
+
+// DropElements makes the elements with the given tag names be removed along with
+// their content, instead of being replaced by their content. Allowed elements are
+// not affected.
+func (p *Policy) DropElements(tagNames ...string) *Policy {
+	for _, name := range tagNames {
+		p.dropped[strings.ToLower(name)] = true
+	}
+	return p
+}
+
+// AllowURLSchemes allows the URLs with the given schemes, such as "https" or
+// "mailto", in the attributes holding URLs, such as href, src or srcset.
+// Attributes holding a URL with another scheme are removed.
+//
+// Beware that allowing schemes such as "javascript" or "data" defeats the purpose
+// of the sanitizer.
+func (p *Policy) AllowURLSchemes(schemes ...string) *Policy {
+	for _, scheme := range schemes {
+		p.schemes[strings.ToLower(scheme)] = true
+	}
+	return p
+}
+
+// AllowRelativeURLs sets whether URLs without scheme are allowed, which is the
+// case by default.
+func (p *Policy) AllowRelativeURLs(allow bool) *Policy {
+	p.noRelative = !allow
+	return p
+}
+
+// AddHook adds a function called on each element of the sanitized tree, before the
+// policy is applied to it. Hooks are called in the order they were added.
+//
+// A hook may rewrite the element, for instance to rename it, or to add or change
+// its attributes. The result is still subject to the policy. A hook may also
+// modify the content of the element, which is sanitized afterwards, but it must
+// not modify the rest of the tree. If a hook returns false, the element is
+// removed along with its content, and the next hooks are not called.
+func (p *Policy) AddHook(hook func(n *Node) bool) *Policy {
+	p.hooks = append(p.hooks, hook)
+	return p
+}
+
+// StrictPolicy returns a policy keeping only the text content: all the elements
+// are removed.
+func StrictPolicy() *Policy {
+	return NewPolicy()
+}
+
+// BasicPolicy returns a policy keeping the elements of basic text formatting,
+// such as paragraphs, emphasis, lists, quotes and code, without any attribute.
+func BasicPolicy() *Policy {
+	return NewPolicy().AllowElements(
+		"abbr", "b", "blockquote", "br", "cite", "code", "del", "dfn", "em", "i",
+		"ins", "kbd", "li", "mark", "ol", "p", "pre", "q", "s", "samp", "small",
+		"span", "strike", "strong", "sub", "sup", "u", "ul", "var",
+	)
+}
+
+// UGCPolicy returns a policy suitable for user-generated content, such as
+// comments or forum posts. It extends BasicPolicy with headings, tables, images
+// and links, along with their harmless attributes. URLs must be relative or use
+// the http, https or mailto schemes, and the rel="nofollow ugc" attribute is set
+// on all links.
+func UGCPolicy() *Policy {
+	p := BasicPolicy().
+		AllowElements(
+			"address", "article", "aside", "bdi", "bdo", "caption", "center", "dd",
+			"details", "div", "dl", "dt", "figcaption", "figure", "h1", "h2", "h3",
+			"h4", "h5", "h6", "hgroup", "hr", "rp", "rt", "ruby", "section",
+			"summary", "tbody", "tfoot", "thead", "time", "tr", "wbr",
+		).
+		AllowAttrs("a", "href", "hreflang", "rel").
+		AllowAttrs("blockquote", "cite").
+		AllowAttrs("col", "span").
+		AllowAttrs("colgroup", "span").
+		AllowAttrs("del", "cite", "datetime").
+		AllowAttrs("details", "open").
+		AllowAttrs("img", "alt", "height", "src", "srcset", "width").
+		AllowAttrs("ins", "cite", "datetime").
+		AllowAttrs("ol", "reversed", "start", "type").
+		AllowAttrs("q", "cite").
+		AllowAttrs("table", "summary").
+		AllowAttrs("td", "colspan", "headers", "rowspan").
+		AllowAttrs("th", "abbr", "colspan", "headers", "rowspan", "scope").
+		AllowAttrs("time", "datetime").
+		AllowGlobalAttrs("dir", "lang", "title").
+		AllowURLSchemes("http", "https", "mailto")
+	return p.AddHook(func(n *Node) bool {
+		if n.IsTag("a") && n.HasAttr("href") {
+			n.SetAttr("rel", "nofollow ugc")
+		}
+		return true
+	})
+}
+
+// sanitizeAction is what Sanitize does with a node.
+type sanitizeAction int
+
+const (
+	keepNode   sanitizeAction = iota
+	unwrapNode                // replace the node by its children
+	dropNode                  // remove the node along with its children
+)
+
+// Sanitize removes from the subtree of the given node all the nodes and
+// attributes that are not allowed by this policy. The node itself is kept as is:
+// it is typically the <body> of a document, or a container for a fragment. If it
+// is an element that can never be allowed, such as a <script>, or a foreign
+// element, all its children are removed.
+func (p *Policy) Sanitize(root *Node) {
+	if root.Type == ElementNode && (root.Namespace != "" || forbiddenElements[strings.ToLower(root.Data)]) {
+		root.RemoveChildren()
+		return
+	}
+	n := root.FirstChild
+	for n != nil {
+		switch p.action(n) {
+		case keepNode:
+			n = nextInDocumentOrder(n, root)
+		case unwrapNode:
+			next := n.FirstChild
+			if next == nil {
+				next = nextAfterSubtree(n, root)
+			}
+			for c := n.FirstChild; c != nil; c = n.FirstChild {
+				c.Detach()
+				n.Parent.link(c, n.PrevSibling, n)
+			}
+			n.Detach()
+			n = next
+		case dropNode:
+			next := nextAfterSubtree(n, root)
+			n.Detach()
+			n = next
+		}
+	}
+}
+
+// SanitizeString sanitizes the given HTML, parsed as the content of a <body>
+// element, and returns the resulting HTML.
+func (p *Policy) SanitizeString(s string) string {
+	body := &Node{Type: ElementNode, DataAtom: atom.Body, Data: "body"}
+	// parsing from a string in the context of an element cannot fail
+	_ = body.SetInnerHTML(s)
+	p.Sanitize(body)
+	return body.InnerHTML()
+}
+
+// action applies the hooks and the policy to the given node, and returns what
+// must be done with it.
+func (p *Policy) action(n *Node) sanitizeAction {
+	switch n.Type {
+	case TextNode, DoctypeNode:
+		return keepNode
+	case ElementNode:
+	default:
+		return dropNode
+	}
+	for _, hook := range p.hooks {
+		if !hook(n) {
+			return dropNode
+		}
+	}
+	name := strings.ToLower(n.Data)
+	if n.Type != ElementNode || n.Namespace != "" || forbiddenElements[name] {
+		return dropNode
+	}
+	attrs, ok := p.elements[name]
+	if !ok {
+		if p.dropped[name] {
+			return dropNode
+		}
+		return unwrapNode
+	}
+	n.Data = name
+	kept := n.Attrs[:0]
+	for _, a := range n.Attrs {
+		a.Key = strings.ToLower(a.Key)
+		if a.Namespace == "" && !isForbiddenAttr(a.Key) && (attrs[a.Key] || p.globalAttrs[a.Key]) &&
+			p.allowedURLs(a.Key, a.Val) {
+			kept = append(kept, a)
+		}
+	}
+	n.Attrs = kept
+	return keepNode
+}
+
+// allowedURLs returns true if the given attribute holds no URL, or only URLs
+// allowed by this policy.
+func (p *Policy) allowedURLs(key, val string) bool {
+	switch {
+	case key == "srcset":
+		// comma-separated candidates, made of a URL and optional descriptors
+		for candidate := range strings.SplitSeq(val, ",") {
+			if fields := strings.Fields(candidate); len(fields) > 0 && !p.allowedURL(fields[0]) {
+				return false
+			}
+		}
+	case key == "ping":
+		for u := range strings.FieldsSeq(val) {
+			if !p.allowedURL(u) {
+				return false
+			}
+		}
+	case urlAttrs[key]:
+		return p.allowedURL(val)
+	}
+	return true
+}
+
+// allowedURL returns true if the given URL is relative or has an allowed scheme.
+// The URL is read the way browsers do: leading and trailing control characters
+// and spaces are ignored, as well as tabs and newlines anywhere.
+func (p *Policy) allowedURL(u string) bool {
+	u = strings.TrimFunc(u, func(r rune) bool {
+		return r <= ' '
+	})
+	u = strings.Map(func(r rune) rune {
+		if r == '\t' || r == '\n' || r == '\r' {
+			return -1
+		}
+		return r
+	}, u)
+	i := strings.IndexAny(u, ":/?#")
+	if i < 0 || u[i] != ':' {
+		return !p.noRelative
+	}
+	scheme := u[:i]
+	return isURLScheme(scheme) && p.schemes[strings.ToLower(scheme)]
+}
+
+// isURLScheme returns true if s is a syntactically valid URL scheme.
+func isURLScheme(s string) bool {
+	for i, c := range []byte(s) {
+		switch {
+		case 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z':
+		case i > 0 && ('0' <= c && c <= '9' || c == '+' || c == '-' || c == '.'):
+		default:
+			return false
+		}
+	}
+	return s != ""
+}
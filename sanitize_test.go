@@ -0,0 +1,321 @@
+package gosoup
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSanitizePolicies(t *testing.T) {
+	input := `<h1 id="t">Title</h1><p class="x">Some <b>bold</b> and <a href="https://example.com" target="_blank">link</a>` +
+		`<script>alert(1)</script></p><!-- comment --><img src="/a.png" alt="A" onerror="alert(1)"><span title="t">text</span>`
+
+	assertEquals(t, "TitleSome bold and linktext", StrictPolicy().SanitizeString(input))
+	assertEquals(t, `Title<p>Some <b>bold</b> and link</p><span>text</span>`, BasicPolicy().SanitizeString(input))
+	assertEquals(t, `<h1>Title</h1><p>Some <b>bold</b> and <a href="https://example.com" rel="nofollow ugc">link</a></p>`+
+		`<img src="/a.png" alt="A"/><span title="t">text</span>`, UGCPolicy().SanitizeString(input))
+
+	// no whitespace is added where elements are removed
+	assertEquals(t, "a b", StrictPolicy().SanitizeString("<p>a</p> <p>b</p>"))
+}
+
+func TestSanitizeElementsAndAttributes(t *testing.T) {
+	p := NewPolicy().
+		AllowElements("P", "em").
+		AllowAttrs("a", "HREF").
+		AllowGlobalAttrs("title").
+		DropElements("aside", "em").
+		AllowURLSchemes("https")
+
+	assertEquals(t, `<p title="t">a</p><em>b</em>c`, p.SanitizeString(`<p title="t" lang="en">a</p><em>b</em><aside>x</aside><div>c</div>`))
+	assertEquals(t, `<a href="https://a">x</a><a href="/b">y</a><a>z</a>`,
+		p.SanitizeString(`<a href="https://a">x</a><a href="/b">y</a><a href="http://c">z</a>`))
+
+	p.AllowRelativeURLs(false)
+	assertEquals(t, `<a>y</a>`, p.SanitizeString(`<a href="/b">y</a>`))
+
+	// the root itself is not sanitized, a whole document loses its structure
+	doc := parseBody(t, `<title>t</title><p>a<u>b</u></p>`)
+	body := First(Filter(doc.DescendantNodes(), predicateIsTag("body")))
+	p.Sanitize(body)
+	assertConsistent(t, doc)
+	assertEquals(t, "<html><head><title>t</title></head><body><p>ab</p></body></html>", render(t, doc))
+	p.Sanitize(doc)
+	assertConsistent(t, doc)
+	assertEquals(t, "<p>ab</p>", render(t, doc))
+}
+
+func TestSanitizeNestedUnwrapping(t *testing.T) {
+	doc := parseBody(t, `<div><u><i><u>a</u>b</i></u><x><b>c</b><u></u></x>d</div>`)
+	body := First(Filter(doc.DescendantNodes(), predicateIsTag("body")))
+	NewPolicy().AllowElements("b").Sanitize(body)
+	assertConsistent(t, doc)
+	assertEquals(t, "ab<b>c</b>d", renderBody(t, doc))
+}
+
+func TestSanitizeHooks(t *testing.T) {
+	p := BasicPolicy().
+		AddHook(func(n *Node) bool {
+			// keep the strongest emphasis
+			switch n.Data {
+			case "b":
+				n.Data = "strong"
+			case "i":
+				n.Data = "em"
+			}
+			return true
+		}).
+		AddHook(func(n *Node) bool {
+			return !n.HasClass("spoiler")
+		}).
+		AddHook(func(n *Node) bool {
+			// the policy still applies to what the hooks produce
+			n.SetAttr("onclick", "alert(1)")
+			if n.IsTag("p") {
+				n.Data = "script"
+			}
+			return true
+		})
+	assertEquals(t, "<strong>a</strong> <em>b</em> c", p.SanitizeString(`<b>a</b> <i>b</i> <span class="spoiler">d</span>c<p>e</p>`))
+}
+
+func TestSanitizeProgrammaticTree(t *testing.T) {
+	div := &Node{Type: ElementNode, Data: "div"}
+	script := &Node{Type: ElementNode, Data: "SCRIPT"}
+	script.AppendChild(&Node{Type: TextNode, Data: "alert(1)"})
+	div.AppendChild(script)
+	injected := &Node{Type: ElementNode, Data: "img src=x onerror=alert(1)"}
+	injected.AppendChild(&Node{Type: TextNode, Data: "a"})
+	div.AppendChild(injected)
+	b := &Node{Type: ElementNode, Data: "B", Attrs: []Attribute{
+		{Key: "ONCLICK", Val: "alert(1)"},
+		{Key: "title onclick", Val: "alert(1)"},
+		{Key: "TITLE", Val: "t"},
+		{Namespace: "xlink", Key: "title", Val: "t"},
+	}}
+	b.AppendChild(&Node{Type: TextNode, Data: "b"})
+	div.AppendChild(b)
+	div.AppendChild(&Node{Type: CommentNode, Data: "c"})
+
+	BasicPolicy().AllowGlobalAttrs("title").Sanitize(div)
+	assertConsistent(t, div)
+	assertEquals(t, `a<b title="t">b</b>`, div.InnerHTML())
+}
+
+func TestSanitizeForbiddenRoot(t *testing.T) {
+	doc := parseBody(t, `<script>alert(1)</script><xmp><script>alert(1)</script></xmp><svg><title>t</title></svg><p><b>a</b></p>`)
+	for _, tag := range []string{"script", "xmp", "svg"} {
+		root := First(Filter(doc.DescendantNodes(), predicateIsTag(tag)))
+		BasicPolicy().Sanitize(root)
+		assertConsistent(t, root)
+		assertEquals(t, "", root.InnerHTML())
+	}
+	p := First(Filter(doc.DescendantNodes(), predicateIsTag("p")))
+	BasicPolicy().Sanitize(p)
+	assertEquals(t, "<b>a</b>", p.InnerHTML())
+}
+
+func TestSanitizeDeepTree(t *testing.T) {
+	depth := 100000
+	html := strings.Repeat("<div><x>", depth) + "x"
+	root := First(NewStream(strings.NewReader(html)).SubtreesByTag("div"))
+	BasicPolicy().AllowElements("div").Sanitize(root)
+	assertEquals(t, depth-1, len(root.DescendantsByTag("div").All()))
+	assertEquals(t, 0, len(root.DescendantsByTag("x").All()))
+}
+
+func TestPolicyPanics(t *testing.T) {
+	assertPanics(t, func() { NewPolicy().AllowElements("p", "Script") }, "expected a panic for <script>")
+	assertPanics(t, func() { NewPolicy().AllowElements("svg") }, "expected a panic for <svg>")
+	assertPanics(t, func() { NewPolicy().AllowAttrs("iframe", "src") }, "expected a panic for <iframe>")
+	assertPanics(t, func() { NewPolicy().AllowAttrs("img", "onError") }, "expected a panic for onerror")
+	assertPanics(t, func() { NewPolicy().AllowAttrs("div", "style") }, "expected a panic for style")
+	assertPanics(t, func() { NewPolicy().AllowGlobalAttrs("title", "onclick") }, "expected a panic for onclick")
+}
+
+// xssVectors are known ways of injecting script in HTML.
+var xssVectors = []string{
+	`<script>alert(1)</script>`,
+	`<SCRIPT SRC=http://xss.example/xss.js></SCRIPT>`,
+	`<scr<script>ipt>alert(1)</script>`,
+	`<<script>alert(1);//<</script>`,
+	`<img src=x onerror=alert(1)>`,
+	`<img src=x:alert(alt) onerror=eval(src) alt=0>`,
+	`<IMG SRC="javascript:alert('XSS');">`,
+	`<IMG SRC=JaVaScRiPt:alert('XSS')>`,
+	`<IMG SRC=&#106;&#97;&#118;&#97;&#115;&#99;&#114;&#105;&#112;&#116;&#58;&#97;&#108;&#101;&#114;&#116;&#40;&#39;&#88;&#83;&#83;&#39;&#41;>`,
+	`<IMG SRC=&#0000106&#0000097&#0000118&#0000097&#0000115&#0000099&#0000114&#0000105&#0000112&#0000116&#0000058&#0000097&#0000108&#0000101&#0000114&#0000116&#0000040&#0000039&#0000088&#0000083&#0000083&#0000039&#0000041>`,
+	`<IMG SRC=&#x6A&#x61&#x76&#x61&#x73&#x63&#x72&#x69&#x70&#x74&#x3A&#x61&#x6C&#x65&#x72&#x74&#x28&#x27&#x58&#x53&#x53&#x27&#x29>`,
+	"<IMG SRC=\"jav\tascript:alert('XSS');\">",
+	`<IMG SRC="jav&#x09;ascript:alert('XSS');">`,
+	`<IMG SRC="jav&#x0A;ascript:alert('XSS');">`,
+	`<IMG SRC="jav&#x0D;ascript:alert('XSS');">`,
+	`<IMG SRC=" &#14;  javascript:alert('XSS');">`,
+	"<a href=\"\x00javascript:alert(1)\">x</a>",
+	`<a href="&#1;javascript:alert(1)">x</a>`,
+	`<a href="javascript&colon;alert(1)">x</a>`,
+	`<a href="java&#0000010script:alert(1)">x</a>`,
+	`<a href="  JAVASCRIPT:alert(1)">x</a>`,
+	`<a href=javascript:alert(1)//http://example.com>x</a>`,
+	`<a/href="javascript:alert(1)">x</a>`,
+	`<a href="vbscript:msgbox(1)">x</a>`,
+	`<a href="data:text/html;base64,PHNjcmlwdD5hbGVydCgxKTwvc2NyaXB0Pg==">x</a>`,
+	`<a href="#" onclick="alert(1)">x</a>`,
+	`<a href="https://example.com" ping="javascript:alert(1)">x</a>`,
+	`<img srcset="x.png 1x, javascript:alert(1) 2x">`,
+	`<img srcset="javascript:alert(1)">`,
+	`<img dynsrc="javascript:alert(1)" lowsrc="javascript:alert(1)">`,
+	`<input type=image src=javascript:alert(1)>`,
+	`<area href=javascript:alert(1)>`,
+	`<blockquote cite="javascript:alert(1)">x</blockquote>`,
+	`<video poster=javascript:alert(1)><source onerror="alert(1)"></video>`,
+	`<table background="javascript:alert(1)"><tr><td background="javascript:alert(1)">x</td></tr></table>`,
+	`<form action="javascript:alert(1)"><input type=submit></form>`,
+	`<form><button formaction=javascript:alert(1)>x</button></form>`,
+	`<isindex type=image src=1 onerror=alert(1)>`,
+	`<details open ontoggle=alert(1)>`,
+	`<marquee onstart=alert(1)>x</marquee>`,
+	`<body onload=alert(1)>`,
+	`<frameset onload=alert(1)>`,
+	`<div onmouseover="alert(1)">x</div>`,
+	`<x onclick=alert(1)>x</x>`,
+	`<div style="background:url(javascript:alert(1))">x</div>`,
+	`<div style="width: expression(alert(1))">x</div>`,
+	`<style>@import 'http://xss.example/xss.css';</style>`,
+	`<link rel=stylesheet href="javascript:alert(1)">`,
+	`<meta http-equiv="refresh" content="0;url=javascript:alert(1)">`,
+	`<base href="javascript:alert(1)//">`,
+	`<iframe src="javascript:alert(1)"></iframe>`,
+	`<iframe srcdoc="<script>alert(1)</script>"></iframe>`,
+	`<object data="javascript:alert(1)"></object>`,
+	`<embed src="javascript:alert(1)">`,
+	`<applet code="x.class"></applet>`,
+	`<bgsound src="javascript:alert(1)">`,
+	`<svg onload=alert(1)>`,
+	`<svg><script>alert(1)</script></svg>`,
+	`<svg><a xlink:href="javascript:alert(1)"><text>x</text></a></svg>`,
+	`<svg><animate onbegin=alert(1) attributeName=x dur=1s>`,
+	`<math href="javascript:alert(1)">x</math>`,
+	`<math><mtext><table><mglyph><style><img src=x onerror=alert(1)>`,
+	`<form><math><mtext></form><form><mglyph><style></math><img src onerror=alert(1)>`,
+	`<noscript><p title="</noscript><img src=x onerror=alert(1)>">`,
+	`<select><style></select><img src=x onerror=alert(1)></style>`,
+	`<xmp><img src=x onerror=alert(1)></xmp>`,
+	`<textarea><img src=x onerror=alert(1)></textarea>`,
+	`<title><img src=x onerror=alert(1)></title>`,
+	`<template><img src=x onerror=alert(1)></template>`,
+	`<noembed><img src=x onerror=alert(1)></noembed>`,
+	`<plaintext><img src=x onerror=alert(1)>`,
+	`<!--<img src="--><img src=x onerror=alert(1)//">`,
+	`<![CDATA[<script>alert(1)</script>]]>`,
+	`<img """><script>alert(1)</script>">`,
+	"<img src=\"x` `<script>alert(1)</script>\"` `>",
+	`<a href="https://example.com" target=_blank rel=opener>x</a>`,
+}
+
+// permissivePolicy allows most of what a policy can allow, including the
+// elements and attributes used by the XSS vectors.
+func permissivePolicy() *Policy {
+	return UGCPolicy().
+		AllowElements("marquee", "x", "body", "isindex", "bgsound", "select", "option").
+		AllowAttrs("a", "ping", "target").
+		AllowAttrs("area", "href").
+		AllowAttrs("button", "formaction").
+		AllowAttrs("form", "action").
+		AllowAttrs("img", "dynsrc", "lowsrc").
+		AllowAttrs("input", "formaction", "src", "type").
+		AllowAttrs("source", "src", "srcset").
+		AllowAttrs("table", "background").
+		AllowAttrs("td", "background").
+		AllowAttrs("textarea", "name").
+		AllowAttrs("video", "poster", "src").
+		AllowGlobalAttrs("class", "id", "src", "href", "alt")
+}
+
+// assertCannotExecuteScript parses the given HTML as a browser would, and checks
+// that it contains no way of executing script.
+func assertCannotExecuteScript(t *testing.T, html, vector string) {
+	t.Helper()
+	body := &Node{Type: ElementNode, Data: "body"}
+	if err := body.SetInnerHTML(html); err != nil {
+		t.Fatal(err)
+	}
+	for n := range body.DescendantNodes() {
+		switch n.Type {
+		case TextNode:
+			continue
+		case ElementNode:
+		default:
+			t.Errorf("%q: unexpected node %q in %q", vector, n.Data, html)
+			continue
+		}
+		switch {
+		case n.Namespace != "":
+			t.Errorf("%q: unexpected foreign element <%s> in %q", vector, n.Data, html)
+		case forbiddenElements[n.Data]:
+			t.Errorf("%q: unexpected element <%s> in %q", vector, n.Data, html)
+		}
+		for _, a := range n.Attrs {
+			key := strings.ToLower(a.Key)
+			if strings.HasPrefix(key, "on") || key == "style" || key == "srcdoc" {
+				t.Errorf("%q: unexpected attribute %s in %q", vector, key, html)
+			}
+			for _, u := range strings.Split(a.Val, ",") {
+				// the scheme as read by browsers
+				u = strings.TrimLeftFunc(u, func(r rune) bool { return r <= ' ' })
+				u = strings.ToLower(strings.NewReplacer("\t", "", "\n", "", "\r", "").Replace(u))
+				if strings.HasPrefix(u, "javascript:") || strings.HasPrefix(u, "vbscript:") || strings.HasPrefix(u, "data:") {
+					t.Errorf("%q: unexpected URL in attribute %s in %q", vector, key, html)
+				}
+			}
+		}
+	}
+}
+
+func TestSanitizeXSSVectors(t *testing.T) {
+	policies := map[string]*Policy{
+		"strict":     StrictPolicy(),
+		"basic":      BasicPolicy(),
+		"ugc":        UGCPolicy(),
+		"permissive": permissivePolicy(),
+	}
+	for name, p := range policies {
+		t.Run(name, func(t *testing.T) {
+			for _, vector := range xssVectors {
+				sanitized := p.SanitizeString(vector)
+				assertCannotExecuteScript(t, sanitized, vector)
+				// sanitizing again must not change anything
+				assertEqualsWithMsg(t, sanitized, p.SanitizeString(sanitized), vector)
+			}
+		})
+	}
+}
+
+func TestSanitizeKeepsSafeURLs(t *testing.T) {
+	p := UGCPolicy()
+	for _, link := range []string{
+		`https://example.com/a?b=c#d`,
+		`HTTP://example.com`,
+		`mailto:someone@example.com`,
+		`/relative/path`,
+		`relative/path:with/colon`,
+		`?query`,
+		`#fragment`,
+		`//example.com/protocol-relative`,
+	} {
+		a := `<a href="` + link + `" rel="nofollow ugc">x</a>`
+		assertEquals(t, a, p.SanitizeString(a))
+	}
+	img := `<img srcset="a.png 1x, https://example.com/b.png 2x"/>`
+	assertEquals(t, img, p.SanitizeString(img))
+}
+
+func FuzzSanitize(f *testing.F) {
+	for _, vector := range xssVectors {
+		f.Add(vector)
+	}
+	p := permissivePolicy()
+	f.Fuzz(func(t *testing.T, s string) {
+		sanitized := p.SanitizeString(s)
+		assertCannotExecuteScript(t, sanitized, s)
+	})
+}
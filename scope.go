@@ -0,0 +1,122 @@
+package gosoup
+
+import (
+	"fmt"
+	a "golang.org/x/net/html/atom"
+)
+
+// maxScannedScope is the depth of the stack of open elements up to which the
+// parser scans it to find the elements in scope. Above it, the parser indexes
+// the stack: scanning it for every start tag would make parsing deeply nested
+// elements quadratic.
+const maxScannedScope = 64
+
+// A scopeIndex indexes the stack of open elements of a parser by namespace and
+// tag. It is not part of the port of the html package: it is built on demand by
+// indexedScope, and dropped by openElementsShifted when the stack is modified in
+// the middle. Otherwise, the elements are only pushed and popped, and sync finds
+// the part of the stack that changed from the top.
+type scopeIndex struct {
+	// oe is the stack of open elements as indexed.
+	oe nodeStack
+	// keys holds the namespace and tag of the elements of oe, as indexed.
+	keys []elementKey
+	// open holds the indices in oe of the elements with each namespace and tag.
+	open map[elementKey][]int
+}
+
+type elementKey struct {
+	namespace string
+	tagAtom   a.Atom
+}
+
+// indexedScope returns the index of the stack of open elements, up to date.
+func (p *parser) indexedScope() *scopeIndex {
+	if p.scopes == nil {
+		p.scopes = &scopeIndex{open: make(map[elementKey][]int)}
+	}
+	p.scopes.sync(p.oe)
+	return p.scopes
+}
+
+// openElementsShifted is called when the stack of open elements is modified
+// other than by pushing or popping elements, which can move the elements to other
+// indices. It drops the index of the stack.
+func (p *parser) openElementsShifted() {
+	p.scopes = nil
+	p.shifts++
+}
+
+// removeOpenElement removes n from the stack of open elements. It is a no-op if
+// n is not present.
+func (p *parser) removeOpenElement(n *Node) {
+	p.oe.remove(n)
+	p.openElementsShifted()
+}
+
+// sync updates the index to oe. Except for the head element, which is removed by
+// shifting the stack, an element is pushed only once: if it is at the same index
+// in both stacks, it has not been popped, nor have the elements below it.
+func (s *scopeIndex) sync(oe nodeStack) {
+	i := min(len(s.oe), len(oe))
+	for i > 0 && s.oe[i-1] != oe[i-1] {
+		i--
+	}
+	for j := len(s.oe) - 1; j >= i; j-- {
+		indices := s.open[s.keys[j]]
+		s.open[s.keys[j]] = indices[:len(indices)-1]
+	}
+	s.oe, s.keys = s.oe[:i], s.keys[:i]
+	for _, n := range oe[i:] {
+		key := elementKey{n.Namespace, n.DataAtom}
+		s.open[key] = append(s.open[key], len(s.oe))
+		s.oe = append(s.oe, n)
+		s.keys = append(s.keys, key)
+	}
+}
+
+// top returns the index of the highest element with the given namespace and
+// tag, or -1 if there is none.
+func (s *scopeIndex) top(namespace string, tagAtom a.Atom) int {
+	if indices := s.open[elementKey{namespace, tagAtom}]; len(indices) > 0 {
+		return indices[len(indices)-1]
+	}
+	return -1
+}
+
+// indexOfElementInScope is like parser.indexOfElementInScope.
+func (s *scopeIndex) indexOfElementInScope(sc scope, matchTags []a.Atom) int {
+	match := -1
+	for _, t := range matchTags {
+		match = max(match, s.top("", t))
+	}
+	if match == -1 {
+		return -1
+	}
+	stop := -1
+	switch sc {
+	case defaultScope:
+		// No-op.
+	case listItemScope:
+		stop = max(s.top("", a.Ol), s.top("", a.Ul))
+	case buttonScope:
+		stop = s.top("", a.Button)
+	case tableScope:
+		stop = max(s.top("", a.Html), s.top("", a.Table), s.top("", a.Template))
+	default:
+		panic(fmt.Sprintf("html: internal error: indexOfElementInScope unknown scope: %d", sc))
+	}
+	switch sc {
+	case defaultScope, listItemScope, buttonScope:
+		for namespace, tags := range defaultScopeStopTags {
+			for _, t := range tags {
+				stop = max(stop, s.top(namespace, t))
+			}
+		}
+	}
+	// An element matching the tags is found before it is checked as a stop tag.
+	if match < stop {
+		return -1
+	}
+	return match
+}
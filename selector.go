@@ -0,0 +1,926 @@
+package gosoup
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// SelectorError is returned when a CSS selector cannot be compiled.
+type SelectorError struct {
+	Selector string // the selector that was being compiled
+	Offset   int    // byte offset in Selector where the error was detected
+	Msg      string // description of the problem
+	Node     *Node  // the node the selector was applied to, if any
+}
+
+func (e *SelectorError) Error() string {
+	if e.Node != nil {
+		return fmt.Sprintf("selector %q: %s at offset %d, applied to %s", e.Selector, e.Msg, e.Offset, describeNode(e.Node))
+	}
+	return fmt.Sprintf("selector %q: %s at offset %d", e.Selector, e.Msg, e.Offset)
+}
+
+// Selector is a compiled CSS selector list, which can be matched against nodes.
+//
+// The supported syntax covers most of CSS Selectors Level 3 and the most useful
+// parts of Level 4:
+//
+//   - type selectors and the universal selector: div, *
+//   - class and id selectors: .article, #main
+//   - attribute selectors: [href], [lang|=en], [class~=x], [href^="https"],
+//     [src$=".png"], [title*=foo], with the optional i and s flags
+//   - combinators: descendant (whitespace), child (>), next sibling (+) and
+//     subsequent sibling (~)
+//   - structural pseudo-classes: :root, :empty, :first-child, :last-child,
+//     :only-child, :first-of-type, :last-of-type, :only-of-type, :nth-child(),
+//     :nth-last-child() (both accepting "An+B of S"), :nth-of-type() and
+//     :nth-last-of-type()
+//   - logical pseudo-classes: :not(), :is(), :where() and :has() (the latter
+//     accepting relative selectors such as ":has(> img)")
+//   - :scope, :link, :any-link, :checked, :disabled and :enabled
+//
+// Namespace prefixes and pseudo-elements are not supported.
+type Selector struct {
+	source    string
+	selectors []complexSelector
+}
+
+// CompileSelector parses the given CSS selector list. If the selector is invalid,
+// the returned error is a *SelectorError giving the position of the problem.
+func CompileSelector(selector string) (*Selector, error) {
+	p := &selectorParser{src: selector}
+	list, err := p.parseSelectorList(false)
+	if err != nil {
+		return nil, err
+	}
+	if !p.eof() {
+		return nil, p.errorf(p.pos, "unexpected %q", p.peek())
+	}
+	return &Selector{selector, list}, nil
+}
+
+// MustCompileSelector is like CompileSelector but panics if the selector cannot
+// be compiled. It simplifies the initialization of global selector variables.
+func MustCompileSelector(selector string) *Selector {
+	s, err := CompileSelector(selector)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// String returns the source text of this selector.
+func (s *Selector) String() string {
+	return s.source
+}
+
+// Match returns true if the given node is an element matching this selector.
+func (s *Selector) Match(node *Node) bool {
+	return s.matchScoped(node, nil)
+}
+
+func (s *Selector) matchScoped(node *Node, scope *Node) bool {
+	return matchAny(s.selectors, node, scope)
+}
+
+// Select returns an iterator on this node's descendant elements that match the
+// given CSS selector, in depth-first order.
+//
+// Within the selector, :scope refers to this node (or to the root element if this
+// node is the document node).
+func (node *Node) Select(selector string) (NodeIterator, error) {
+	s, err := CompileSelector(selector)
+	if err != nil {
+		if selErr, ok := err.(*SelectorError); ok {
+			selErr.Node = node
+		}
+		return emptyNodeIterator(), err
+	}
+	return node.SelectCompiled(s), nil
+}
+
+// SelectFirst returns the first descendant element of this node that matches the
+// given CSS selector, in depth-first order, or nil if no element matches.
+func (node *Node) SelectFirst(selector string) (*Node, error) {
+	it, err := node.Select(selector)
+	if err != nil {
+		return nil, err
+	}
+	return it.First(), nil
+}
+
+// SelectCompiled returns an iterator on this node's descendant elements that
+// match the given compiled selector, in depth-first order.
+func (node *Node) SelectCompiled(s *Selector) NodeIterator {
+	scope := node
+	if node.Type == DocumentNode {
+		scope = nil
+	}
+	return node.DescendantsMatching(func(n *Node) bool {
+		return s.matchScoped(n, scope)
+	})
+}
+
+type combinator byte
+
+const (
+	descendantCombinator        combinator = ' '
+	childCombinator             combinator = '>'
+	nextSiblingCombinator       combinator = '+'
+	subsequentSiblingCombinator combinator = '~'
+)
+
+// simpleSelector matches a single condition on an element. The scope is the
+// element :scope refers to, or nil if it refers to the root element.
+type simpleSelector func(n, scope *Node) bool
+
+// compoundSelector is a sequence of simple selectors that must all match the
+// same element. An empty compound selector matches any element.
+type compoundSelector []simpleSelector
+
+func (cs compoundSelector) match(n, scope *Node) bool {
+	if n.Type != ElementNode {
+		return false
+	}
+	for _, s := range cs {
+		if !s(n, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+// complexSelector is a chain of compound selectors separated by combinators:
+// combinators[i] sits between compounds[i] and compounds[i+1].
+type complexSelector struct {
+	compounds   []compoundSelector
+	combinators []combinator
+}
+
+func (cs *complexSelector) match(n, scope *Node) bool {
+	return cs.matchAt(n, len(cs.compounds)-1, scope)
+}
+
+// matchAt matches the selector from right to left, starting at the compound of
+// index i, backtracking when needed.
+func (cs *complexSelector) matchAt(n *Node, i int, scope *Node) bool {
+	if !cs.compounds[i].match(n, scope) {
+		return false
+	}
+	if i == 0 {
+		return true
+	}
+	switch cs.combinators[i-1] {
+	case descendantCombinator:
+		for p := n.Parent; p != nil && p.Type == ElementNode; p = p.Parent {
+			if cs.matchAt(p, i-1, scope) {
+				return true
+			}
+		}
+	case childCombinator:
+		if p := n.Parent; p != nil && p.Type == ElementNode {
+			return cs.matchAt(p, i-1, scope)
+		}
+	case nextSiblingCombinator:
+		if s := prevElementSibling(n); s != nil {
+			return cs.matchAt(s, i-1, scope)
+		}
+	case subsequentSiblingCombinator:
+		for s := prevElementSibling(n); s != nil; s = prevElementSibling(s) {
+			if cs.matchAt(s, i-1, scope) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func matchAny(list []complexSelector, n, scope *Node) bool {
+	for i := range list {
+		if list[i].match(n, scope) {
+			return true
+		}
+	}
+	return false
+}
+
+func prevElementSibling(n *Node) *Node {
+	for s := n.PrevSibling; s != nil; s = s.PrevSibling {
+		if s.Type == ElementNode {
+			return s
+		}
+	}
+	return nil
+}
+
+func nextElementSibling(n *Node) *Node {
+	for s := n.NextSibling; s != nil; s = s.NextSibling {
+		if s.Type == ElementNode {
+			return s
+		}
+	}
+	return nil
+}
+
+// selectorParser is a recursive-descent parser for CSS selector lists.
+type selectorParser struct {
+	src string
+	pos int
+}
+
+func (p *selectorParser) errorf(pos int, format string, args ...interface{}) error {
+	return &SelectorError{Selector: p.src, Offset: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *selectorParser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+// peek returns the current byte, or 0 at the end of the input.
+func (p *selectorParser) peek() byte {
+	return p.peekAt(0)
+}
+
+func (p *selectorParser) peekAt(offset int) byte {
+	if p.pos+offset >= len(p.src) {
+		return 0
+	}
+	return p.src[p.pos+offset]
+}
+
+// unexpected returns an error describing the current byte (or the end of input).
+func (p *selectorParser) unexpected(expected string) error {
+	if p.eof() {
+		return p.errorf(p.pos, "unexpected end of selector, expected %s", expected)
+	}
+	return p.errorf(p.pos, "unexpected %q, expected %s", p.peek(), expected)
+}
+
+func isSelectorWhitespace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+// skipWhitespace skips whitespace and returns true if any was found.
+func (p *selectorParser) skipWhitespace() bool {
+	start := p.pos
+	for !p.eof() && isSelectorWhitespace(p.peek()) {
+		p.pos++
+	}
+	return p.pos > start
+}
+
+// parseSelectorList parses comma-separated complex selectors. Relative selectors
+// (as in :has()) may start with a combinator.
+func (p *selectorParser) parseSelectorList(relative bool) ([]complexSelector, error) {
+	var list []complexSelector
+	for {
+		p.skipWhitespace()
+		cs, err := p.parseComplex(relative)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, cs)
+		p.skipWhitespace()
+		if p.peek() != ',' {
+			return list, nil
+		}
+		p.pos++
+	}
+}
+
+func (p *selectorParser) parseComplex(relative bool) (complexSelector, error) {
+	var cs complexSelector
+	if relative {
+		cs.compounds = append(cs.compounds, compoundSelector{matchScope})
+		comb := descendantCombinator
+		if c := p.peek(); c == '>' || c == '+' || c == '~' {
+			comb = combinator(c)
+			p.pos++
+			p.skipWhitespace()
+		}
+		cs.combinators = append(cs.combinators, comb)
+	}
+	for {
+		compound, err := p.parseCompound()
+		if err != nil {
+			return cs, err
+		}
+		cs.compounds = append(cs.compounds, compound)
+
+		hadSpace := p.skipWhitespace()
+		var comb combinator
+		switch c := p.peek(); c {
+		case '>', '+', '~':
+			comb = combinator(c)
+			p.pos++
+			p.skipWhitespace()
+		case 0, ',', ')':
+			return cs, nil
+		default:
+			if !hadSpace {
+				return cs, p.errorf(p.pos, "unexpected %q", c)
+			}
+			comb = descendantCombinator
+		}
+		cs.combinators = append(cs.combinators, comb)
+	}
+}
+
+func (p *selectorParser) parseCompound() (compoundSelector, error) {
+	start := p.pos
+	var cs compoundSelector
+	if p.peek() == '*' {
+		p.pos++
+	} else if p.startsIdent() {
+		cs = append(cs, matchTag(p.parseName()))
+	}
+	if p.peek() == '|' {
+		return nil, p.errorf(p.pos, "namespace prefixes are not supported")
+	}
+	for {
+		var s simpleSelector
+		var err error
+		switch p.peek() {
+		case '#':
+			p.pos++
+			if !p.startsName() {
+				return nil, p.unexpected("identifier after '#'")
+			}
+			s = matchID(p.parseName())
+		case '.':
+			p.pos++
+			if !p.startsIdent() {
+				return nil, p.unexpected("identifier after '.'")
+			}
+			s = matchClass(p.parseName())
+		case '[':
+			s, err = p.parseAttribute()
+		case ':':
+			s, err = p.parsePseudo()
+		default:
+			if p.pos == start {
+				return nil, p.unexpected("selector")
+			}
+			return cs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		cs = append(cs, s)
+	}
+}
+
+func isNameStart(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c >= utf8.RuneSelf
+}
+
+func isNameChar(c byte) bool {
+	return isNameStart(c) || c >= '0' && c <= '9' || c == '-'
+}
+
+func (p *selectorParser) isValidEscapeAt(offset int) bool {
+	return p.peekAt(offset) == '\\' && p.pos+offset+1 < len(p.src) && p.peekAt(offset+1) != '\n'
+}
+
+// startsIdent returns true if an identifier starts at the current position.
+func (p *selectorParser) startsIdent() bool {
+	c := p.peek()
+	if c == '-' {
+		next := p.peekAt(1)
+		return isNameStart(next) || next == '-' || p.isValidEscapeAt(1)
+	}
+	return isNameStart(c) || p.isValidEscapeAt(0)
+}
+
+// startsName returns true if a name (which may start with a digit) starts at the
+// current position.
+func (p *selectorParser) startsName() bool {
+	return isNameChar(p.peek()) || p.isValidEscapeAt(0)
+}
+
+// parseName consumes a sequence of name characters and escapes.
+func (p *selectorParser) parseName() string {
+	var b strings.Builder
+	for !p.eof() {
+		c := p.peek()
+		switch {
+		case isNameChar(c):
+			b.WriteByte(c)
+			p.pos++
+		case p.isValidEscapeAt(0):
+			p.pos++
+			b.WriteRune(p.parseEscape())
+		default:
+			return b.String()
+		}
+	}
+	return b.String()
+}
+
+// parseEscape parses the escape sequence following a backslash.
+func (p *selectorParser) parseEscape() rune {
+	start := p.pos
+	for p.pos < len(p.src) && p.pos-start < 6 && isHexDigit(p.peek()) {
+		p.pos++
+	}
+	if p.pos == start {
+		r, size := utf8.DecodeRuneInString(p.src[p.pos:])
+		p.pos += size
+		return r
+	}
+	code, _ := strconv.ParseUint(p.src[start:p.pos], 16, 32)
+	if !p.eof() && isSelectorWhitespace(p.peek()) {
+		p.pos++
+	}
+	if code == 0 || code > utf8.MaxRune || code >= 0xD800 && code <= 0xDFFF {
+		return utf8.RuneError
+	}
+	return rune(code)
+}
+
+func isHexDigit(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+// parseString parses a single- or double-quoted string.
+func (p *selectorParser) parseString() (string, error) {
+	start := p.pos
+	quote := p.peek()
+	p.pos++
+	var b strings.Builder
+	for {
+		if p.eof() {
+			return "", p.errorf(start, "unterminated string")
+		}
+		c := p.peek()
+		switch {
+		case c == quote:
+			p.pos++
+			return b.String(), nil
+		case c == '\n':
+			return "", p.errorf(start, "unterminated string")
+		case c == '\\':
+			p.pos++
+			if p.eof() {
+				continue
+			}
+			if p.peek() == '\n' {
+				p.pos++
+				continue
+			}
+			b.WriteRune(p.parseEscape())
+		default:
+			b.WriteByte(c)
+			p.pos++
+		}
+	}
+}
+
+func (p *selectorParser) parseAttribute() (simpleSelector, error) {
+	start := p.pos
+	p.pos++ // '['
+	p.skipWhitespace()
+	if !p.startsIdent() {
+		return nil, p.unexpected("attribute name")
+	}
+	name := p.parseName()
+	p.skipWhitespace()
+
+	if p.peek() == ']' {
+		p.pos++
+		return matchAttrExists(name), nil
+	}
+
+	var op string
+	switch c := p.peek(); c {
+	case '=':
+		op = "="
+		p.pos++
+	case '~', '|', '^', '$', '*':
+		if p.peekAt(1) != '=' {
+			if c == '|' {
+				return nil, p.errorf(p.pos, "namespace prefixes are not supported")
+			}
+			return nil, p.errorf(p.pos, "unknown attribute operator")
+		}
+		op = p.src[p.pos : p.pos+2]
+		p.pos += 2
+	default:
+		return nil, p.unexpected("attribute operator or ']'")
+	}
+	p.skipWhitespace()
+
+	var value string
+	switch c := p.peek(); {
+	case c == '"' || c == '\'':
+		var err error
+		if value, err = p.parseString(); err != nil {
+			return nil, err
+		}
+	case p.startsIdent():
+		value = p.parseName()
+	default:
+		return nil, p.unexpected("attribute value")
+	}
+	hadSpace := p.skipWhitespace()
+
+	ignoreCase := false
+	if hadSpace && p.startsIdent() {
+		flagPos := p.pos
+		switch strings.ToLower(p.parseName()) {
+		case "i":
+			ignoreCase = true
+		case "s":
+		default:
+			return nil, p.errorf(flagPos, "unknown attribute selector flag")
+		}
+		p.skipWhitespace()
+	}
+	if p.peek() != ']' {
+		if p.eof() {
+			return nil, p.errorf(start, "unterminated attribute selector")
+		}
+		return nil, p.unexpected("']'")
+	}
+	p.pos++
+	return matchAttr(name, op, value, ignoreCase), nil
+}
+
+func (p *selectorParser) parsePseudo() (simpleSelector, error) {
+	start := p.pos
+	p.pos++ // ':'
+	if p.peek() == ':' {
+		return nil, p.errorf(start, "pseudo-elements are not supported")
+	}
+	if !p.startsIdent() {
+		return nil, p.unexpected("pseudo-class name")
+	}
+	name := strings.ToLower(p.parseName())
+	if p.peek() != '(' {
+		s, ok := simplePseudoClasses[name]
+		if !ok {
+			return nil, p.errorf(start, "unknown pseudo-class :%s", name)
+		}
+		return s, nil
+	}
+	p.pos++ // '('
+	p.skipWhitespace()
+
+	var s simpleSelector
+	var err error
+	switch name {
+	case "not", "is", "where", "has":
+		var list []complexSelector
+		list, err = p.parseSelectorList(name == "has")
+		if err != nil {
+			return nil, err
+		}
+		switch name {
+		case "not":
+			s = func(n, scope *Node) bool { return !matchAny(list, n, scope) }
+		case "is", "where":
+			s = func(n, scope *Node) bool { return matchAny(list, n, scope) }
+		case "has":
+			s = matchHas(list)
+		}
+	case "nth-child", "nth-last-child", "nth-of-type", "nth-last-of-type":
+		s, err = p.parseNth(name)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, p.errorf(start, "unknown pseudo-class :%s()", name)
+	}
+	p.skipWhitespace()
+	if p.peek() != ')' {
+		return nil, p.unexpected("')'")
+	}
+	p.pos++
+	return s, nil
+}
+
+// parseNth parses the argument of the :nth-* pseudo-classes, in the form
+// "An+B", optionally followed by "of S" for :nth-child and :nth-last-child.
+func (p *selectorParser) parseNth(name string) (simpleSelector, error) {
+	a, b, err := p.parseAnPlusB()
+	if err != nil {
+		return nil, err
+	}
+	p.skipWhitespace()
+
+	var of []complexSelector
+	if (name == "nth-child" || name == "nth-last-child") && p.startsIdent() {
+		kwPos := p.pos
+		if kw := strings.ToLower(p.parseName()); kw != "of" {
+			return nil, p.errorf(kwPos, "unexpected %q, expected \"of\" or ')'", kw)
+		}
+		if !p.skipWhitespace() {
+			return nil, p.unexpected("whitespace after \"of\"")
+		}
+		if of, err = p.parseSelectorList(false); err != nil {
+			return nil, err
+		}
+	}
+
+	fromEnd := strings.HasPrefix(name, "nth-last-")
+	ofType := strings.HasSuffix(name, "-of-type")
+	return func(n, scope *Node) bool {
+		if of != nil && !matchAny(of, n, scope) {
+			return false
+		}
+		sibling := prevElementSibling
+		if fromEnd {
+			sibling = nextElementSibling
+		}
+		index := 1
+		for s := sibling(n); s != nil; s = sibling(s) {
+			switch {
+			case ofType:
+				if s.Data == n.Data && s.Namespace == n.Namespace {
+					index++
+				}
+			case of != nil:
+				if matchAny(of, s, scope) {
+					index++
+				}
+			default:
+				index++
+			}
+		}
+		return nthMatches(a, b, index)
+	}, nil
+}
+
+// nthMatches returns true if index = a*n + b for some integer n >= 0.
+func nthMatches(a, b, index int) bool {
+	if a == 0 {
+		return index == b
+	}
+	diff := index - b
+	return diff/a >= 0 && diff%a == 0
+}
+
+func (p *selectorParser) parseAnPlusB() (a, b int, err error) {
+	if p.startsIdent() && p.peek() != 'n' && p.peek() != 'N' && p.peek() != '-' {
+		kwPos := p.pos
+		switch strings.ToLower(p.parseName()) {
+		case "odd":
+			return 2, 1, nil
+		case "even":
+			return 2, 0, nil
+		default:
+			return 0, 0, p.errorf(kwPos, "invalid An+B expression")
+		}
+	}
+
+	start := p.pos
+	sign := 1
+	switch p.peek() {
+	case '+':
+		p.pos++
+	case '-':
+		sign = -1
+		p.pos++
+	}
+	digits := p.parseDigits()
+	if c := p.peek(); c == 'n' || c == 'N' {
+		p.pos++
+		a = sign
+		if digits != "" {
+			n, _ := strconv.Atoi(digits)
+			a = sign * n
+		}
+		p.skipWhitespace()
+		bSign := 0
+		switch p.peek() {
+		case '+':
+			bSign = 1
+		case '-':
+			bSign = -1
+		}
+		if bSign == 0 {
+			return a, 0, nil
+		}
+		p.pos++
+		p.skipWhitespace()
+		bDigits := p.parseDigits()
+		if bDigits == "" {
+			return 0, 0, p.unexpected("integer")
+		}
+		b, _ = strconv.Atoi(bDigits)
+		return a, bSign * b, nil
+	}
+	if digits == "" {
+		return 0, 0, p.errorf(start, "invalid An+B expression")
+	}
+	b, _ = strconv.Atoi(digits)
+	return 0, sign * b, nil
+}
+
+func (p *selectorParser) parseDigits() string {
+	start := p.pos
+	for !p.eof() && p.peek() >= '0' && p.peek() <= '9' {
+		p.pos++
+	}
+	return p.src[start:p.pos]
+}
+
+func matchTag(name string) simpleSelector {
+	return func(n, _ *Node) bool {
+		return strings.EqualFold(n.Data, name)
+	}
+}
+
+func matchID(id string) simpleSelector {
+	return func(n, _ *Node) bool {
+		return n.AttrOrDefault("id", "") == id
+	}
+}
+
+func matchClass(class string) simpleSelector {
+	return func(n, _ *Node) bool {
+		return n.HasClass(class)
+	}
+}
+
+// lookupAttr returns the value of the attribute with the given name, compared
+// case-insensitively as attribute names are in HTML.
+func lookupAttr(n *Node, name string) (string, bool) {
+	for _, a := range n.Attrs {
+		if a.Namespace == "" && strings.EqualFold(a.Key, name) {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+func matchAttrExists(name string) simpleSelector {
+	return func(n, _ *Node) bool {
+		_, ok := lookupAttr(n, name)
+		return ok
+	}
+}
+
+func matchAttr(name, op, value string, ignoreCase bool) simpleSelector {
+	if ignoreCase {
+		value = strings.ToLower(value)
+	}
+	return func(n, _ *Node) bool {
+		v, ok := lookupAttr(n, name)
+		if !ok {
+			return false
+		}
+		if ignoreCase {
+			v = strings.ToLower(v)
+		}
+		switch op {
+		case "=":
+			return v == value
+		case "~=":
+			return containsWord(v, value, false)
+		case "|=":
+			return v == value || strings.HasPrefix(v, value+"-")
+		case "^=":
+			return value != "" && strings.HasPrefix(v, value)
+		case "$=":
+			return value != "" && strings.HasSuffix(v, value)
+		case "*=":
+			return value != "" && strings.Contains(v, value)
+		}
+		return false
+	}
+}
+
+// containsWord returns true if the given whitespace-separated list contains word.
+func containsWord(list, word string, ignoreCase bool) bool {
+	if word == "" {
+		return false
+	}
+	for _, w := range strings.FieldsFunc(list, isHTMLSpace) {
+		if w == word || ignoreCase && strings.EqualFold(w, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// isHTMLSpace returns true if r is ASCII whitespace as defined by the HTML spec.
+func isHTMLSpace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\n' || r == '\f' || r == '\r'
+}
+
+func matchScope(n, scope *Node) bool {
+	if scope == nil {
+		return isRootElement(n)
+	}
+	return n == scope
+}
+
+func isRootElement(n *Node) bool {
+	return n.Parent == nil || n.Parent.Type == DocumentNode
+}
+
+// matchHas matches elements that are the anchor of at least one of the given
+// relative selectors.
+func matchHas(list []complexSelector) simpleSelector {
+	return func(n, _ *Node) bool {
+		for i := range list {
+			cs := &list[i]
+			if cs.combinators[0] == descendantCombinator || cs.combinators[0] == childCombinator {
+				if hasMatchingDescendant(n, cs, n) {
+					return true
+				}
+				continue
+			}
+			for s := nextElementSibling(n); s != nil; s = nextElementSibling(s) {
+				if cs.match(s, n) || hasMatchingDescendant(s, cs, n) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+}
+
+func hasMatchingDescendant(n *Node, cs *complexSelector, anchor *Node) bool {
+	for d := n.FirstChild; d != nil; d = nextInDocumentOrder(d, n) {
+		if d.Type == ElementNode && cs.match(d, anchor) {
+			return true
+		}
+	}
+	return false
+}
+
+func isFormControl(n *Node) bool {
+	switch n.Data {
+	case "button", "input", "select", "textarea", "optgroup", "option", "fieldset":
+		return true
+	}
+	return false
+}
+
+var simplePseudoClasses = map[string]simpleSelector{
+	"root": func(n, _ *Node) bool {
+		return isRootElement(n)
+	},
+	"scope": matchScope,
+	"empty": func(n, _ *Node) bool {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == ElementNode || c.Type == TextNode && c.Data != "" {
+				return false
+			}
+		}
+		return true
+	},
+	"first-child": func(n, _ *Node) bool {
+		return prevElementSibling(n) == nil
+	},
+	"last-child": func(n, _ *Node) bool {
+		return nextElementSibling(n) == nil
+	},
+	"only-child": func(n, _ *Node) bool {
+		return prevElementSibling(n) == nil && nextElementSibling(n) == nil
+	},
+	"first-of-type": func(n, _ *Node) bool {
+		return !hasSiblingOfSameType(n, prevElementSibling)
+	},
+	"last-of-type": func(n, _ *Node) bool {
+		return !hasSiblingOfSameType(n, nextElementSibling)
+	},
+	"only-of-type": func(n, _ *Node) bool {
+		return !hasSiblingOfSameType(n, prevElementSibling) && !hasSiblingOfSameType(n, nextElementSibling)
+	},
+	"link":     matchLink,
+	"any-link": matchLink,
+	"checked": func(n, _ *Node) bool {
+		switch n.Data {
+		case "input":
+			t := strings.ToLower(n.AttrOrDefault("type", ""))
+			return (t == "checkbox" || t == "radio") && n.HasAttr("checked")
+		case "option":
+			return n.HasAttr("selected")
+		}
+		return false
+	},
+	"disabled": func(n, _ *Node) bool {
+		return isFormControl(n) && n.HasAttr("disabled")
+	},
+	"enabled": func(n, _ *Node) bool {
+		return isFormControl(n) && !n.HasAttr("disabled")
+	},
+}
+
+func matchLink(n, _ *Node) bool {
+	return (n.Data == "a" || n.Data == "area") && n.HasAttr("href")
+}
+
+func hasSiblingOfSameType(n *Node, sibling func(*Node) *Node) bool {
+	for s := sibling(n); s != nil; s = sibling(s) {
+		if s.Data == n.Data && s.Namespace == n.Namespace {
+			return true
+		}
+	}
+	return false
+}
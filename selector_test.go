@@ -0,0 +1,177 @@
+package gosoup
+
+import (
+	"strings"
+	"testing"
+)
+
+const HTML_SELECTORS string = `<!DOCTYPE html>
+<html lang="en-US">
+<head><title>Selectors</title></head>
+<body>
+	<div id="d1" class="article main">
+		<p id="p1">First <a id="a1" href="https://example.com">secure</a></p>
+		<p id="p2" class="foobar">Second <a id="a2" href="http://example.com">plain</a></p>
+		<p id="p3" class="foo bar" title="Hello World">Third</p>
+		<span id="s1"></span>
+	</div>
+	<div id="d2" class="article">
+		<h2 id="h1">Title</h2>
+		<p id="p4" lang="en">Fourth <img id="i1" src="x.png"></p>
+		<ul id="u1">
+			<li id="l1">1</li><li id="l2">2</li><li id="l3" class="x">3</li><li id="l4">4</li><li id="l5" class="x">5</li>
+		</ul>
+	</div>
+	<form id="f1">
+		<input id="in1" type="checkbox" checked>
+		<input id="in2" type="text" disabled>
+	</form>
+</body>
+</html>`
+
+func assertSelect(t *testing.T, doc *Node, selector string, ids ...string) {
+	it, err := doc.Select(selector)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for n := range it.Nodes {
+		got = append(got, n.AttrOrDefault("id", n.Data))
+	}
+	assert(t, strings.Join(got, " ") == strings.Join(ids, " "),
+		"selector '", selector, "': expected ", ids, ", got ", got)
+}
+
+func TestSelect(t *testing.T) {
+	doc, err := Parse(strings.NewReader(HTML_SELECTORS))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertSelect(t, doc, "title", "title")
+	assertSelect(t, doc, "#p2", "p2")
+	assertSelect(t, doc, "div.article", "d1", "d2")
+	assertSelect(t, doc, ".article.main", "d1")
+	assertSelect(t, doc, ".foo", "p3")
+	assertSelect(t, doc, "P.bar", "p3")
+	assertSelect(t, doc, "p.BAR")
+	assertSelect(t, doc, "div.article > p:nth-child(2) a[href^=\"https\"]")
+	assertSelect(t, doc, "div.article > p:nth-child(1) a[href^=\"https\"]", "a1")
+	assertSelect(t, doc, "div > p", "p1", "p2", "p3", "p4")
+	assertSelect(t, doc, "#d2 p", "p4")
+	assertSelect(t, doc, "#p1 + p", "p2")
+	assertSelect(t, doc, "#p1 ~ *", "p2", "p3", "s1")
+	assertSelect(t, doc, "h2, span, img", "s1", "h1", "i1")
+	assertSelect(t, doc, "*[title]", "p3")
+}
+
+func TestSelectAttributes(t *testing.T) {
+	doc, err := Parse(strings.NewReader(HTML_SELECTORS))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertSelect(t, doc, "[title='Hello World']", "p3")
+	assertSelect(t, doc, "[title='hello world']")
+	assertSelect(t, doc, "[title='hello world' i]", "p3")
+	assertSelect(t, doc, "[title~=World]", "p3")
+	assertSelect(t, doc, "[title~=Wor]")
+	assertSelect(t, doc, "[lang|=en]", "html", "p4")
+	assertSelect(t, doc, "a[href$=\".com\"]", "a1", "a2")
+	assertSelect(t, doc, "[src*=png]", "i1")
+	assertSelect(t, doc, "[class~=foo]", "p3")
+	assertSelect(t, doc, "[class^=foo]", "p2", "p3")
+	assertSelect(t, doc, "[ID=p1]", "p1")
+	assertSelect(t, doc, "#\\70 1", "p1")
+}
+
+func TestSelectPseudoClasses(t *testing.T) {
+	doc, err := Parse(strings.NewReader(HTML_SELECTORS))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertSelect(t, doc, ":root", "html")
+	assertSelect(t, doc, "li:first-child", "l1")
+	assertSelect(t, doc, "li:last-child", "l5")
+	assertSelect(t, doc, "li:nth-child(odd)", "l1", "l3", "l5")
+	assertSelect(t, doc, "li:nth-child(even)", "l2", "l4")
+	assertSelect(t, doc, "li:nth-child(-n+2)", "l1", "l2")
+	assertSelect(t, doc, "li:nth-child(3n + 1)", "l1", "l4")
+	assertSelect(t, doc, "li:nth-last-child(2)", "l4")
+	assertSelect(t, doc, "li:nth-child(2 of .x)", "l5")
+	assertSelect(t, doc, "#d1 > :nth-of-type(2)", "p2")
+	assertSelect(t, doc, "#d1 > :last-of-type", "p3", "s1")
+	assertSelect(t, doc, "#d1 > :only-of-type", "s1")
+	assertSelect(t, doc, "p:only-child, a:only-child", "a1", "a2")
+	assertSelect(t, doc, "span:empty", "s1")
+	assertSelect(t, doc, "div:not(.main)", "d2")
+	assertSelect(t, doc, "li:not(.x, :first-child)", "l2", "l4")
+	assertSelect(t, doc, ":is(h2, span)", "s1", "h1")
+	assertSelect(t, doc, "div:has(img)", "d2")
+	assertSelect(t, doc, "div:has(> span)", "d1")
+	assertSelect(t, doc, "div:has(> img)")
+	assertSelect(t, doc, "h2:has(+ p)", "h1")
+	assertSelect(t, doc, "p:has(~ ul > .x)", "p4")
+	assertSelect(t, doc, "a:link", "a1", "a2")
+	assertSelect(t, doc, ":checked", "in1")
+	assertSelect(t, doc, "input:disabled", "in2")
+	assertSelect(t, doc, "input:enabled", "in1")
+}
+
+func TestSelectScope(t *testing.T) {
+	doc, err := Parse(strings.NewReader(HTML_SELECTORS))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d2, err := doc.SelectFirst("#d2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSelect(t, d2, ":scope > p", "p4")
+	assertSelect(t, d2, "div p", "p4")
+	assertSelect(t, doc, ":scope > body", "body")
+
+	none, err := d2.SelectFirst("a")
+	assert(t, err == nil && none == nil, "expected no match, got ", none, err)
+}
+
+func TestSelectorErrors(t *testing.T) {
+	cases := []struct {
+		selector string
+		offset   int
+	}{
+		{"", 0},
+		{"div >", 5},
+		{"div..x", 4},
+		{"p[href", 6},
+		{"a[href=]", 7},
+		{"a[href=\"x]", 7},
+		{"a[href=x y]", 9},
+		{"p:nth-child(foo)", 12},
+		{"p::before", 1},
+		{"p:hover", 1},
+		{"div, ", 5},
+		{"div)", 3},
+		{"ns|div", 2},
+		{"p:not(.a", 8},
+	}
+	for _, c := range cases {
+		_, err := CompileSelector(c.selector)
+		selErr, ok := err.(*SelectorError)
+		assert(t, ok, "expected a SelectorError for '", c.selector, "', got ", err)
+		assert(t, selErr.Offset == c.offset,
+			"selector '", c.selector, "': expected error at offset ", c.offset, ", got ", selErr)
+	}
+
+	doc, err := Parse(strings.NewReader(HTML_SELECTORS))
+	if err != nil {
+		t.Fatal(err)
+	}
+	it, err := doc.Select("div >")
+	assert(t, err != nil, "expected an error")
+	assertEquals(t, 0, len(it.All()))
+	_, err = doc.SelectFirst("div >")
+	assert(t, err != nil, "expected an error")
+}
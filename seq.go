@@ -0,0 +1,333 @@
+package gosoup
+
+import (
+	"iter"
+)
+
+// ChildNodes returns a sequence of this node's direct children, including blank
+// text nodes.
+func (node *Node) ChildNodes() iter.Seq[*Node] {
+	return func(yield func(*Node) bool) {
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			if !yield(child) {
+				return
+			}
+		}
+	}
+}
+
+// DescendantNodes returns a sequence of this node's descendants in depth-first
+// order, including blank text nodes.
+func (node *Node) DescendantNodes() iter.Seq[*Node] {
+	return func(yield func(*Node) bool) {
+		for n := node.FirstChild; n != nil; n = nextInDocumentOrder(n, node) {
+			if !yield(n) {
+				return
+			}
+		}
+	}
+}
+
+// All returns a sequence of this node followed by all its descendants, in
+// depth-first order.
+func (node *Node) All() iter.Seq[*Node] {
+	return func(yield func(*Node) bool) {
+		if yield(node) {
+			node.DescendantNodes()(yield)
+		}
+	}
+}
+
+// TraversalOrder is the order in which the descendants of a node are traversed.
+type TraversalOrder int
+
+const (
+	// PreOrder is the depth-first document order: each node comes before its
+	// children.
+	PreOrder TraversalOrder = iota
+	// PostOrder is the depth-first order in which each node comes after its
+	// children, suitable for bottom-up processing.
+	PostOrder
+	// BreadthFirst is the level order: all the nodes of a given depth come before
+	// the nodes of the next depth, each level being in document order.
+	BreadthFirst
+	// ReverseOrder is the reverse document order: the last descendant comes first,
+	// and each node comes after its children.
+	ReverseOrder
+)
+
+// DescendantNodesInOrder returns a sequence of this node's descendants in the given
+// order, including blank text nodes. With PreOrder, it provides the same nodes as
+// DescendantNodes. With PostOrder, the yielded node may be detached or replaced
+// in the loop, since its own descendants were already yielded.
+//
+// This function panics if the order is unknown.
+func (node *Node) DescendantNodesInOrder(order TraversalOrder) iter.Seq[*Node] {
+	switch order {
+	case PreOrder:
+		return node.DescendantNodes()
+	case PostOrder:
+		return node.postOrderNodes()
+	case BreadthFirst:
+		return node.breadthFirstNodes()
+	case ReverseOrder:
+		return node.reverseOrderNodes()
+	}
+	panic("DescendantNodesInOrder: unknown traversal order")
+}
+
+func (node *Node) postOrderNodes() iter.Seq[*Node] {
+	return func(yield func(*Node) bool) {
+		if node.FirstChild == nil {
+			return
+		}
+		for n := firstLeaf(node.FirstChild); n != node; {
+			// the next node must be found before yielding, in case n is detached
+			next := n.Parent
+			if n.NextSibling != nil {
+				next = firstLeaf(n.NextSibling)
+			}
+			if !yield(n) {
+				return
+			}
+			n = next
+		}
+	}
+}
+
+func (node *Node) breadthFirstNodes() iter.Seq[*Node] {
+	return func(yield func(*Node) bool) {
+		for _, n := range node.DescendantNodesByDepth() {
+			if !yield(n) {
+				return
+			}
+		}
+	}
+}
+
+// DescendantNodesByDepth returns a sequence of this node's descendants in
+// breadth-first order, including blank text nodes, along with their depth
+// relative to this node: 1 for its children, 2 for their children, and so on.
+// Unlike calling Depth on each node, this takes constant time per node.
+func (node *Node) DescendantNodesByDepth() iter.Seq2[int, *Node] {
+	return func(yield func(int, *Node) bool) {
+		queue := []*Node{node}
+		depth, levelEnd := 0, 1 // the depth of the node queue[i], and the end of its level
+		for i := 0; i < len(queue); i++ {
+			if i == levelEnd {
+				depth, levelEnd = depth+1, len(queue)
+			}
+			for c := queue[i].FirstChild; c != nil; c = c.NextSibling {
+				if !yield(depth+1, c) {
+					return
+				}
+				queue = append(queue, c)
+			}
+			queue[i] = nil // let the visited nodes be collected
+		}
+	}
+}
+
+func (node *Node) reverseOrderNodes() iter.Seq[*Node] {
+	return func(yield func(*Node) bool) {
+		for n := lastDescendant(node); n != node; n = prevInDocumentOrder(n, node) {
+			if !yield(n) {
+				return
+			}
+		}
+	}
+}
+
+// ChildrenSeq returns a sequence of this node's direct children, except blank text
+// nodes. It provides the same nodes as Children.
+func (node *Node) ChildrenSeq() iter.Seq[*Node] {
+	return Filter(node.ChildNodes(), notBlank)
+}
+
+// DescendantsSeq returns a sequence of this node's descendants in depth-first
+// order, except blank text nodes. It provides the same nodes as Descendants.
+func (node *Node) DescendantsSeq() iter.Seq[*Node] {
+	return Filter(node.DescendantNodes(), notBlank)
+}
+
+// NextSiblingsSeq returns a sequence of the siblings following this node, in
+// document order, except blank text nodes. It provides the same nodes as
+// NextSiblings.
+func (node *Node) NextSiblingsSeq() iter.Seq[*Node] {
+	return Filter(linked(node.NextSibling, func(n *Node) *Node { return n.NextSibling }), notBlank)
+}
+
+// PrevSiblingsSeq returns a sequence of the siblings preceding this node, from the
+// closest one, except blank text nodes. It provides the same nodes as
+// PrevSiblings.
+func (node *Node) PrevSiblingsSeq() iter.Seq[*Node] {
+	return Filter(linked(node.PrevSibling, func(n *Node) *Node { return n.PrevSibling }), notBlank)
+}
+
+// SiblingsSeq returns a sequence of the other children of this node's parent, in
+// document order, except blank text nodes. It provides the same nodes as Siblings.
+func (node *Node) SiblingsSeq() iter.Seq[*Node] {
+	return func(yield func(*Node) bool) {
+		if node.Parent == nil {
+			return
+		}
+		for n := range node.Parent.ChildrenSeq() {
+			if n != node && !yield(n) {
+				return
+			}
+		}
+	}
+}
+
+// AncestorsSeq returns a sequence of the ancestors of this node, from its parent
+// up to the root of the tree. It provides the same nodes as Ancestors.
+func (node *Node) AncestorsSeq() iter.Seq[*Node] {
+	return linked(node.Parent, func(n *Node) *Node { return n.Parent })
+}
+
+// AncestorsOrSelfSeq returns a sequence of this node followed by its ancestors. It
+// provides the same nodes as AncestorsOrSelf.
+func (node *Node) AncestorsOrSelfSeq() iter.Seq[*Node] {
+	return linked(node, func(n *Node) *Node { return n.Parent })
+}
+
+// linked returns a sequence of the nodes starting at first and following the
+// links given by next, until nil.
+func linked(first *Node, next func(*Node) *Node) iter.Seq[*Node] {
+	return func(yield func(*Node) bool) {
+		for n := first; n != nil; n = next(n) {
+			if !yield(n) {
+				return
+			}
+		}
+	}
+}
+
+// nextInDocumentOrder returns the node following n in document order, without
+// leaving the subtree of root.
+func nextInDocumentOrder(n, root *Node) *Node {
+	if n.FirstChild != nil {
+		return n.FirstChild
+	}
+	return nextAfterSubtree(n, root)
+}
+
+// nextAfterSubtree returns the node following the subtree of n in document order,
+// without leaving the subtree of root.
+func nextAfterSubtree(n, root *Node) *Node {
+	for ; n != root; n = n.Parent {
+		if n.NextSibling != nil {
+			return n.NextSibling
+		}
+	}
+	return nil
+}
+
+// prevInDocumentOrder returns the node preceding n in document order, without
+// leaving the subtree of root.
+func prevInDocumentOrder(n, root *Node) *Node {
+	if n == root {
+		return nil
+	}
+	if n.PrevSibling == nil {
+		return n.Parent
+	}
+	return lastDescendant(n.PrevSibling)
+}
+
+// firstLeaf returns the first node without children of the subtree of n in
+// document order, n itself if it has no children.
+func firstLeaf(n *Node) *Node {
+	for n.FirstChild != nil {
+		n = n.FirstChild
+	}
+	return n
+}
+
+// lastDescendant returns the last node of the subtree of n in document order, n
+// itself if it has no children.
+func lastDescendant(n *Node) *Node {
+	for n.LastChild != nil {
+		n = n.LastChild
+	}
+	return n
+}
+
+// walk visits the subtree of root in depth-first order, without recursion so that
+// the depth of the tree does not matter. The enter function is called on each node
+// before its children, which are skipped if it returns false, and the leave
+// function is called on each node after its children. The walk stops at the
+// first error.
+func walk(root *Node, enter func(n *Node) (bool, error), leave func(n *Node) error) error {
+	n := root
+	for {
+		descend, err := enter(n)
+		if err != nil {
+			return err
+		}
+		if descend && n.FirstChild != nil {
+			n = n.FirstChild
+			continue
+		}
+		for {
+			if err := leave(n); err != nil {
+				return err
+			}
+			if n == root {
+				return nil
+			}
+			if n.NextSibling != nil {
+				n = n.NextSibling
+				break
+			}
+			n = n.Parent
+		}
+	}
+}
+
+// Filter returns a sequence of the nodes of seq that match the given predicate.
+func Filter(seq iter.Seq[*Node], predicate func(*Node) bool) iter.Seq[*Node] {
+	return func(yield func(*Node) bool) {
+		for node := range seq {
+			if predicate(node) && !yield(node) {
+				return
+			}
+		}
+	}
+}
+
+// Map returns a sequence of the nodes produced by applying the given mapping
+// function on each node of seq.
+func Map(seq iter.Seq[*Node], mapper func(*Node) *Node) iter.Seq[*Node] {
+	return func(yield func(*Node) bool) {
+		for node := range seq {
+			if !yield(mapper(node)) {
+				return
+			}
+		}
+	}
+}
+
+// Take returns a sequence of at most max nodes of seq.
+func Take(seq iter.Seq[*Node], max int) iter.Seq[*Node] {
+	return func(yield func(*Node) bool) {
+		if max <= 0 {
+			return
+		}
+		count := 0
+		for node := range seq {
+			count++
+			if !yield(node) || count >= max {
+				return
+			}
+		}
+	}
+}
+
+// First returns the first node of seq, or nil if seq is empty.
+func First(seq iter.Seq[*Node]) *Node {
+	for node := range seq {
+		return node
+	}
+	return nil
+}
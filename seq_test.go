@@ -0,0 +1,160 @@
+package gosoup
+
+import (
+	"iter"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func datas(seq iter.Seq[*Node]) []string {
+	var list []string
+	for n := range seq {
+		list = append(list, n.Data)
+	}
+	return list
+}
+
+func assertDatas(t *testing.T, expected []string, value []string) {
+	assert(t, slices.Equal(expected, value), "expected ", expected, ", got ", value)
+}
+
+func TestSeq(t *testing.T) {
+	doc, err := Parse(strings.NewReader(HTML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	head := First(Filter(doc.DescendantsSeq(), predicateIsTag("head")))
+	assert(t, head != nil, "head not found")
+
+	assertDatas(t, []string{"title"}, datas(head.ChildrenSeq()))
+	assertDatas(t, []string{"\n\t\t", "title", "\n\t"}, datas(head.ChildNodes()))
+	assertDatas(t, []string{"\n\t\t", "title", "Your Title Here", "\n\t"}, datas(head.DescendantNodes()))
+	assertDatas(t, []string{"head", "\n\t\t", "title", "Your Title Here", "\n\t"}, datas(head.All()))
+	assertDatas(t, []string{"title", "Your Title Here"}, datas(head.DescendantsSeq()))
+
+	body := First(Filter(doc.DescendantsSeq(), predicateIsTag("body")))
+	assertDatas(t, []string{"aside", "hr"}, datas(Take(body.ChildrenSeq(), 2)))
+	assertDatas(t, nil, datas(Take(body.ChildrenSeq(), 0)))
+	isHeader := func(n *Node) bool {
+		return n.IsTag("h1") || n.IsTag("h2")
+	}
+	upper := func(n *Node) *Node {
+		return &Node{Data: strings.ToUpper(n.Data)}
+	}
+	assertDatas(t, []string{"H1", "H2"}, datas(Map(Filter(body.ChildrenSeq(), isHeader), upper)))
+	assert(t, First(body.ChildrenSeq()).Data == "aside", "expected aside")
+	assert(t, First(Filter(body.ChildrenSeq(), predicateIsTag("table"))) == nil, "expected no table")
+}
+
+func TestSeqEarlyBreak(t *testing.T) {
+	doc, err := Parse(strings.NewReader(HTML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := runtime.NumGoroutine()
+	count := 0
+	for n := range doc.DescendantsSeq() {
+		assertEquals(t, before, runtime.NumGoroutine())
+		if n.IsTag("body") {
+			break
+		}
+		count++
+	}
+	assertEquals(t, 4, count)
+}
+
+func TestIteratorSeq(t *testing.T) {
+	doc, err := Parse(strings.NewReader(HTML))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertDatas(t, []string{"html", "head", "title"}, datas(doc.Descendants().Limit(3).Seq()))
+
+	it := doc.Descendants().Filter(notBlank)
+	for n := range it.Seq() {
+		if n.IsTag("title") {
+			break
+		}
+	}
+	assertNoIteratorGoroutines(t)
+
+	assertDatas(t, []string{"html"}, datas(NewNodeIterator(doc.ChildrenSeq()).Seq()))
+}
+
+func TestTraversalOrders(t *testing.T) {
+	doc := parseBody(t, `<div><p>a<b>b</b></p><ul><li>c</li></ul></div><hr>`)
+	div := First(Filter(doc.DescendantNodes(), predicateIsTag("div")))
+
+	assertDatas(t, []string{"p", "a", "b", "b", "ul", "li", "c"}, datas(div.DescendantNodesInOrder(PreOrder)))
+	assertDatas(t, []string{"a", "b", "b", "p", "c", "li", "ul"}, datas(div.DescendantNodesInOrder(PostOrder)))
+	assertDatas(t, []string{"p", "ul", "a", "b", "li", "b", "c"}, datas(div.DescendantNodesInOrder(BreadthFirst)))
+	assertDatas(t, []string{"c", "li", "ul", "b", "b", "a", "p"}, datas(div.DescendantNodesInOrder(ReverseOrder)))
+	assertDatas(t, []string{"a", "b"}, datas(Take(div.DescendantNodesInOrder(PostOrder), 2)))
+
+	hr := First(Filter(doc.DescendantNodes(), predicateIsTag("hr")))
+	for _, order := range []TraversalOrder{PreOrder, PostOrder, BreadthFirst, ReverseOrder} {
+		assertDatas(t, nil, datas(hr.DescendantNodesInOrder(order)))
+	}
+	assertPanics(t, func() { div.DescendantNodesInOrder(TraversalOrder(42)) }, "expected a panic for an unknown order")
+
+	// the last paragraph before the list
+	ul := First(Filter(doc.DescendantNodes(), predicateIsTag("ul")))
+	var last *Node
+	seen := false
+	for n := range doc.DescendantNodesInOrder(ReverseOrder) {
+		if n == ul {
+			seen = true
+		} else if seen && n.IsTag("p") {
+			last = n
+			break
+		}
+	}
+	assertEquals(t, "a", last.FirstChild.Data)
+}
+
+func TestPostOrderTransformation(t *testing.T) {
+	doc := parseBody(t, `<div><span><span>x</span></span><span>y</span></div>`)
+	// unwrap all the spans, bottom-up
+	for n := range doc.DescendantNodesInOrder(PostOrder) {
+		if n.IsTag("span") {
+			for n.FirstChild != nil {
+				n.Parent.InsertBefore(n.FirstChild, n)
+			}
+			n.Detach()
+		}
+	}
+	assertConsistent(t, doc)
+	assertEquals(t, "<div>xy</div>", renderBody(t, doc))
+}
+
+func TestDepth(t *testing.T) {
+	doc := parseBody(t, `<div><p>a<b>b</b></p></div>`)
+	assertEquals(t, 0, doc.Depth())
+	var depths []int
+	for n := range doc.DescendantNodesInOrder(BreadthFirst) {
+		depths = append(depths, n.Depth())
+	}
+	assert(t, slices.Equal([]int{1, 2, 2, 3, 4, 5, 5, 6}, depths), "unexpected depths ", depths)
+	assertEquals(t, 8, len(doc.TreeIteratorOrder(BreadthFirst).All()))
+
+	// the depths are relative to the node
+	p := First(Filter(doc.DescendantNodes(), predicateIsTag("p")))
+	depths = nil
+	var nodes []*Node
+	for depth, n := range doc.DescendantNodesByDepth() {
+		depths = append(depths, depth)
+		nodes = append(nodes, n)
+		assertEquals(t, n.Depth(), depth)
+	}
+	assertEquals(t, 8, len(depths))
+	assert(t, slices.Equal(nodes, slices.Collect(doc.DescendantNodesInOrder(BreadthFirst))), "unexpected order")
+	depths = nil
+	for depth, n := range p.DescendantNodesByDepth() {
+		depths = append(depths, depth)
+		assertEquals(t, n.Depth()-p.Depth(), depth)
+	}
+	assert(t, slices.Equal([]int{1, 1, 2}, depths), "unexpected depths ", depths)
+}
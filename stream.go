@@ -0,0 +1,331 @@
+package gosoup
+
+import (
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"io"
+	"iter"
+)
+
+// EventType is the type of an Event produced by a Stream.
+type EventType int
+
+const (
+	StartElementEvent EventType = iota
+	EndElementEvent
+	TextEvent
+	CommentEvent
+	DoctypeEvent
+)
+
+// Event is a piece of an HTML document read by a Stream.
+type Event struct {
+	Type EventType
+	// Data is the tag name for element events, and the unescaped content for the
+	// other events.
+	Data string
+	// Namespace is the namespace of the element for element events, "svg" or
+	// "math" for foreign elements, empty for HTML elements.
+	Namespace string
+	// Attrs holds the attributes of the element for start element events.
+	Attrs []Attribute
+	// Depth is the number of elements enclosing the element or the text, starting
+	// at 0 for the root element.
+	Depth int
+}
+
+// voidElements are the elements that cannot have content, and thus no end tag.
+var voidElements = map[string]bool{
+	"area":   true,
+	"base":   true,
+	"br":     true,
+	"col":    true,
+	"embed":  true,
+	"hr":     true,
+	"img":    true,
+	"input":  true,
+	"keygen": true,
+	"link":   true,
+	"meta":   true,
+	"param":  true,
+	"source": true,
+	"track":  true,
+	"wbr":    true,
+}
+
+// impliedEnds lists, for each element, the start tags that close it implicitly
+// when it is the current element.
+var impliedEnds = map[string]map[string]bool{
+	"li":       {"li": true},
+	"dt":       {"dt": true, "dd": true},
+	"dd":       {"dt": true, "dd": true},
+	"option":   {"option": true, "optgroup": true},
+	"optgroup": {"optgroup": true},
+	"tr":       {"tr": true, "tbody": true, "thead": true, "tfoot": true},
+	"td":       {"td": true, "th": true, "tr": true, "tbody": true, "thead": true, "tfoot": true},
+	"th":       {"td": true, "th": true, "tr": true, "tbody": true, "thead": true, "tfoot": true},
+	"thead":    {"tbody": true, "tfoot": true},
+	"tbody":    {"tbody": true, "tfoot": true},
+	"p":        paragraphClosers,
+}
+
+// paragraphClosers are the start tags that close an open <p> element.
+var paragraphClosers = map[string]bool{
+	"address":    true,
+	"article":    true,
+	"aside":      true,
+	"blockquote": true,
+	"center":     true,
+	"dd":         true,
+	"details":    true,
+	"dialog":     true,
+	"dir":        true,
+	"div":        true,
+	"dl":         true,
+	"dt":         true,
+	"fieldset":   true,
+	"figcaption": true,
+	"figure":     true,
+	"footer":     true,
+	"form":       true,
+	"h1":         true,
+	"h2":         true,
+	"h3":         true,
+	"h4":         true,
+	"h5":         true,
+	"h6":         true,
+	"header":     true,
+	"hgroup":     true,
+	"hr":         true,
+	"li":         true,
+	"listing":    true,
+	"main":       true,
+	"menu":       true,
+	"nav":        true,
+	"ol":         true,
+	"p":          true,
+	"plaintext":  true,
+	"pre":        true,
+	"search":     true,
+	"section":    true,
+	"summary":    true,
+	"table":      true,
+	"ul":         true,
+	"xmp":        true,
+}
+
+// Stream reads an HTML document as a sequence of events, without building a tree.
+// Its memory footprint does not depend on the size of the document, but only on
+// the size of the largest token and on the depth of the elements.
+//
+// Unlike Parse, a Stream does not implement the full HTML tree construction
+// algorithm: it only closes the elements explicitly, or implicitly in the common
+// cases where the HTML syntax allows to omit end tags, such as <li> or <p>. The
+// events are thus always balanced, but misnested tags may produce a different
+// structure than Parse. Elements are not created implicitly either: a document
+// without <html> tag produces no event for it.
+type Stream struct {
+	z       *html.Tokenizer
+	open    []Event // the start events of the open elements
+	pending []Event // the events to deliver before reading the next token
+	err     error
+}
+
+// NewStream returns a Stream reading the HTML from the given Reader. The input is
+// assumed to be UTF-8 encoded.
+func NewStream(r io.Reader) *Stream {
+	return &Stream{z: html.NewTokenizer(r)}
+}
+
+// Err returns the error that stopped the stream, if it was not the end of the
+// input. It should be called after Next returned false.
+func (s *Stream) Err() error {
+	if s.err == io.EOF {
+		return nil
+	}
+	return s.err
+}
+
+// Next returns the next event of the stream. It returns false if there are no more
+// events, because the end of the input was reached or because of an error.
+func (s *Stream) Next() (Event, bool) {
+	for len(s.pending) == 0 {
+		if s.err != nil {
+			if len(s.open) == 0 {
+				return Event{}, false
+			}
+			s.close()
+			continue
+		}
+		s.readToken()
+	}
+	e := s.pending[0]
+	s.pending = s.pending[1:]
+	return e, true
+}
+
+// Events returns a sequence of the events of this stream.
+func (s *Stream) Events() iter.Seq[Event] {
+	return func(yield func(Event) bool) {
+		for {
+			e, ok := s.Next()
+			if !ok || !yield(e) {
+				return
+			}
+		}
+	}
+}
+
+// namespace returns the namespace of the current element.
+func (s *Stream) namespace() string {
+	if len(s.open) == 0 {
+		return ""
+	}
+	return s.open[len(s.open)-1].Namespace
+}
+
+// close closes the current element.
+func (s *Stream) close() {
+	start := s.open[len(s.open)-1]
+	s.open = s.open[:len(s.open)-1]
+	s.pending = append(s.pending, Event{
+		Type:      EndElementEvent,
+		Data:      start.Data,
+		Namespace: start.Namespace,
+		Depth:     start.Depth,
+	})
+}
+
+// readToken reads the next token and queues the corresponding events.
+func (s *Stream) readToken() {
+	tt := s.z.Next()
+	switch tt {
+	case html.ErrorToken:
+		s.err = s.z.Err()
+	case html.TextToken:
+		s.pending = append(s.pending, Event{Type: TextEvent, Data: string(s.z.Text()), Depth: len(s.open)})
+	case html.CommentToken:
+		s.pending = append(s.pending, Event{Type: CommentEvent, Data: string(s.z.Text()), Depth: len(s.open)})
+	case html.DoctypeToken:
+		s.pending = append(s.pending, Event{Type: DoctypeEvent, Data: string(s.z.Text()), Depth: len(s.open)})
+	case html.StartTagToken, html.SelfClosingTagToken:
+		s.startTag(tt == html.SelfClosingTagToken)
+	case html.EndTagToken:
+		name, _ := s.z.TagName()
+		s.endTag(string(name))
+	}
+}
+
+func (s *Stream) startTag(selfClosing bool) {
+	name, hasAttr := s.z.TagName()
+	e := Event{Type: StartElementEvent, Data: string(name), Namespace: s.namespace()}
+	for hasAttr {
+		var key, val []byte
+		key, val, hasAttr = s.z.TagAttr()
+		e.Attrs = append(e.Attrs, Attribute{Key: string(key), Val: string(val)})
+	}
+	if e.Namespace == "" {
+		for len(s.open) > 0 && impliedEnds[s.open[len(s.open)-1].Data][e.Data] {
+			s.close()
+		}
+		switch e.Data {
+		case "svg", "math":
+			e.Namespace = e.Data
+		}
+	}
+	if e.Namespace != "" {
+		// foreign elements have no raw text content, even <style> or <title>
+		s.z.NextIsNotRawText()
+	}
+	e.Depth = len(s.open)
+	s.open = append(s.open, e)
+	s.pending = append(s.pending, e)
+	if e.Namespace == "" && voidElements[e.Data] || e.Namespace != "" && selfClosing {
+		s.close()
+	}
+}
+
+func (s *Stream) endTag(name string) {
+	for i := len(s.open) - 1; i >= 0; i-- {
+		if s.open[i].Data == name {
+			for len(s.open) > i {
+				s.close()
+			}
+			return
+		}
+	}
+	// no such open element, the end tag is ignored
+}
+
+// Subtrees returns a sequence of the elements of this stream whose start event
+// matches the given predicate, materialized as trees of Node along with all their
+// content. The rest of the document is discarded as it is read.
+//
+// The returned nodes have a nil Parent field. Matching elements nested in a
+// returned element are only part of its tree, they are not returned separately.
+func (s *Stream) Subtrees(match func(e Event) bool) iter.Seq[*Node] {
+	return func(yield func(*Node) bool) {
+		var root, current *Node
+		for e := range s.Events() {
+			if root == nil {
+				if e.Type == StartElementEvent && match(e) {
+					root = eventNode(e)
+					current = root
+				}
+				continue
+			}
+			switch e.Type {
+			case StartElementEvent:
+				n := eventNode(e)
+				current.link(n, current.LastChild, nil)
+				current = n
+			case EndElementEvent:
+				if current != root {
+					current = current.Parent
+					continue
+				}
+				if !yield(root) {
+					return
+				}
+				root, current = nil, nil
+			case TextEvent:
+				if last := current.LastChild; last != nil && last.Type == TextNode {
+					last.Data += e.Data
+				} else {
+					current.link(eventNode(e), current.LastChild, nil)
+				}
+			default:
+				current.link(eventNode(e), current.LastChild, nil)
+			}
+		}
+	}
+}
+
+// SubtreesByTag returns a sequence of the elements of this stream with the given
+// tag name, materialized as trees of Node, like Subtrees.
+func (s *Stream) SubtreesByTag(tagName string) iter.Seq[*Node] {
+	return s.Subtrees(func(e Event) bool {
+		return e.Data == tagName
+	})
+}
+
+// eventNode returns a node corresponding to the given event, which must not be an
+// end element event.
+func eventNode(e Event) *Node {
+	switch e.Type {
+	case StartElementEvent:
+		return &Node{
+			Type:      ElementNode,
+			DataAtom:  atom.Lookup([]byte(e.Data)),
+			Data:      e.Data,
+			Namespace: e.Namespace,
+			Attrs:     e.Attrs,
+		}
+	case CommentEvent:
+		return &Node{Type: CommentNode, Data: e.Data}
+	case DoctypeEvent:
+		return &Node{Type: DoctypeNode, Data: e.Data}
+	default:
+		return &Node{Type: TextNode, Data: e.Data}
+	}
+}
@@ -0,0 +1,142 @@
+package gosoup
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+// describeEvents returns a compact description of the events of the stream.
+func describeEvents(s *Stream) []string {
+	var list []string
+	for e := range s.Events() {
+		switch e.Type {
+		case StartElementEvent:
+			desc := fmt.Sprint(e.Depth, "<", e.Data)
+			for _, a := range e.Attrs {
+				desc += " " + a.Key + "=" + a.Val
+			}
+			list = append(list, desc+">")
+		case EndElementEvent:
+			list = append(list, fmt.Sprint(e.Depth, "</", e.Data, ">"))
+		case TextEvent:
+			list = append(list, fmt.Sprint(e.Depth, " ", e.Data))
+		case CommentEvent:
+			list = append(list, fmt.Sprint(e.Depth, "<!--", e.Data, "-->"))
+		case DoctypeEvent:
+			list = append(list, fmt.Sprint(e.Depth, "<!DOCTYPE ", e.Data, ">"))
+		}
+	}
+	return list
+}
+
+func TestStreamEvents(t *testing.T) {
+	s := NewStream(strings.NewReader(`<!DOCTYPE html><div id=a>x &amp; y<br><!--c--><img src=i.png></div>`))
+	assertDatas(t, []string{
+		"0<!DOCTYPE html>",
+		"0<div id=a>",
+		"1 x & y",
+		"1<br>",
+		"1</br>",
+		"1<!--c-->",
+		"1<img src=i.png>",
+		"1</img>",
+		"0</div>",
+	}, describeEvents(s))
+	assert(t, s.Err() == nil, "unexpected error ", s.Err())
+}
+
+func TestStreamImpliedEnds(t *testing.T) {
+	s := NewStream(strings.NewReader(`<ul><li>a<li>b</ul><p>one<p>two<div>three</span></div><svg><title><b>t</b></title><path/></svg><b>unclosed`))
+	assertDatas(t, []string{
+		"0<ul>", "1<li>", "2 a", "1</li>", "1<li>", "2 b", "1</li>", "0</ul>",
+		"0<p>", "1 one", "0</p>", "0<p>", "1 two", "0</p>",
+		"0<div>", "1 three", "0</div>",
+		"0<svg>", "1<title>", "2<b>", "3 t", "2</b>", "1</title>", "1<path>", "1</path>", "0</svg>",
+		"0<b>", "1 unclosed", "0</b>",
+	}, describeEvents(s))
+}
+
+func TestStreamRawText(t *testing.T) {
+	s := NewStream(strings.NewReader(`<script>if (a<b) {}</script><textarea><b>x</b></textarea>`))
+	assertDatas(t, []string{
+		"0<script>", "1 if (a<b) {}", "0</script>",
+		"0<textarea>", "1 <b>x</b>", "0</textarea>",
+	}, describeEvents(s))
+}
+
+func TestStreamSubtrees(t *testing.T) {
+	input := `<html><body><nav>skip</nav>` +
+		`<article id="1"><h1>One</h1><p>first <b>para</b><p>second</article>` +
+		`<div><article id="2">Two<article id="nested">inner</article></article></div>` +
+		`</body></html>`
+
+	var got []string
+	for n := range NewStream(strings.NewReader(input)).SubtreesByTag("article") {
+		assert(t, n.Parent == nil, "subtree with a parent")
+		assertConsistent(t, n)
+		got = append(got, render(t, n))
+	}
+	assertDatas(t, []string{
+		`<article id="1"><h1>One</h1><p>first <b>para</b></p><p>second</p></article>`,
+		`<article id="2">Two<article id="nested">inner</article></article>`,
+	}, got)
+
+	// breaking early
+	s := NewStream(strings.NewReader(input))
+	for n := range s.Subtrees(func(e Event) bool { return e.Data == "p" }) {
+		assertEquals(t, "first para", n.Text())
+		break
+	}
+	e, ok := s.Next()
+	assert(t, ok && e.Type == StartElementEvent && e.Data == "p", "expected the stream to continue after the subtree")
+}
+
+// repeatReader repeats a string n times without holding the whole input in
+// memory.
+type repeatReader struct {
+	s    string
+	n    int
+	left string
+}
+
+func (r *repeatReader) Read(p []byte) (int, error) {
+	if r.left == "" {
+		if r.n == 0 {
+			return 0, io.EOF
+		}
+		r.n--
+		r.left = r.s
+	}
+	n := copy(p, r.left)
+	r.left = r.left[n:]
+	return n, nil
+}
+
+func TestStreamLargeInput(t *testing.T) {
+	r := io.MultiReader(
+		strings.NewReader("<html><body>"),
+		&repeatReader{s: "<div><article><p>text</p></article><aside>noise</aside></div>\n", n: 20000},
+		strings.NewReader("</body></html>"),
+	)
+	count := 0
+	for n := range NewStream(r).SubtreesByTag("article") {
+		assertEquals(t, "text", n.Text())
+		count++
+	}
+	assertEquals(t, 20000, count)
+}
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, io.ErrUnexpectedEOF
+}
+
+func TestStreamError(t *testing.T) {
+	s := NewStream(io.MultiReader(strings.NewReader("<div><p>text"), failingReader{}))
+	events := describeEvents(s)
+	assertEquals(t, io.ErrUnexpectedEOF, s.Err())
+	assertEquals(t, "0</div>", events[len(events)-1])
+}
@@ -0,0 +1,282 @@
+package gosoup
+
+import (
+	"strings"
+)
+
+// Text returns the text content of this node: the concatenation of the data of
+// all the text nodes of its subtree, in document order, as is. This is the
+// equivalent of the textContent DOM property.
+func (node *Node) Text() string {
+	if node.Type == TextNode {
+		return node.Data
+	}
+	var b strings.Builder
+	for n := range node.DescendantNodes() {
+		if n.Type == TextNode {
+			b.WriteString(n.Data)
+		}
+	}
+	return b.String()
+}
+
+// InnerText returns the text of this node as it would be rendered, in the manner
+// of the innerText DOM property, assuming the default style of HTML elements:
+//
+//   - whitespace is collapsed, except inside <pre> and <textarea> elements
+//   - block elements such as <div> and <li> are put on their own lines, and
+//     paragraphs are separated by a blank line
+//   - <br> elements are rendered as line breaks
+//   - table cells are separated by tabs and table rows by line breaks
+//   - hidden elements such as <script>, <style>, <template> and <head> are skipped
+//
+// If this node itself is a hidden element, its text content is returned, like
+// Text does.
+func (node *Node) InnerText() string {
+	if node.Type == TextNode {
+		return node.Data
+	}
+	if isHiddenElement(node) {
+		return node.Text()
+	}
+	w := &innerTextWriter{}
+	if node.Type == ElementNode && preformattedElements[node.Data] {
+		w.pre = 1
+	}
+	for c := node.FirstChild; c != nil; c = c.NextSibling {
+		walk(c, w.enter, w.leave)
+	}
+	return w.b.String()
+}
+
+// hiddenElements are the elements that are not rendered by default.
+var hiddenElements = map[string]bool{
+	"head":     true,
+	"noscript": true,
+	"rp":       true,
+	"script":   true,
+	"style":    true,
+	"template": true,
+	"title":    true,
+}
+
+// blockElements are the elements that are displayed as blocks by default, and
+// thus start on a new line.
+var blockElements = map[string]bool{
+	"address":    true,
+	"article":    true,
+	"aside":      true,
+	"blockquote": true,
+	"body":       true,
+	"caption":    true,
+	"center":     true,
+	"dd":         true,
+	"details":    true,
+	"dialog":     true,
+	"dir":        true,
+	"div":        true,
+	"dl":         true,
+	"dt":         true,
+	"fieldset":   true,
+	"figcaption": true,
+	"figure":     true,
+	"footer":     true,
+	"form":       true,
+	"h1":         true,
+	"h2":         true,
+	"h3":         true,
+	"h4":         true,
+	"h5":         true,
+	"h6":         true,
+	"header":     true,
+	"hgroup":     true,
+	"hr":         true,
+	"html":       true,
+	"legend":     true,
+	"li":         true,
+	"listing":    true,
+	"main":       true,
+	"menu":       true,
+	"nav":        true,
+	"ol":         true,
+	"optgroup":   true,
+	"option":     true,
+	"plaintext":  true,
+	"pre":        true,
+	"search":     true,
+	"section":    true,
+	"summary":    true,
+	"table":      true,
+	"ul":         true,
+	"xmp":        true,
+}
+
+// preformattedElements are the elements in which whitespace is preserved.
+var preformattedElements = map[string]bool{
+	"listing":   true,
+	"plaintext": true,
+	"pre":       true,
+	"textarea":  true,
+	"xmp":       true,
+}
+
+func isHTMLElement(n *Node, elements map[string]bool) bool {
+	return n.Type == ElementNode && n.Namespace == "" && elements[n.Data]
+}
+
+func isHiddenElement(n *Node) bool {
+	return isHTMLElement(n, hiddenElements)
+}
+
+// innerTextWriter accumulates rendered text. Whitespace and line breaks are kept
+// pending until some text follows them, so that they are never written at the
+// start or at the end of the result, and so that consecutive line breaks are
+// merged.
+type innerTextWriter struct {
+	b      strings.Builder
+	breaks int  // number of pending line breaks
+	space  bool // whether a collapsible space is pending
+	last   byte // last byte written, 0 if nothing was written yet
+	pre    int  // number of enclosing preformatted elements
+}
+
+// lineBreaks requests at least count line breaks before the next text.
+func (w *innerTextWriter) lineBreaks(count int) {
+	w.breaks = max(w.breaks, count)
+	w.space = false
+}
+
+// flush writes the pending line breaks or space, if some text was already
+// written.
+func (w *innerTextWriter) flush() {
+	if w.last != 0 {
+		if w.breaks > 0 {
+			w.b.WriteString(strings.Repeat("\n", w.breaks))
+			w.last = '\n'
+		} else if w.space && w.last != '\n' && w.last != '\t' && w.last != ' ' {
+			w.b.WriteByte(' ')
+			w.last = ' '
+		}
+	}
+	w.breaks = 0
+	w.space = false
+}
+
+// raw writes the given string as is, after the pending line breaks.
+func (w *innerTextWriter) raw(s string) {
+	w.space = false
+	w.flush()
+	w.b.WriteString(s)
+	w.last = s[len(s)-1]
+}
+
+// text writes the given text, collapsing its whitespace.
+func (w *innerTextWriter) text(s string) {
+	for i := 0; i < len(s); i++ {
+		if isHTMLSpace(rune(s[i])) {
+			w.space = true
+			continue
+		}
+		w.flush()
+		w.b.WriteByte(s[i])
+		w.last = s[i]
+	}
+}
+
+// enter writes the given node, or what precedes its children, and returns true if
+// its children must be written.
+func (w *innerTextWriter) enter(n *Node) (bool, error) {
+	switch n.Type {
+	case TextNode:
+		if w.pre > 0 {
+			if n.Data != "" {
+				w.raw(n.Data)
+			}
+		} else {
+			w.text(n.Data)
+		}
+		return false, nil
+	case ElementNode:
+	default:
+		return false, nil
+	}
+	if n.Namespace != "" {
+		return true, nil
+	}
+	switch {
+	case isHiddenElement(n):
+		return false, nil
+	case n.Data == "br":
+		w.raw("\n")
+		return false, nil
+	case n.Data == "p":
+		w.lineBreaks(2)
+	default:
+		if preformattedElements[n.Data] {
+			w.pre++
+		}
+		if blockElements[n.Data] {
+			w.lineBreaks(1)
+		}
+	}
+	return true, nil
+}
+
+// leave writes what follows the children of the given node.
+func (w *innerTextWriter) leave(n *Node) error {
+	if n.Type != ElementNode || n.Namespace != "" || isHiddenElement(n) {
+		return nil
+	}
+	switch {
+	case n.Data == "p":
+		w.lineBreaks(2)
+	case n.Data == "td" || n.Data == "th":
+		if nextCell(n) != nil {
+			w.raw("\t")
+		}
+	case n.Data == "tr":
+		if !isLastRow(n) {
+			w.raw("\n")
+		}
+	default:
+		if preformattedElements[n.Data] {
+			w.pre--
+		}
+		if blockElements[n.Data] {
+			w.lineBreaks(1)
+		}
+	}
+	return nil
+}
+
+// nextCell returns the next cell in the row of the given cell, if any.
+func nextCell(cell *Node) *Node {
+	for n := cell.NextSibling; n != nil; n = n.NextSibling {
+		if n.IsTag("td") || n.IsTag("th") {
+			return n
+		}
+	}
+	return nil
+}
+
+// isLastRow returns true if the given row is the last one of its table, looking
+// into the following row groups.
+func isLastRow(row *Node) bool {
+	for n := row.NextSibling; n != nil; n = n.NextSibling {
+		if n.IsTag("tr") {
+			return false
+		}
+	}
+	group := row.Parent
+	if group == nil || !(group.IsTag("tbody") || group.IsTag("thead") || group.IsTag("tfoot")) {
+		return true
+	}
+	for g := group.NextSibling; g != nil; g = g.NextSibling {
+		for n := g.FirstChild; n != nil; n = n.NextSibling {
+			if n.IsTag("tr") {
+				return false
+			}
+		}
+	}
+	return true
+}
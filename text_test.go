@@ -0,0 +1,57 @@
+package gosoup
+
+import (
+	"testing"
+)
+
+func TestText(t *testing.T) {
+	doc := parseBody(t, "<div id=\"a\">\n  Hello <b>big</b>\n  <!-- comment --> world<script>var x;</script>\n</div>")
+	a := byID(doc, "a")
+	before := render(t, doc)
+
+	assertEquals(t, "\n  Hello big\n   worldvar x;\n", a.Text())
+	assertEquals(t, " world", a.LastChild.PrevSibling.PrevSibling.Text())
+	assertEquals(t, "", (&Node{Type: ElementNode, Data: "p"}).Text())
+	assertEquals(t, before, render(t, doc))
+}
+
+func TestInnerText(t *testing.T) {
+	tests := []struct {
+		html     string
+		expected string
+	}{
+		{"  Hello   <b> big </b>\n world  ", "Hello big world"},
+		{"<div>one</div><div>two</div>three", "one\ntwo\nthree"},
+		{"<p>one</p><p>two</p>", "one\n\ntwo"},
+		{"<div><p>one</p></div><div>two</div>", "one\n\ntwo"},
+		{"one<br>two <br> three", "one\ntwo\nthree"},
+		{"<ul><li>a</li> <li> b </li></ul>", "a\nb"},
+		{"a<script>var x;</script><style>p{}</style><template>t</template>b", "ab"},
+		{"<pre>  keep\n    this</pre>after", "  keep\n    this\nafter"},
+		{"<table><tr><th>a</th><th>b</th></tr><tr><td> c </td><td>d</td></tr></table>", "a\tb\nc\td"},
+		{"<table><thead><tr><td>a</td></tr></thead><tbody><tr><td>b</td></tr></tbody></table>", "a\nb"},
+		{"<span>a</span> <span>b</span>", "a b"},
+		{"<h1>Title</h1>\n<p>Text with <a href=\"#\">a link</a>.</p>", "Title\n\nText with a link."},
+	}
+	for _, test := range tests {
+		doc := parseBody(t, test.html)
+		body := First(Filter(doc.DescendantNodes(), predicateIsTag("body")))
+		value := body.InnerText()
+		assertEqualsWithMsg(t, test.expected, value, "for ", test.html, ", got ", value)
+	}
+}
+
+func TestInnerTextOfPreformattedElements(t *testing.T) {
+	doc := parseBody(t, "<pre>a\n   b</pre><textarea> c  d </textarea><listing>e\n f</listing>")
+	assertEquals(t, "a\n   b", First(Filter(doc.DescendantNodes(), predicateIsTag("pre"))).InnerText())
+	assertEquals(t, " c  d ", First(Filter(doc.DescendantNodes(), predicateIsTag("textarea"))).InnerText())
+	assertEquals(t, "e\n f", First(Filter(doc.DescendantNodes(), predicateIsTag("listing"))).InnerText())
+}
+
+func TestInnerTextOfHiddenElements(t *testing.T) {
+	doc := parseBody(t, "<html><head><title> The  title </title></head><body>text<script>var x;</script></body></html>")
+
+	assertEquals(t, "text", doc.InnerText())
+	assertEquals(t, " The  title ", First(Filter(doc.DescendantNodes(), predicateIsTag("title"))).InnerText())
+	assertEquals(t, "var x;", First(Filter(doc.DescendantNodes(), predicateIsTag("script"))).InnerText())
+}
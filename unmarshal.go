@@ -0,0 +1,394 @@
+package gosoup
+
+import (
+	"cmp"
+	"encoding"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrNoMatch is the reason of a FieldError when no element matches the
+	// selector of a field.
+	ErrNoMatch = errors.New("gosoup: no element matches the selector")
+	// ErrNoAttribute is the reason of a FieldError when the matched element does
+	// not have the attribute of a field.
+	ErrNoAttribute = errors.New("gosoup: the element has no such attribute")
+)
+
+// FieldError describes why Unmarshal could not fill a field.
+type FieldError struct {
+	Field    string // the path of the field in the value, such as "Items[2].Price"
+	Selector string // the selector of the field, empty if it refers to the context node
+	Context  *Node  // the node the selector was applied to
+	Match    *Node  // the node matched by the selector, nil if there is none
+	Err      error  // the reason, ErrNoMatch, ErrNoAttribute or a conversion error
+}
+
+func (e *FieldError) Error() string {
+	where := describeNode(e.Context)
+	if e.Selector != "" {
+		where = fmt.Sprintf("selector %q in %s", e.Selector, where)
+	}
+	if e.Match != nil && e.Match != e.Context {
+		where = describeNode(e.Match) + " matched by " + where
+	}
+	return fmt.Sprintf("%s: %v, for %s", e.Field, e.Err, where)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// UnmarshalError is returned by Unmarshal when some fields could not be filled.
+// It lists all of them, in the order of the fields.
+type UnmarshalError struct {
+	Errors []*FieldError
+}
+
+func (e *UnmarshalError) Error() string {
+	if len(e.Errors) == 1 {
+		return "Unmarshal: " + e.Errors[0].Error()
+	}
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("Unmarshal: %d fields failed: %s", len(e.Errors), strings.Join(msgs, "; "))
+}
+
+// Unwrap returns the errors of the fields, so that errors.Is and errors.As look
+// into them.
+func (e *UnmarshalError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, err := range e.Errors {
+		errs[i] = err
+	}
+	return errs
+}
+
+// Unmarshal fills the struct pointed to by v with data found in the subtree of the
+// given node. Only the fields with a soup tag are filled, the tag giving the CSS
+// selector of the element holding the data, relative to the node:
+//
+//	type Article struct {
+//	    Title   string    `soup:"h1.title"`
+//	    Next    *url.URL  `soup:"a.next,attr=href,optional"`
+//	    Date    time.Time `soup:"time,attr=datetime"`
+//	    Tags    []string  `soup:"ul.tags > li"`
+//	    Author  Author    `soup:".author"`
+//	}
+//
+// The first element matching the selector is used, or all of them for slices. An
+// empty selector refers to the node itself, which is mostly useful with the attr
+// option. The options following the selector are:
+//
+//   - attr=name uses the value of the given attribute of the element, instead of
+//     its text
+//   - html uses the inner HTML of the element instead of its text
+//   - optional leaves the field untouched if no element matches the selector,
+//     instead of reporting an error
+//   - layout=... is the layout of time.Time fields, time.RFC3339 by default. It
+//     must be the last option, since the layout may contain commas.
+//
+// The selector ends at the first comma followed by an option. After a comma, html
+// and optional are always options, never type selectors: `soup:"title, html"`
+// gives the inner HTML of the title element. A selector list matching the html
+// element can use :root instead, as in `soup:"body, :root,attr=lang"`.
+//
+// The text of an element is given by InnerText, without leading and trailing
+// whitespace. It is converted to the type of the field, which can be a string, a
+// bool, an integer or float number, a time.Time, a time.Duration, a url.URL, or
+// any type implementing encoding.TextUnmarshaler. A field of type *Node receives
+// the element itself. Fields can also be pointers to these types, which are
+// allocated if the selector matches, nested structs, whose fields are filled
+// from the matched element, and slices, which receive all the matches and may be
+// empty. Embedded structs without soup tag are filled from the node itself.
+//
+// Unmarshal fills all the fields it can. If some of them cannot be filled,
+// because their selector matches nothing or because the data cannot be
+// converted, it returns an *UnmarshalError listing all of them, with the
+// position of the nodes involved if it is known.
+func Unmarshal(node *Node, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("Unmarshal: expected a non-nil pointer to a struct, got %T", v)
+	}
+	u := &unmarshaler{}
+	u.structFields(node, rv.Elem(), "")
+	if len(u.errs) > 0 {
+		return &UnmarshalError{u.errs}
+	}
+	return nil
+}
+
+// fieldTag is a parsed soup struct tag.
+type fieldTag struct {
+	selector *Selector // nil for the context node itself
+	attr     string
+	hasAttr  bool
+	html     bool
+	optional bool
+	layout   string
+}
+
+// source returns the source of the selector of this tag.
+func (t fieldTag) source() string {
+	if t.selector == nil {
+		return ""
+	}
+	return t.selector.String()
+}
+
+// tagSelectors caches the selectors compiled from struct tags.
+var tagSelectors sync.Map
+
+// isTagOption returns true if the given part of a struct tag is an option rather
+// than a part of a selector list. It is never called on the first part, which is
+// always the start of the selector.
+func isTagOption(part string) bool {
+	switch part {
+	case "html", "optional":
+		return true
+	}
+	return strings.HasPrefix(part, "attr=") || strings.HasPrefix(part, "layout=")
+}
+
+func parseFieldTag(tag string) (fieldTag, error) {
+	parts := strings.Split(tag, ",")
+	i := 1
+	for i < len(parts) && !isTagOption(strings.TrimSpace(parts[i])) {
+		i++
+	}
+	var t fieldTag
+	if source := strings.TrimSpace(strings.Join(parts[:i], ",")); source != "" {
+		if s, ok := tagSelectors.Load(source); ok {
+			t.selector = s.(*Selector)
+		} else {
+			s, err := CompileSelector(source)
+			if err != nil {
+				return t, err
+			}
+			tagSelectors.Store(source, s)
+			t.selector = s
+		}
+	}
+	for ; i < len(parts); i++ {
+		option := strings.TrimSpace(parts[i])
+		switch {
+		case option == "html":
+			t.html = true
+		case option == "optional":
+			t.optional = true
+		case strings.HasPrefix(option, "attr="):
+			t.attr, t.hasAttr = strings.TrimPrefix(option, "attr="), true
+		case strings.HasPrefix(option, "layout="):
+			t.layout = strings.TrimPrefix(strings.Join(parts[i:], ","), "layout=")
+			i = len(parts)
+		default:
+			return t, fmt.Errorf("unknown soup tag option %q", option)
+		}
+	}
+	return t, nil
+}
+
+// unmarshaler fills values, accumulating the errors of the fields.
+type unmarshaler struct {
+	errs []*FieldError
+}
+
+func (u *unmarshaler) fail(path string, tag fieldTag, context, match *Node, err error) {
+	u.errs = append(u.errs, &FieldError{
+		Field:    path,
+		Selector: tag.source(),
+		Context:  context,
+		Match:    match,
+		Err:      err,
+	})
+}
+
+// structFields fills the tagged fields of the given struct value from the given
+// node.
+func (u *unmarshaler) structFields(node *Node, sv reflect.Value, path string) {
+	t := sv.Type()
+	for i := range t.NumField() {
+		f := t.Field(i)
+		tag, tagged := f.Tag.Lookup("soup")
+		if !tagged && f.Anonymous && f.Type.Kind() == reflect.Struct {
+			u.structFields(node, sv.Field(i), path)
+			continue
+		}
+		if !tagged || tag == "-" {
+			continue
+		}
+		fieldPath := f.Name
+		if path != "" {
+			fieldPath = path + "." + f.Name
+		}
+		if !f.IsExported() {
+			u.fail(fieldPath, fieldTag{}, node, nil, errors.New("unexported field"))
+			continue
+		}
+		ft, err := parseFieldTag(tag)
+		if err != nil {
+			u.fail(fieldPath, fieldTag{}, node, nil, err)
+			continue
+		}
+		u.field(node, sv.Field(i), ft, fieldPath)
+	}
+}
+
+var nodePtrType = reflect.TypeFor[*Node]()
+
+// field fills the given field from the nodes matched by its tag in the subtree of
+// the given node.
+func (u *unmarshaler) field(node *Node, fv reflect.Value, tag fieldTag, path string) {
+	if fv.Kind() == reflect.Slice {
+		var matches []*Node
+		if tag.selector == nil {
+			matches = []*Node{node}
+		} else {
+			matches = node.SelectCompiled(tag.selector).All()
+		}
+		slice := reflect.MakeSlice(fv.Type(), len(matches), len(matches))
+		for i, m := range matches {
+			u.value(node, m, slice.Index(i), tag, path+"["+strconv.Itoa(i)+"]")
+		}
+		fv.Set(slice)
+		return
+	}
+	match := node
+	if tag.selector != nil {
+		match = node.SelectCompiled(tag.selector).First()
+	}
+	if match == nil {
+		if !tag.optional {
+			u.fail(path, tag, node, nil, ErrNoMatch)
+		}
+		return
+	}
+	u.value(node, match, fv, tag, path)
+}
+
+// value fills the given value from the given matched node.
+func (u *unmarshaler) value(context, match *Node, v reflect.Value, tag fieldTag, path string) {
+	t := v.Type()
+	switch {
+	case t == nodePtrType:
+		v.Set(reflect.ValueOf(match))
+		return
+	case t.Kind() == reflect.Pointer:
+		if v.IsNil() {
+			v.Set(reflect.New(t.Elem()))
+		}
+		u.value(context, match, v.Elem(), tag, path)
+		return
+	case t.Kind() == reflect.Struct && !isTextType(t):
+		u.structFields(match, v, path)
+		return
+	}
+	var s string
+	switch {
+	case tag.hasAttr:
+		if !match.HasAttr(tag.attr) {
+			u.fail(path, tag, context, match, ErrNoAttribute)
+			return
+		}
+		s = match.AttrOrDefault(tag.attr, "")
+	case tag.html:
+		s = match.InnerHTML()
+	default:
+		s = strings.TrimSpace(match.InnerText())
+	}
+	if err := convertText(s, v, tag.layout); err != nil {
+		u.fail(path, tag, context, match, err)
+	}
+}
+
+var (
+	timeType            = reflect.TypeFor[time.Time]()
+	durationType        = reflect.TypeFor[time.Duration]()
+	urlType             = reflect.TypeFor[url.URL]()
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+)
+
+// isTextType returns true if values of the given type are converted from text
+// rather than filled field by field.
+func isTextType(t reflect.Type) bool {
+	return t == timeType || t == urlType || reflect.PointerTo(t).Implements(textUnmarshalerType)
+}
+
+// convertText converts the given text to the type of the given value, and stores
+// it in the value.
+func convertText(s string, v reflect.Value, layout string) error {
+	t := v.Type()
+	switch t {
+	case timeType:
+		date, err := time.Parse(cmp.Or(layout, time.RFC3339), strings.TrimSpace(s))
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(date))
+		return nil
+	case durationType:
+		d, err := time.ParseDuration(strings.TrimSpace(s))
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	case urlType:
+		parsed, err := url.Parse(strings.TrimSpace(s))
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(*parsed))
+		return nil
+	}
+	if v.CanAddr() {
+		if tu, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			return tu.UnmarshalText([]byte(s))
+		}
+	}
+	var err error
+	switch t.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		var b bool
+		if b, err = strconv.ParseBool(strings.TrimSpace(s)); err == nil {
+			v.SetBool(b)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var i int64
+		if i, err = strconv.ParseInt(strings.TrimSpace(s), 10, t.Bits()); err == nil {
+			v.SetInt(i)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		var i uint64
+		if i, err = strconv.ParseUint(strings.TrimSpace(s), 10, t.Bits()); err == nil {
+			v.SetUint(i)
+		}
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		if f, err = strconv.ParseFloat(strings.TrimSpace(s), t.Bits()); err == nil {
+			v.SetFloat(f)
+		}
+	default:
+		return fmt.Errorf("unsupported field type %s", t)
+	}
+	if err != nil {
+		var numErr *strconv.NumError
+		if errors.As(err, &numErr) {
+			err = numErr.Err
+		}
+		return fmt.Errorf("cannot convert %q to %s: %w", s, t, err)
+	}
+	return nil
+}
@@ -0,0 +1,249 @@
+package gosoup
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+const productsHTML = `<html><body>
+<h1 class="title"> Our   products </h1>
+<p class="updated"><time datetime="2024-03-01T10:00:00Z">March 1st, 2024</time></p>
+<ul class="products">
+	<li class="item" data-id="1">
+		<a href="/p/1">Kettle</a>
+		<span class="price">19.90</span>
+		<span class="stock">12</span>
+		<span class="tag">kitchen</span><span class="tag">tea</span>
+	</li>
+	<li class="item" data-id="2">
+		<a href="/p/2">Toaster</a>
+		<span class="price">34.50</span>
+		<span class="stock">0</span>
+	</li>
+</ul>
+<div class="seller"><b>ACME</b>, since <i>Jan 2, 2006</i></div>
+<a class="next" href="/page/2">Next</a>
+</body></html>`
+
+type product struct {
+	ID    int      `soup:",attr=data-id"`
+	Name  string   `soup:"a"`
+	Link  url.URL  `soup:"a,attr=href"`
+	Price float64  `soup:".price"`
+	Stock *uint    `soup:".stock"`
+	Tags  []string `soup:".tag"`
+}
+
+type seller struct {
+	Name  string    `soup:"b"`
+	Since time.Time `soup:"i,layout=Jan 2, 2006"`
+}
+
+type pagination struct {
+	Next *url.URL `soup:"a.next, a[rel=next],attr=href"`
+	Prev *url.URL `soup:"a.prev,attr=href,optional"`
+}
+
+type productsPage struct {
+	pagination
+	Title      string    `soup:"h1.title"`
+	Updated    time.Time `soup:"p.updated time,attr=datetime"`
+	Products   []product `soup:"li.item"`
+	Names      []string  `soup:"ul > li > a"`
+	Seller     *seller   `soup:".seller"`
+	List       *Node     `soup:"ul.products"`
+	Sold       []product `soup:"li.sold"`
+	Missing    *string   `soup:".missing,optional"`
+	Ignored    string    `soup:"-"`
+	NoTag      string
+	SellerHTML string `soup:".seller,html"`
+}
+
+func TestUnmarshal(t *testing.T) {
+	doc := parseBody(t, productsHTML)
+	page := productsPage{NoTag: "untouched"}
+	if err := Unmarshal(doc, &page); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, "Our products", page.Title)
+	assertEquals(t, time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC), page.Updated)
+	assertEquals(t, 2, len(page.Products))
+
+	kettle := page.Products[0]
+	assertEquals(t, 1, kettle.ID)
+	assertEquals(t, "Kettle", kettle.Name)
+	assertEquals(t, "/p/1", kettle.Link.Path)
+	assertEquals(t, 19.9, kettle.Price)
+	assertEquals(t, uint(12), *kettle.Stock)
+	assertDatas(t, []string{"kitchen", "tea"}, kettle.Tags)
+	assertEquals(t, uint(0), *page.Products[1].Stock)
+	assertEquals(t, 0, len(page.Products[1].Tags))
+
+	assertDatas(t, []string{"Kettle", "Toaster"}, page.Names)
+	assertEquals(t, "ACME", page.Seller.Name)
+	assertEquals(t, 2006, page.Seller.Since.Year())
+	assertEquals(t, "<b>ACME</b>, since <i>Jan 2, 2006</i>", page.SellerHTML)
+	assert(t, page.List.HasClass("products"), "expected the list element")
+	assert(t, page.Sold != nil && len(page.Sold) == 0, "expected an empty slice")
+	assert(t, page.Missing == nil, "expected no value for an optional field")
+	assertEquals(t, "untouched", page.NoTag)
+	assertEquals(t, "/page/2", page.Next.String())
+	assert(t, page.Prev == nil, "expected no previous page")
+}
+
+type textValue struct {
+	upper string
+}
+
+func (v *textValue) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		return errors.New("empty value")
+	}
+	v.upper = strings.ToUpper(string(text))
+	return nil
+}
+
+func TestUnmarshalTypes(t *testing.T) {
+	var v struct {
+		Bool     bool          `soup:"#bool"`
+		Int8     int8          `soup:"#int"`
+		Uint16   uint16        `soup:"#int"`
+		Float32  float32       `soup:"#float"`
+		Duration time.Duration `soup:"#duration"`
+		Text     textValue     `soup:"#text"`
+		Texts    []*textValue  `soup:"#text"`
+		Raw      string        `soup:"#raw,attr=title"`
+		Numbers  []int         `soup:"#numbers span"`
+	}
+	doc := parseBody(t, `<b id="bool">true</b><b id="int"> 120 </b><b id="float">-1.5e3</b>`+
+		`<b id="duration">1h30m</b><b id="text">abc</b><b id="raw" title=" a  b "></b>`+
+		`<p id="numbers"><span>1</span><span>2</span></p>`)
+	if err := Unmarshal(doc, &v); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, true, v.Bool)
+	assertEquals(t, int8(120), v.Int8)
+	assertEquals(t, uint16(120), v.Uint16)
+	assertEquals(t, float32(-1500), v.Float32)
+	assertEquals(t, 90*time.Minute, v.Duration)
+	assertEquals(t, "ABC", v.Text.upper)
+	assertEquals(t, "ABC", v.Texts[0].upper)
+	assertEquals(t, " a  b ", v.Raw)
+	assertEquals(t, 2, len(v.Numbers))
+	assertEquals(t, 2, v.Numbers[1])
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	doc := parseWithPositions(t, productsHTML)
+	var v struct {
+		Title    string `soup:"h2.title"`
+		Products []struct {
+			Price int    `soup:".price"`
+			Image string `soup:"img,attr=src"`
+			Link  string `soup:"a,attr=title"`
+		} `soup:"li.item"`
+		Stock   int8              `soup:"li:nth-child(2) .stock, li .stock"`
+		Seller  time.Time         `soup:".seller i"`
+		Invalid string            `soup:"li[,attr=href"`
+		Option  string            `soup:"li,attr=href,text"`
+		Map     map[string]string `soup:"li"`
+		hidden  string            `soup:"li"`
+		Count   int               `soup:"ul.products"`
+	}
+	err := Unmarshal(doc, &v)
+	var unmarshalErr *UnmarshalError
+	if !errors.As(err, &unmarshalErr) {
+		t.Fatal("expected an UnmarshalError, got ", err)
+	}
+
+	var fields []string
+	for _, e := range unmarshalErr.Errors {
+		fields = append(fields, e.Field)
+	}
+	assertDatas(t, []string{
+		"Title",
+		"Products[0].Price", "Products[0].Image", "Products[0].Link",
+		"Products[1].Price", "Products[1].Image", "Products[1].Link",
+		"Seller", "Invalid", "Option", "Map", "hidden", "Count",
+	}, fields)
+	assert(t, errors.Is(err, ErrNoMatch), "expected ErrNoMatch")
+	assert(t, errors.Is(err, ErrNoAttribute), "expected ErrNoAttribute")
+	var selectorErr *SelectorError
+	assert(t, errors.As(err, &selectorErr), "expected a SelectorError")
+
+	// the values that could be converted are set
+	assertEquals(t, int8(12), v.Stock)
+	assertEquals(t, 2, len(v.Products))
+
+	assertEquals(t, `Title: gosoup: no element matches the selector, for selector "h2.title" in document at 1:1`,
+		unmarshalErr.Errors[0].Error())
+	assertEquals(t, `Products[0].Price: cannot convert "19.90" to int: invalid syntax, for <span> at 7:3 matched by selector ".price" in <li> at 5:2`,
+		unmarshalErr.Errors[1].Error())
+	assertEquals(t, `Products[1].Link: gosoup: the element has no such attribute, for <a> at 12:3 matched by selector "a" in <li> at 11:2`,
+		unmarshalErr.Errors[6].Error())
+	assertEquals(t, `Option: unknown soup tag option "text", for document at 1:1`, unmarshalErr.Errors[9].Error())
+	assertEquals(t, `Count: cannot convert "Kettle 19.90 12 kitchentea\nToaster 34.50 0" to int: invalid syntax, for <ul> at 4:1 matched by selector "ul.products" in document at 1:1`,
+		unmarshalErr.Errors[12].Error())
+	assert(t, strings.HasPrefix(err.Error(), "Unmarshal: 13 fields failed: Title: "), "unexpected message ", err)
+}
+
+func TestUnmarshalTarget(t *testing.T) {
+	doc := parseBody(t, productsHTML)
+	var title struct {
+		Title string `soup:"h1"`
+	}
+	assert(t, Unmarshal(doc, title) != nil, "expected an error for a non-pointer")
+	assert(t, Unmarshal(doc, (*productsPage)(nil)) != nil, "expected an error for a nil pointer")
+	var s string
+	assert(t, Unmarshal(doc, &s) != nil, "expected an error for a non-struct")
+
+	var single struct {
+		Title string `soup:"h1"`
+	}
+	err := Unmarshal(doc.FirstChild.LastChild, &single)
+	assert(t, err == nil, err)
+	assertEquals(t, "Our products", single.Title)
+
+	// selectors are relative to the given node
+	item := First(Filter(doc.DescendantNodes(), predicateIsTag("li")))
+	var scoped struct {
+		Names []string `soup:":scope > a"`
+		Body  string   `soup:"body,optional"`
+	}
+	err = Unmarshal(item, &scoped)
+	assert(t, err == nil, err)
+	assertDatas(t, []string{"Kettle"}, scoped.Names)
+	assertEquals(t, "", scoped.Body)
+}
+
+func TestUnmarshalTagSyntax(t *testing.T) {
+	doc := parseBody(t, `<html lang="en"><title>T</title><div title="a, b">x <b>y</b></div><p>y</p>`)
+	var v struct {
+		Inner   string    `soup:"div, html"`
+		List    []string  `soup:"div, p"`
+		Lang    string    `soup:"head, :root,attr=lang"`
+		Quote   string    `soup:"[title='a, b'],attr=title"`
+		Missing time.Time `soup:"time,optional,layout=Jan 2, 2006"`
+	}
+	err := Unmarshal(doc, &v)
+	assert(t, err == nil, err)
+	// html after a comma is the option, not the type selector
+	assertEquals(t, "x <b>y</b>", v.Inner)
+	assertEquals(t, 2, len(v.List))
+	assertEquals(t, "y", v.List[1])
+	assertEquals(t, "en", v.Lang)
+	assertEquals(t, "a, b", v.Quote)
+	assert(t, v.Missing.IsZero(), "unexpected time ", v.Missing)
+
+	tag, err := parseFieldTag("title, html")
+	assert(t, err == nil, err)
+	assertEquals(t, "title", tag.source())
+	assert(t, tag.html, "expected the html option")
+	tag, err = parseFieldTag("html")
+	assert(t, err == nil, err)
+	assertEquals(t, "html", tag.source())
+	assert(t, !tag.html, "expected no html option")
+}
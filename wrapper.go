@@ -3,16 +3,22 @@ package gosoup
 import (
 	"golang.org/x/net/html"
 	"io"
+	"unsafe"
 )
 
 // Parse returns the parse tree for the HTML from the given Reader. The input is
 // assumed to be UTF-8 encoded.
+//
+// The tree is the same as the one html.Parse returns, but it is built directly
+// from the tokens of the input, without building a tree of html.Node first. Use
+// ParseWithOptions to record the position of each node in the input.
+//
+// Unlike html.Parse, which rejects HTML that is nested deeper than 512 elements,
+// Parse accepts any nesting, and the trees can be traversed, converted and
+// rendered whatever their depth. Use ParseWithOptions with Options.MaxDepth to
+// limit the depth of the tree built from untrusted input.
 func Parse(r io.Reader) (*Node, error) {
-	n, err := html.Parse(r)
-	if err != nil {
-		return nil, err
-	}
-	return WrapTree(n), nil
+	return newParser(r, Options{}).parseDocument()
 }
 
 // Render renders the parse tree n to the given writer.
@@ -38,88 +44,39 @@ func Parse(r io.Reader) (*Node, error) {
 // become a tree containing <html>, <head> and <body> elements. Another example is
 // that the programmatic equivalent of "a<head>b</head>c" becomes
 // "<html><head><head/><body>abc</body></html>".
+//
+// The tree is rendered by html.Render, without being copied first, since
+// UnwrapTree converts it in constant time. Use RenderWithOptions to pretty-print
+// or minify the output.
 func Render(w io.Writer, n *Node) error {
-	return html.Render(w, UnwrapTree(n))
+	return RenderWithOptions(w, n, RenderOptions{})
 }
 
-// WrapTree converts the given html.Node and all its children into an equivalent
-// tree of gosoup.Node.
+// Node must have the same size as html.Node, see WrapTree. These declarations do
+// not compile otherwise, and TestNodeLayout checks the fields themselves.
+var (
+	_ [unsafe.Sizeof(Node{}) - unsafe.Sizeof(html.Node{})]struct{}
+	_ [unsafe.Sizeof(html.Node{}) - unsafe.Sizeof(Node{})]struct{}
+)
+
+// WrapTree returns the given html.Node as a Node, along with its whole tree.
 //
-// The returned node has a nil Parent field, and nil NextSibling and PrevSibling
-// fields. However, FirstChildren and LastChildren are populated, and the children
-// themselves are completely linked with all their fields.
+// Node has the same fields as html.Node, in the same order, so that the conversion
+// takes a constant time and does not copy anything: the returned node is the
+// given one, still linked to its parent, siblings and children, and the changes
+// made to the tree through one type are visible through the other. This makes it
+// possible to use gosoup with other code based on the html package, like a tree
+// returned by html.Parse. Parse and Render do not need such conversions, as they
+// work on trees of Node directly.
 func WrapTree(hnode *html.Node) *Node {
-	if hnode == nil {
-		return nil
-	}
-	n := new(Node)
-
-	// copy data
-	n.Type = NodeType(hnode.Type)
-	n.DataAtom = hnode.DataAtom
-	n.Data = hnode.Data
-	n.Namespace = hnode.Namespace
-	n.Attrs = make([]Attribute, 0, len(hnode.Attr))
-	for _, hattr := range hnode.Attr {
-		n.Attrs = append(n.Attrs, Attribute(hattr))
-	}
-
-	// link to children nodes
-	if hnode.FirstChild == nil {
-		// the node has no children
-		return n
-	}
-
-	n.FirstChild = WrapTree(hnode.FirstChild)
-	n.FirstChild.Parent = n
-	n.LastChild = n.FirstChild
-	for hchild := hnode.FirstChild.NextSibling; hchild != nil; hchild = hchild.NextSibling {
-		newChild := WrapTree(hchild)
-		newChild.Parent = n
-		newChild.PrevSibling = n.LastChild
-		n.LastChild.NextSibling = newChild
-		n.LastChild = newChild
-	}
-	return n
+	return (*Node)(unsafe.Pointer(hnode))
 }
 
-// WrapTree converts the given gosoup.Node and all its children into an equivalent
-// tree of html.Node.
+// UnwrapTree returns the given Node as an html.Node, along with its whole tree.
 //
-// The returned node has a nil Parent field, and nil NextSibling and PrevSibling
-// fields. However, FirstChildren and LastChildren are populated, and the children
-// themselves are completely linked with all their fields.
+// Like WrapTree, the conversion takes a constant time and the returned node is the
+// given one, so that it can be passed to code based on the html package, like
+// html.Render, without copying the tree.
 func UnwrapTree(node *Node) *html.Node {
-	if node == nil {
-		return nil
-	}
-	h := new(html.Node)
-
-	// copy data
-	h.Type = html.NodeType(node.Type)
-	h.DataAtom = node.DataAtom
-	h.Data = node.Data
-	h.Namespace = node.Namespace
-	h.Attr = make([]html.Attribute, 0, len(node.Attrs))
-	for _, attr := range node.Attrs {
-		h.Attr = append(h.Attr, html.Attribute(attr))
-	}
-
-	// link to children nodes
-	if node.FirstChild == nil {
-		// the node has no children
-		return h
-	}
-
-	h.FirstChild = UnwrapTree(node.FirstChild)
-	h.FirstChild.Parent = h
-	h.LastChild = h.FirstChild
-	for child := node.FirstChild.NextSibling; child != nil; child = child.NextSibling {
-		newChild := UnwrapTree(child)
-		newChild.Parent = h
-		newChild.PrevSibling = h.LastChild
-		h.LastChild.NextSibling = newChild
-		h.LastChild = newChild
-	}
-	return h
+	return (*html.Node)(unsafe.Pointer(node))
 }
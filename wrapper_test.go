@@ -0,0 +1,405 @@
+package gosoup
+
+import (
+	"bytes"
+	"fmt"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// renderWithHTML renders the given tree with html.Render, the reference for Render.
+func renderWithHTML(t testing.TB, n *Node) string {
+	var b bytes.Buffer
+	if err := html.Render(&b, UnwrapTree(n)); err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
+
+func TestRenderMatchesHTMLRender(t *testing.T) {
+	inputs := []string{
+		HTML,
+		HTML_POSITIONS,
+		`<!DOCTYPE html PUBLIC "-//W3C//DTD HTML 4.01//EN" "http://www.w3.org/TR/html4/strict.dtd"><p>x`,
+		`<!DOCTYPE html SYSTEM "about:legacy-compat"><p>x`,
+		`<!-- a & b --><!-->--><p>a &amp; b &lt;c&gt; "quoted" 'single'` + "\r" + `</p>`,
+		`<p title="a &amp; b &quot;c&quot; 'd'" data-x="<>">x</p>`,
+		`<script>if (a < b && c > d) { x = "</p>" }</script><style>p > b { color: red }</style>`,
+		"<pre>\n\nindented</pre><textarea>\nx</textarea><listing>\ny</listing>",
+		`<noscript><b>x</b></noscript><xmp><b>x</b></xmp><iframe>a<b</iframe>`,
+		`<svg viewBox="0 0 10 10"><style>a < b</style><foreignObject><p>x</p></foreignObject><path d="M0 0"/></svg>`,
+		`<math><mi>x</mi><annotation-xml encoding="text/html"><p>a<b</p></annotation-xml></math>`,
+		`<table><tr><td>1<td>2</table><img src=a.png alt="a > b"><br><input value=x>`,
+		`<p>before<plaintext>a <b> & c</p>`,
+	}
+	for _, input := range inputs {
+		doc, err := Parse(strings.NewReader(input))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEqualsWithMsg(t, renderWithHTML(t, doc), render(t, doc), "input: ", input)
+		// the renderer of the options, used without them by OuterHTML
+		assertEqualsWithMsg(t, renderWithHTML(t, doc), doc.OuterHTML(), "input: ", input)
+	}
+}
+
+func TestRenderErrors(t *testing.T) {
+	var b bytes.Buffer
+	img := &Node{Type: ElementNode, Data: "img"}
+	img.AppendChild(&Node{Type: TextNode, Data: "x"})
+	assert(t, Render(&b, img) != nil, "expected an error for a void element with children")
+	assert(t, Render(&b, &Node{Type: ErrorNode}) != nil, "expected an error for an error node")
+	doctype := &Node{Type: DoctypeNode, Data: "html", Attrs: []Attribute{{Key: "system", Val: `a"b'c`}}}
+	assert(t, Render(&b, doctype) != nil, "expected an error for a doctype with both quotes")
+}
+
+// dumpTree returns a description of the given tree, one node per line, to compare
+// trees in tests.
+func dumpTree(root *Node) string {
+	var b strings.Builder
+	for n := range root.All() {
+		fmt.Fprintf(&b, "%s%d %d %q %q %q", strings.Repeat("  ", n.Depth()), n.Type, n.DataAtom, n.Namespace, n.Data, n.Attrs)
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// parserInputs are inputs exercising the various parts of the tree construction
+// algorithm.
+var parserInputs = []string{
+	HTML,
+	HTML_POSITIONS,
+	HTML_SELECTORS,
+	``,
+	`text`,
+	`<!DOCTYPE html PUBLIC "-//W3C//DTD HTML 4.01 Transitional//EN"><p>x<table>`,
+	`<!-- before --><html lang=en><head><title>a</title><link rel=x></head> <body a=1><body b=2>x</body></html><!-- after -->`,
+	`<p><b><b><b><b>x<p>y`,
+	`<p><b class=x><b class=x><b class=x><b class=x>x<p>y`,
+	`<a href=1>a<p>b</a>c`,
+	`<b>1<p>2</b>3</p>`,
+	`<div><b><i><u>x</b>y</i>z</div>`,
+	`<table><tr><td>1</td>moved<td>2</td></tr>also<b>moved</b></table>`,
+	`<table><caption>c<table>x</table></caption><colgroup><col></colgroup><tbody><tr><th>h</table>`,
+	`<select><option>a<option>b<optgroup><option>c</select><select><input>`,
+	`<ul><li>a<li>b<ol><li>c</ul><dl><dt>t<dd>d</dl>`,
+	`<template><tr><td>x</td></tr></template><template><col></template>`,
+	`<frameset><frame><noframes>x</noframes></frameset>`,
+	`<ruby>a<rb>b<rt>c<rp>d<rtc>e</ruby>`,
+	`<form><form><input></form><button><button>x`,
+	`<svg viewBox="0 0 1 1"><foreignObject><p>x</p></foreignObject><clippath></clippath><font color=red>y</font></svg>`,
+	`<math definitionurl=x><mi>x</mi><annotation-xml encoding="text/html"><p>a</p></annotation-xml><mglyph></math>`,
+	`<svg><![CDATA[a<b]]><title><b>t</b></title></svg>`,
+	`<pre>\n\nx</pre><textarea>\ny</textarea><listing>\nz</listing>`,
+	`<script>a<b</script><style>a<b</style><noscript>x</noscript><iframe><b></iframe><xmp><b></xmp>`,
+	"<p>a\x00b</p><table>\x00</table>",
+	`<image src=x><isindex><nobr>a<nobr>b</nobr><marquee>m</marquee><applet>x</applet>`,
+	`<h1>a<h2>b</h1><p>c</h2>`,
+	`<body><p>x</body></html>y`,
+	`<head></head><body></body><p>after</p>`,
+	`<p>before<plaintext>a <b> & c</p>`,
+}
+
+func TestParseMatchesHTMLParse(t *testing.T) {
+	// the nested prefixes make the parser index the deep stack of open elements
+	deep := strings.Repeat("<div>", maxScannedScope)
+	for _, prefix := range []string{"", deep, "<svg><foreignObject>" + deep} {
+		for _, input := range parserInputs {
+			input = prefix + input
+			h, err := html.Parse(strings.NewReader(input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			doc, err := Parse(strings.NewReader(input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertConsistent(t, doc)
+			assertEqualsWithMsg(t, dumpTree(WrapTree(h)), dumpTree(doc), "input: ", input, "\n", dumpTree(doc))
+		}
+	}
+}
+
+func TestParseFragmentMatchesHTMLParseFragment(t *testing.T) {
+	contexts := []*html.Node{
+		nil,
+		{Type: html.ElementNode, DataAtom: atom.Body, Data: "body"},
+		{Type: html.ElementNode, DataAtom: atom.Table, Data: "table"},
+		{Type: html.ElementNode, DataAtom: atom.Tr, Data: "tr"},
+		{Type: html.ElementNode, DataAtom: atom.Select, Data: "select"},
+		{Type: html.ElementNode, DataAtom: atom.Template, Data: "template"},
+		{Type: html.ElementNode, DataAtom: atom.Textarea, Data: "textarea"},
+		{Type: html.ElementNode, DataAtom: atom.Svg, Data: "svg", Namespace: "svg"},
+	}
+	for _, input := range parserInputs {
+		for _, hcontext := range contexts {
+			var context *Node
+			where := "no context"
+			if hcontext != nil {
+				context = WrapTree(hcontext)
+				where = describeNode(context)
+			}
+			hnodes, herr := html.ParseFragment(strings.NewReader(input), hcontext)
+			nodes, err := ParseFragment(strings.NewReader(input), context)
+			// html.ParseFragment fails on some inputs without context, ParseFragment must fail too
+			assertEqualsWithMsg(t, herr != nil, err != nil, "input: ", input, " in ", where, ": ", herr, err)
+			if err != nil {
+				continue
+			}
+			var expected, actual strings.Builder
+			for _, h := range hnodes {
+				expected.WriteString(dumpTree(WrapTree(h)))
+			}
+			for _, n := range nodes {
+				actual.WriteString(dumpTree(n))
+			}
+			assertEqualsWithMsg(t, expected.String(), actual.String(), "input: ", input, " in ", where)
+		}
+	}
+}
+
+const benchmarkParagraphs = 10000
+
+func benchmarkDocument() string {
+	return "<html><body>" + strings.Repeat(`<p class="text">text <b>bold</b> <a href="/x">link</a></p>`, benchmarkParagraphs) + "</body></html>"
+}
+
+func BenchmarkParse(b *testing.B) {
+	input := benchmarkDocument()
+	b.SetBytes(int64(len(input)))
+	for b.Loop() {
+		if _, err := Parse(strings.NewReader(input)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkHTMLParse(b *testing.B) {
+	input := benchmarkDocument()
+	b.SetBytes(int64(len(input)))
+	for b.Loop() {
+		if _, err := html.Parse(strings.NewReader(input)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseWithPositions(b *testing.B) {
+	input := benchmarkDocument()
+	b.SetBytes(int64(len(input)))
+	for b.Loop() {
+		if _, err := ParseWithOptions(strings.NewReader(input), Options{RecordPositions: true}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// copyTree returns a copy of the given tree of html.Node as a tree of Node, the
+// way WrapTree used to convert trees before Node had the layout of html.Node.
+func copyTree(h *html.Node) *Node {
+	n := &Node{Type: NodeType(h.Type), DataAtom: h.DataAtom, Data: h.Data, Namespace: h.Namespace}
+	n.Attrs = make([]Attribute, 0, len(h.Attr))
+	for _, a := range h.Attr {
+		n.Attrs = append(n.Attrs, Attribute(a))
+	}
+	for c := h.FirstChild; c != nil; c = c.NextSibling {
+		appendChild(n, copyTree(c))
+	}
+	return n
+}
+
+// copyHTMLTree returns a copy of the given tree of Node as a tree of html.Node,
+// the way UnwrapTree used to convert trees.
+func copyHTMLTree(n *Node) *html.Node {
+	h := &html.Node{Type: html.NodeType(n.Type), DataAtom: n.DataAtom, Data: n.Data, Namespace: n.Namespace}
+	h.Attr = make([]html.Attribute, 0, len(n.Attrs))
+	for _, a := range n.Attrs {
+		h.Attr = append(h.Attr, html.Attribute(a))
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		h.AppendChild(copyHTMLTree(c))
+	}
+	return h
+}
+
+// BenchmarkCopyParse measures the way Parse used to work, by copying the tree
+// returned by html.Parse.
+func BenchmarkCopyParse(b *testing.B) {
+	input := benchmarkDocument()
+	b.SetBytes(int64(len(input)))
+	for b.Loop() {
+		h, err := html.Parse(strings.NewReader(input))
+		if err != nil {
+			b.Fatal(err)
+		}
+		copyTree(h)
+	}
+}
+
+// BenchmarkRender should match BenchmarkHTMLRender: without options, Render calls
+// html.Render on the unwrapped tree.
+func BenchmarkRender(b *testing.B) {
+	doc, err := Parse(strings.NewReader(benchmarkDocument()))
+	if err != nil {
+		b.Fatal(err)
+	}
+	var buf bytes.Buffer
+	for b.Loop() {
+		buf.Reset()
+		if err := Render(&buf, doc); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkHTMLRender(b *testing.B) {
+	doc, err := Parse(strings.NewReader(benchmarkDocument()))
+	if err != nil {
+		b.Fatal(err)
+	}
+	var buf bytes.Buffer
+	for b.Loop() {
+		buf.Reset()
+		if err := html.Render(&buf, UnwrapTree(doc)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkCopyRender measures the way Render used to work, by copying the tree
+// before calling html.Render.
+func BenchmarkCopyRender(b *testing.B) {
+	doc, err := Parse(strings.NewReader(benchmarkDocument()))
+	if err != nil {
+		b.Fatal(err)
+	}
+	var buf bytes.Buffer
+	for b.Loop() {
+		buf.Reset()
+		if err := html.Render(&buf, copyHTMLTree(doc)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestNodeLayout(t *testing.T) {
+	nodeType := reflect.TypeFor[Node]()
+	htmlType := reflect.TypeFor[html.Node]()
+	assertEquals(t, htmlType.Size(), nodeType.Size())
+	assertEquals(t, htmlType.NumField(), nodeType.NumField())
+	for i := range nodeType.NumField() {
+		f, h := nodeType.Field(i), htmlType.Field(i)
+		assertEqualsWithMsg(t, h.Offset, f.Offset, "field ", f.Name)
+		assertEqualsWithMsg(t, h.Type.Kind(), f.Type.Kind(), "field ", f.Name)
+		assertEqualsWithMsg(t, h.Type.Size(), f.Type.Size(), "field ", f.Name)
+		if f.Type.Kind() == reflect.Slice {
+			assert(t, f.Type.Elem().ConvertibleTo(h.Type.Elem()), "field ", f.Name, " has another element type")
+		}
+	}
+}
+
+func TestWrapTreeSharesNodes(t *testing.T) {
+	h, err := html.Parse(strings.NewReader(`<p>a</p><p>b</p>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := WrapTree(h)
+	assert(t, UnwrapTree(doc) == h, "expected the same node")
+	body := First(Filter(doc.DescendantNodes(), predicateIsTag("body")))
+	assert(t, UnwrapTree(body.Parent) == h.FirstChild, "expected the node to stay linked")
+
+	// the changes are visible through both types
+	body.LastChild.SetAttr("class", "x")
+	body.RemoveChild(body.FirstChild)
+	var b bytes.Buffer
+	if err := html.Render(&b, h); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, `<html><head></head><body><p class="x">b</p></body></html>`, b.String())
+	h.FirstChild.LastChild.AppendChild(&html.Node{Type: html.TextNode, Data: "c"})
+	assertEquals(t, "bc", body.Text())
+
+	assert(t, WrapTree(nil) == nil, "expected nil")
+	assert(t, UnwrapTree(nil) == nil, "expected nil")
+}
+
+func TestDeepTree(t *testing.T) {
+	const depth = 100000
+	input := strings.Repeat("<div>", depth) + "deep"
+
+	// html.Parse rejects such nesting, but Parse does not
+	doc, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertConsistent(t, doc)
+	body := First(Filter(doc.DescendantNodes(), predicateIsTag("body")))
+	root := body.FirstChild
+	assert(t, root != nil, "expected a div in the body")
+
+	assertEquals(t, depth, len(root.Descendants().All()))
+	count := 0
+	for range root.DescendantNodes() {
+		count++
+	}
+	assertEquals(t, depth, count)
+	assertEquals(t, "deep", root.Text())
+	assertEquals(t, "deep", root.InnerText())
+	result, err := doc.EvaluateXPath("count(//div)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, float64(depth), result.Number())
+
+	expected := input + strings.Repeat("</div>", depth)
+	assertEquals(t, expected, render(t, root))
+	assertEquals(t, expected, renderWithHTML(t, root))
+	assertEquals(t, expected, root.OuterHTML())
+	assertEquals(t, expected, render(t, root.Clone(true)))
+	assertEquals(t, expected, render(t, WrapTree(UnwrapTree(root))))
+	assertEquals(t, "<html><head></head><body>"+expected+"</body></html>", render(t, doc))
+}
+
+func FuzzParse(f *testing.F) {
+	f.Add(HTML)
+	f.Add(HTML_POSITIONS)
+	f.Add(`<!DOCTYPE html><table><tr><td>1<td>2</table><plaintext>a<b>`)
+	f.Add(`<svg><style>a < b</style><foreignObject><p>x</svg><math><mi>y</math>`)
+	f.Add(`<ul><li>a<li>b</ul><pre>` + "\n\n" + `x</pre><!-- c --><script>"</p>"</script>`)
+	for _, input := range parserInputs {
+		f.Add(input)
+	}
+	f.Fuzz(func(t *testing.T, input string) {
+		for range NewStream(strings.NewReader(input)).Subtrees(func(Event) bool { return true }) {
+		}
+		doc, err := Parse(strings.NewReader(input))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertConsistent(t, doc)
+		assertPositions(t, input, doc)
+		doc.InnerText()
+		h, herr := html.Parse(strings.NewReader(input))
+		if herr != nil {
+			// html.Parse rejects the elements nested deeper than 512, which Parse accepts
+			return
+		}
+		assertEquals(t, dumpTree(WrapTree(h)), dumpTree(doc))
+
+		var b, expected bytes.Buffer
+		err = Render(&b, doc)
+		expectedErr := html.Render(&expected, UnwrapTree(doc))
+		if (err == nil) != (expectedErr == nil) {
+			t.Fatalf("Render error %v, html.Render error %v", err, expectedErr)
+		}
+		if err != nil {
+			return
+		}
+		assertEquals(t, expected.String(), b.String())
+		assertEquals(t, expected.String(), doc.OuterHTML())
+		assertEquals(t, b.String(), render(t, doc.Clone(true)))
+	})
+}
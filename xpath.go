@@ -0,0 +1,1574 @@
+package gosoup
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// XPathError is returned when an XPath expression cannot be compiled or evaluated.
+type XPathError struct {
+	Expr   string // the expression that failed
+	Offset int    // byte offset in Expr of the part that caused the error
+	Msg    string // description of the problem
+	Node   *Node  // the context node, for errors during evaluation
+}
+
+func (e *XPathError) Error() string {
+	if e.Node != nil {
+		return fmt.Sprintf("xpath %q: %s at offset %d, evaluated on %s", e.Expr, e.Msg, e.Offset, describeNode(e.Node))
+	}
+	return fmt.Sprintf("xpath %q: %s at offset %d", e.Expr, e.Msg, e.Offset)
+}
+
+// XPath is a compiled XPath 1.0 expression, which can be evaluated on nodes.
+//
+// All axes, node tests, predicates, operators and functions of the XPath 1.0 core
+// function library are supported. Variable references are not.
+//
+// The data model is mapped onto gosoup trees as follows: the document node is the
+// root node, doctype nodes are ignored, and element namespaces are matched by
+// prefix, as in svg:rect. A name test without prefix only matches nodes without
+// namespace, so //svg matches nothing while //svg:svg matches <svg> elements.
+// Attributes are nodes of the data model but not of gosoup trees: node-sets give
+// them as XPathNode values, see XPathResult.NodeSet.
+type XPath struct {
+	source string
+	root   xpathExpr
+}
+
+// CompileXPath parses the given XPath 1.0 expression. If the expression is invalid,
+// the returned error is an *XPathError giving the position of the problem.
+func CompileXPath(expr string) (*XPath, error) {
+	toks, err := lexXPath(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &xpathParser{src: expr, toks: toks}
+	root, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+	return &XPath{expr, root}, nil
+}
+
+// MustCompileXPath is like CompileXPath but panics if the expression cannot be
+// compiled. It simplifies the initialization of global expression variables.
+func MustCompileXPath(expr string) *XPath {
+	x, err := CompileXPath(expr)
+	if err != nil {
+		panic(err)
+	}
+	return x
+}
+
+// String returns the source text of this expression.
+func (x *XPath) String() string {
+	return x.source
+}
+
+// Evaluate evaluates this expression using the given node as context node.
+func (x *XPath) Evaluate(node *Node) (result XPathResult, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			xerr, ok := r.(*XPathError)
+			if !ok {
+				panic(r)
+			}
+			xerr.Expr = x.source
+			xerr.Node = node
+			err = xerr
+		}
+	}()
+	ev := &xpathEvaluator{}
+	return XPathResult{x.root.eval(&xpathContext{XPathNode{Node: node}, 1, 1, ev})}, nil
+}
+
+// XPath returns an iterator on the nodes selected by the given XPath expression,
+// evaluated with this node as context node, in document order.
+//
+// An error is returned if the expression is invalid, if it does not evaluate to
+// a node-set, or if it selects attributes, which are not nodes of the tree: use
+// EvaluateXPath for such expressions.
+func (node *Node) XPath(expr string) (NodeIterator, error) {
+	x, err := CompileXPath(expr)
+	if err != nil {
+		return emptyNodeIterator(), err
+	}
+	return node.XPathCompiled(x)
+}
+
+// XPathFirst returns the first node in document order selected by the given XPath
+// expression, evaluated with this node as context node, or nil if no node is
+// selected.
+func (node *Node) XPathFirst(expr string) (*Node, error) {
+	it, err := node.XPath(expr)
+	if err != nil {
+		return nil, err
+	}
+	return it.First(), nil
+}
+
+// XPathCompiled returns an iterator on the nodes selected by the given compiled
+// XPath expression, evaluated with this node as context node, in document order.
+func (node *Node) XPathCompiled(x *XPath) (NodeIterator, error) {
+	res, err := x.Evaluate(node)
+	if err != nil {
+		return emptyNodeIterator(), err
+	}
+	if res.Type() != XPathNodeSet {
+		return emptyNodeIterator(), &XPathError{x.source, 0, "expression does not evaluate to a node-set", node}
+	}
+	if slices.ContainsFunc(res.NodeSet(), XPathNode.IsAttr) {
+		return emptyNodeIterator(), &XPathError{x.source, 0, "expression selects attributes", node}
+	}
+	return res.Nodes(), nil
+}
+
+// EvaluateXPath evaluates the given XPath expression with this node as context
+// node, and returns its result, whatever its type.
+func (node *Node) EvaluateXPath(expr string) (XPathResult, error) {
+	x, err := CompileXPath(expr)
+	if err != nil {
+		return XPathResult{}, err
+	}
+	return x.Evaluate(node)
+}
+
+// XPathResultType is the type of the result of an XPath expression.
+type XPathResultType int
+
+const (
+	XPathNodeSet XPathResultType = iota
+	XPathBoolean
+	XPathNumber
+	XPathString
+)
+
+// XPathResult is the result of the evaluation of an XPath expression. Whatever
+// its actual type, it can be converted to the other types following the rules of
+// the XPath boolean(), number() and string() functions.
+type XPathResult struct {
+	value interface{}
+}
+
+// Type returns the actual type of this result.
+func (r XPathResult) Type() XPathResultType {
+	switch r.value.(type) {
+	case bool:
+		return XPathBoolean
+	case float64:
+		return XPathNumber
+	case string:
+		return XPathString
+	}
+	return XPathNodeSet
+}
+
+// NodeSet returns the nodes of this result in document order, attributes
+// included. It returns nil if this result is not a node-set.
+func (r XPathResult) NodeSet() []XPathNode {
+	nodes, _ := r.value.([]XPathNode)
+	return nodes
+}
+
+// Nodes returns an iterator on the nodes of this result, in document order. It
+// provides no nodes if this result is not a node-set. Attributes are left out,
+// since they are not nodes of the tree: NodeSet returns them.
+func (r XPathResult) Nodes() NodeIterator {
+	return NewNodeIterator(func(yield func(*Node) bool) {
+		for _, n := range r.NodeSet() {
+			if !n.IsAttr() && !yield(n.Node) {
+				return
+			}
+		}
+	})
+}
+
+// XPathNode is a node of a node-set, as defined by the XPath data model. It is
+// either a node of the tree or an attribute, which is given along with the element
+// owning it.
+type XPathNode struct {
+	Node *Node     // the node, or the element owning the attribute
+	Attr Attribute // the attribute, if IsAttr returns true
+
+	attr int // index of the attribute in Node.Attrs plus one, 0 for a tree node
+}
+
+// IsAttr returns true if this node is the attribute Attr of the element Node,
+// rather than Node itself.
+func (n XPathNode) IsAttr() bool {
+	return n.attr > 0
+}
+
+// Bool returns this result converted to a boolean.
+func (r XPathResult) Bool() bool {
+	return xpathBool(r.value)
+}
+
+// Number returns this result converted to a number.
+func (r XPathResult) Number() float64 {
+	return xpathNumber(r.value)
+}
+
+// String returns this result converted to a string.
+func (r XPathResult) String() string {
+	return xpathString(r.value)
+}
+
+//
+// Lexer
+//
+
+type xpathTokenKind int
+
+const (
+	xtEOF      xpathTokenKind = iota
+	xtNumber                  // a number literal
+	xtLiteral                 // a string literal
+	xtName                    // a name test: name, prefix:name or prefix:*
+	xtStar                    // the name test *
+	xtOperator                // and or mod div * / // | + - = != < <= > >=
+	xtFunction                // a function name, followed by '('
+	xtNodeType                // comment, text, processing-instruction or node, followed by '('
+	xtAxis                    // an axis name, followed by '::'
+	xtVariable                // $name
+	xtPunct                   // ( ) [ ] . .. @ , ::
+)
+
+type xpathToken struct {
+	kind xpathTokenKind
+	val  string
+	num  float64
+	pos  int
+}
+
+func xpathSyntaxError(src string, pos int, format string, args ...interface{}) *XPathError {
+	return &XPathError{Expr: src, Offset: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func isXPathSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
+
+// xpathFields splits s around runs of XML whitespace. Unlike strings.Fields, it
+// doesn't split on other Unicode spaces such as U+00A0.
+func xpathFields(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return r <= ' ' && isXPathSpace(byte(r))
+	})
+}
+
+func isNCNameStart(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c >= utf8.RuneSelf
+}
+
+func isNCNameChar(c byte) bool {
+	return isNCNameStart(c) || c >= '0' && c <= '9' || c == '-' || c == '.'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func lexXPath(src string) ([]xpathToken, error) {
+	var toks []xpathToken
+	pos := 0
+	skipSpace := func() {
+		for pos < len(src) && isXPathSpace(src[pos]) {
+			pos++
+		}
+	}
+	// operatorExpected implements the disambiguation rule of the XPath spec:
+	// if there is a preceding token which is not one of @, ::, (, [, , or an
+	// operator, then * is the multiply operator and a name is an operator name.
+	operatorExpected := func() bool {
+		if len(toks) == 0 {
+			return false
+		}
+		prev := toks[len(toks)-1]
+		switch prev.kind {
+		case xtOperator:
+			return false
+		case xtPunct:
+			return prev.val == ")" || prev.val == "]" || prev.val == "." || prev.val == ".."
+		}
+		return true
+	}
+	scanNCName := func() string {
+		start := pos
+		for pos < len(src) && isNCNameChar(src[pos]) {
+			pos++
+		}
+		return src[start:pos]
+	}
+
+	for {
+		skipSpace()
+		if pos >= len(src) {
+			toks = append(toks, xpathToken{kind: xtEOF, pos: pos})
+			return toks, nil
+		}
+		start := pos
+		c := src[pos]
+		tok := xpathToken{pos: start}
+		switch {
+		case c == '(' || c == ')' || c == '[' || c == ']' || c == ',' || c == '@':
+			tok.kind, tok.val = xtPunct, string(c)
+			pos++
+		case c == ':' && pos+1 < len(src) && src[pos+1] == ':':
+			tok.kind, tok.val = xtPunct, "::"
+			pos += 2
+		case c == '.' && (pos+1 >= len(src) || !isDigit(src[pos+1])):
+			tok.kind, tok.val = xtPunct, "."
+			pos++
+			if pos < len(src) && src[pos] == '.' {
+				tok.val = ".."
+				pos++
+			}
+		case isDigit(c) || c == '.':
+			for pos < len(src) && isDigit(src[pos]) {
+				pos++
+			}
+			if pos < len(src) && src[pos] == '.' {
+				pos++
+				for pos < len(src) && isDigit(src[pos]) {
+					pos++
+				}
+			}
+			tok.kind, tok.val = xtNumber, src[start:pos]
+			tok.num, _ = strconv.ParseFloat(tok.val, 64)
+		case c == '"' || c == '\'':
+			end := strings.IndexByte(src[pos+1:], c)
+			if end < 0 {
+				return nil, xpathSyntaxError(src, start, "unterminated string literal")
+			}
+			tok.kind, tok.val = xtLiteral, src[pos+1:pos+1+end]
+			pos += end + 2
+		case c == '/':
+			tok.kind, tok.val = xtOperator, "/"
+			pos++
+			if pos < len(src) && src[pos] == '/' {
+				tok.val = "//"
+				pos++
+			}
+		case c == '|' || c == '+' || c == '-' || c == '=':
+			tok.kind, tok.val = xtOperator, string(c)
+			pos++
+		case c == '!':
+			if pos+1 >= len(src) || src[pos+1] != '=' {
+				return nil, xpathSyntaxError(src, start, "unexpected '!'")
+			}
+			tok.kind, tok.val = xtOperator, "!="
+			pos += 2
+		case c == '<' || c == '>':
+			tok.kind, tok.val = xtOperator, string(c)
+			pos++
+			if pos < len(src) && src[pos] == '=' {
+				tok.val += "="
+				pos++
+			}
+		case c == '*':
+			pos++
+			if operatorExpected() {
+				tok.kind, tok.val = xtOperator, "*"
+			} else {
+				tok.kind, tok.val = xtStar, "*"
+			}
+		case c == '$':
+			pos++
+			name := scanNCName()
+			if name == "" {
+				return nil, xpathSyntaxError(src, start, "expected variable name after '$'")
+			}
+			tok.kind, tok.val = xtVariable, name
+		case isNCNameStart(c):
+			name := scanNCName()
+			if operatorExpected() {
+				switch name {
+				case "and", "or", "mod", "div":
+					tok.kind, tok.val = xtOperator, name
+				default:
+					return nil, xpathSyntaxError(src, start, "unexpected name %q, expected an operator", name)
+				}
+				break
+			}
+			// a QName or a prefix:* name test
+			if pos+1 < len(src) && src[pos] == ':' && src[pos+1] != ':' {
+				if src[pos+1] == '*' {
+					pos += 2
+					name += ":*"
+				} else if isNCNameStart(src[pos+1]) {
+					pos++
+					name += ":" + scanNCName()
+				}
+			}
+			save := pos
+			skipSpace()
+			switch {
+			case pos < len(src) && src[pos] == '(':
+				switch name {
+				case "comment", "text", "processing-instruction", "node":
+					tok.kind = xtNodeType
+				default:
+					tok.kind = xtFunction
+				}
+			case pos+1 < len(src) && src[pos] == ':' && src[pos+1] == ':':
+				tok.kind = xtAxis
+			default:
+				tok.kind = xtName
+			}
+			pos = save
+			tok.val = name
+		default:
+			r, _ := utf8.DecodeRuneInString(src[pos:])
+			return nil, xpathSyntaxError(src, start, "unexpected %q", r)
+		}
+		toks = append(toks, tok)
+	}
+}
+
+//
+// Parser
+//
+
+type xpathParser struct {
+	src  string
+	toks []xpathToken
+	i    int
+}
+
+func (p *xpathParser) peek() xpathToken {
+	return p.toks[p.i]
+}
+
+func (p *xpathParser) next() xpathToken {
+	t := p.toks[p.i]
+	if t.kind != xtEOF {
+		p.i++
+	}
+	return t
+}
+
+func (p *xpathParser) isOperator(val string) bool {
+	t := p.peek()
+	return t.kind == xtOperator && t.val == val
+}
+
+func (p *xpathParser) isPunct(val string) bool {
+	t := p.peek()
+	return t.kind == xtPunct && t.val == val
+}
+
+func (p *xpathParser) fail(t xpathToken, format string, args ...interface{}) {
+	panic(xpathSyntaxError(p.src, t.pos, format, args...))
+}
+
+func (p *xpathParser) unexpected(expected string) {
+	t := p.peek()
+	if t.kind == xtEOF {
+		p.fail(t, "unexpected end of expression, expected %s", expected)
+	}
+	p.fail(t, "unexpected %q, expected %s", p.src[t.pos:p.toks[p.i+1].pos], expected)
+}
+
+func (p *xpathParser) expectPunct(val string) {
+	if !p.isPunct(val) {
+		p.unexpected("'" + val + "'")
+	}
+	p.next()
+}
+
+func (p *xpathParser) parse() (expr xpathExpr, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			xerr, ok := r.(*XPathError)
+			if !ok {
+				panic(r)
+			}
+			err = xerr
+		}
+	}()
+	expr = p.parseOr()
+	if p.peek().kind != xtEOF {
+		p.unexpected("an operator")
+	}
+	return expr, nil
+}
+
+func (p *xpathParser) parseBinary(operand func() xpathExpr, ops ...string) xpathExpr {
+	left := operand()
+	for {
+		t := p.peek()
+		if t.kind != xtOperator {
+			return left
+		}
+		found := false
+		for _, op := range ops {
+			if t.val == op {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return left
+		}
+		p.next()
+		left = &xpathBinary{t.val, left, operand()}
+	}
+}
+
+func (p *xpathParser) parseOr() xpathExpr {
+	return p.parseBinary(p.parseAnd, "or")
+}
+
+func (p *xpathParser) parseAnd() xpathExpr {
+	return p.parseBinary(p.parseEquality, "and")
+}
+
+func (p *xpathParser) parseEquality() xpathExpr {
+	return p.parseBinary(p.parseRelational, "=", "!=")
+}
+
+func (p *xpathParser) parseRelational() xpathExpr {
+	return p.parseBinary(p.parseAdditive, "<", "<=", ">", ">=")
+}
+
+func (p *xpathParser) parseAdditive() xpathExpr {
+	return p.parseBinary(p.parseMultiplicative, "+", "-")
+}
+
+func (p *xpathParser) parseMultiplicative() xpathExpr {
+	return p.parseBinary(p.parseUnary, "*", "div", "mod")
+}
+
+func (p *xpathParser) parseUnary() xpathExpr {
+	if p.isOperator("-") {
+		p.next()
+		return &xpathNegate{p.parseUnary()}
+	}
+	return p.parseUnion()
+}
+
+func (p *xpathParser) parseUnion() xpathExpr {
+	left := p.parsePath()
+	for p.isOperator("|") {
+		t := p.next()
+		left = &xpathUnion{left, p.parsePath(), t.pos}
+	}
+	return left
+}
+
+func startsStep(t xpathToken) bool {
+	switch t.kind {
+	case xtName, xtStar, xtNodeType, xtAxis:
+		return true
+	case xtPunct:
+		return t.val == "@" || t.val == "." || t.val == ".."
+	}
+	return false
+}
+
+var descendantOrSelfStep = &xpathStep{axis: "descendant-or-self", test: xpathNodeTest{kind: xpathAnyNode}}
+
+func (p *xpathParser) parsePath() xpathExpr {
+	t := p.peek()
+	switch {
+	case t.kind == xtOperator && (t.val == "/" || t.val == "//"):
+		p.next()
+		path := &xpathPath{absolute: true, pos: t.pos}
+		if t.val == "//" {
+			path.steps = append(path.steps, descendantOrSelfStep)
+			path.steps = append(path.steps, p.parseRelativePath()...)
+		} else if startsStep(p.peek()) {
+			path.steps = p.parseRelativePath()
+		}
+		return path
+	case t.kind == xtVariable || t.kind == xtLiteral || t.kind == xtNumber || t.kind == xtFunction ||
+		t.kind == xtPunct && t.val == "(":
+		var expr xpathExpr = p.parsePrimary()
+		if preds := p.parsePredicates(); len(preds) > 0 {
+			expr = &xpathFilter{expr, preds, t.pos}
+		}
+		if p.isOperator("/") || p.isOperator("//") {
+			return &xpathPath{filter: expr, steps: p.parseSteps(nil), pos: t.pos}
+		}
+		return expr
+	case startsStep(t):
+		return &xpathPath{steps: p.parseRelativePath(), pos: t.pos}
+	}
+	p.unexpected("an expression")
+	return nil
+}
+
+func (p *xpathParser) parseRelativePath() []*xpathStep {
+	return p.parseSteps([]*xpathStep{p.parseStep()})
+}
+
+// parseSteps parses any number of steps preceded by '/' or '//'.
+func (p *xpathParser) parseSteps(steps []*xpathStep) []*xpathStep {
+	for p.isOperator("/") || p.isOperator("//") {
+		if p.next().val == "//" {
+			steps = append(steps, descendantOrSelfStep)
+		}
+		steps = append(steps, p.parseStep())
+	}
+	return steps
+}
+
+func (p *xpathParser) parseStep() *xpathStep {
+	t := p.peek()
+	if p.isPunct(".") {
+		p.next()
+		return &xpathStep{axis: "self", test: xpathNodeTest{kind: xpathAnyNode}}
+	}
+	if p.isPunct("..") {
+		p.next()
+		return &xpathStep{axis: "parent", test: xpathNodeTest{kind: xpathAnyNode}}
+	}
+
+	step := &xpathStep{axis: "child"}
+	switch {
+	case t.kind == xtAxis:
+		if _, ok := xpathAxes[t.val]; !ok {
+			p.fail(t, "unknown axis %q", t.val)
+		}
+		step.axis = t.val
+		p.next()
+		p.expectPunct("::")
+	case p.isPunct("@"):
+		step.axis = "attribute"
+		p.next()
+	}
+
+	t = p.peek()
+	switch t.kind {
+	case xtStar:
+		p.next()
+		step.test = xpathNodeTest{kind: xpathPrincipal}
+	case xtName:
+		p.next()
+		step.test = xpathNodeTest{kind: xpathPrincipal, name: t.val}
+		if i := strings.IndexByte(t.val, ':'); i >= 0 {
+			step.test.prefix, step.test.name = t.val[:i], t.val[i+1:]
+			if step.test.name == "*" {
+				step.test.name = ""
+			}
+		}
+	case xtNodeType:
+		p.next()
+		p.expectPunct("(")
+		switch t.val {
+		case "node":
+			step.test.kind = xpathAnyNode
+		case "text":
+			step.test.kind = xpathTextNode
+		case "comment":
+			step.test.kind = xpathCommentNode
+		case "processing-instruction":
+			step.test.kind = xpathPINode
+			if p.peek().kind == xtLiteral {
+				p.next()
+			}
+		}
+		p.expectPunct(")")
+	default:
+		p.unexpected("a node test")
+	}
+	step.preds = p.parsePredicates()
+	return step
+}
+
+func (p *xpathParser) parsePredicates() []xpathExpr {
+	var preds []xpathExpr
+	for p.isPunct("[") {
+		p.next()
+		preds = append(preds, p.parseOr())
+		p.expectPunct("]")
+	}
+	return preds
+}
+
+func (p *xpathParser) parsePrimary() xpathExpr {
+	t := p.next()
+	switch t.kind {
+	case xtVariable:
+		p.fail(t, "variable references are not supported")
+	case xtLiteral:
+		return xpathLiteral{t.val}
+	case xtNumber:
+		return xpathLiteral{t.num}
+	case xtFunction:
+		fn, ok := xpathFunctions[t.val]
+		if !ok {
+			p.fail(t, "unknown function %s()", t.val)
+		}
+		p.expectPunct("(")
+		var args []xpathExpr
+		if !p.isPunct(")") {
+			args = append(args, p.parseOr())
+			for p.isPunct(",") {
+				p.next()
+				args = append(args, p.parseOr())
+			}
+		}
+		p.expectPunct(")")
+		if len(args) < fn.minArgs || fn.maxArgs >= 0 && len(args) > fn.maxArgs {
+			p.fail(t, "wrong number of arguments for %s(): %d", t.val, len(args))
+		}
+		return &xpathCall{t.val, fn, args, t.pos}
+	}
+	// only '(' is left
+	expr := p.parseOr()
+	p.expectPunct(")")
+	return expr
+}
+
+//
+// Evaluation
+//
+
+// xpathEvaluator holds the state shared by all the contexts of an evaluation.
+type xpathEvaluator struct {
+	order map[*Node]int // document order of the nodes, built lazily
+}
+
+type xpathContext struct {
+	node XPathNode
+	pos  int
+	size int
+	ev   *xpathEvaluator
+}
+
+// xpathExpr is a node of the AST of an XPath expression. Evaluating it returns a
+// node-set ([]XPathNode in document order), a bool, a float64 or a string.
+type xpathExpr interface {
+	eval(c *xpathContext) interface{}
+}
+
+func (c *xpathContext) evalNodeSet(e xpathExpr, pos int) []XPathNode {
+	nodes, ok := e.eval(c).([]XPathNode)
+	if !ok {
+		panic(&XPathError{Offset: pos, Msg: "expression does not evaluate to a node-set"})
+	}
+	return nodes
+}
+
+// orderKey returns a key giving the position of the node in document order.
+// Attributes come after their owner element and before its children.
+func (ev *xpathEvaluator) orderKey(n XPathNode) (int, int) {
+	return ev.order[n.Node], n.attr
+}
+
+func (ev *xpathEvaluator) buildOrder(n *Node) {
+	if ev.order != nil {
+		return
+	}
+	ev.order = make(map[*Node]int)
+	root := n.Root()
+	i := 0
+	for cur := root; cur != nil; cur = nextInDocumentOrder(cur, root) {
+		ev.order[cur] = i
+		i++
+	}
+}
+
+// sortUnique sorts the given nodes in document order and removes duplicates.
+func (ev *xpathEvaluator) sortUnique(nodes []XPathNode) []XPathNode {
+	if len(nodes) < 2 {
+		return nodes
+	}
+	ev.buildOrder(nodes[0].Node)
+	sort.SliceStable(nodes, func(i, j int) bool {
+		i1, i2 := ev.orderKey(nodes[i])
+		j1, j2 := ev.orderKey(nodes[j])
+		return i1 < j1 || i1 == j1 && i2 < j2
+	})
+	unique := nodes[:1]
+	for _, n := range nodes[1:] {
+		if n != unique[len(unique)-1] {
+			unique = append(unique, n)
+		}
+	}
+	return unique
+}
+
+type xpathLiteral struct {
+	value interface{}
+}
+
+func (e xpathLiteral) eval(*xpathContext) interface{} {
+	return e.value
+}
+
+type xpathNegate struct {
+	operand xpathExpr
+}
+
+func (e *xpathNegate) eval(c *xpathContext) interface{} {
+	return -xpathNumber(e.operand.eval(c))
+}
+
+type xpathBinary struct {
+	op          string
+	left, right xpathExpr
+}
+
+func (e *xpathBinary) eval(c *xpathContext) interface{} {
+	switch e.op {
+	case "or":
+		return xpathBool(e.left.eval(c)) || xpathBool(e.right.eval(c))
+	case "and":
+		return xpathBool(e.left.eval(c)) && xpathBool(e.right.eval(c))
+	case "=", "!=", "<", "<=", ">", ">=":
+		return xpathCompare(e.op, e.left.eval(c), e.right.eval(c))
+	}
+	l, r := xpathNumber(e.left.eval(c)), xpathNumber(e.right.eval(c))
+	switch e.op {
+	case "+":
+		return l + r
+	case "-":
+		return l - r
+	case "*":
+		return l * r
+	case "div":
+		return l / r
+	default: // mod
+		return math.Mod(l, r)
+	}
+}
+
+type xpathUnion struct {
+	left, right xpathExpr
+	pos         int
+}
+
+func (e *xpathUnion) eval(c *xpathContext) interface{} {
+	l := c.evalNodeSet(e.left, e.pos)
+	r := c.evalNodeSet(e.right, e.pos)
+	all := make([]XPathNode, 0, len(l)+len(r))
+	return c.ev.sortUnique(append(append(all, l...), r...))
+}
+
+type xpathFilter struct {
+	primary xpathExpr
+	preds   []xpathExpr
+	pos     int
+}
+
+func (e *xpathFilter) eval(c *xpathContext) interface{} {
+	nodes := c.evalNodeSet(e.primary, e.pos)
+	for _, pred := range e.preds {
+		nodes = c.ev.applyPredicate(nodes, pred)
+	}
+	return nodes
+}
+
+// applyPredicate keeps the nodes for which the predicate is true. The nodes must
+// be given in the proximity order of the axis they come from.
+func (ev *xpathEvaluator) applyPredicate(nodes []XPathNode, pred xpathExpr) []XPathNode {
+	var kept []XPathNode
+	for i, n := range nodes {
+		v := pred.eval(&xpathContext{n, i + 1, len(nodes), ev})
+		if num, ok := v.(float64); ok {
+			if num == float64(i+1) {
+				kept = append(kept, n)
+			}
+		} else if xpathBool(v) {
+			kept = append(kept, n)
+		}
+	}
+	return kept
+}
+
+type xpathPath struct {
+	filter   xpathExpr // the filter expression the path starts from, if any
+	absolute bool
+	steps    []*xpathStep
+	pos      int
+}
+
+func (e *xpathPath) eval(c *xpathContext) interface{} {
+	var nodes []XPathNode
+	switch {
+	case e.filter != nil:
+		nodes = c.evalNodeSet(e.filter, e.pos)
+	case e.absolute:
+		nodes = []XPathNode{{Node: c.node.Node.Root()}}
+	default:
+		nodes = []XPathNode{c.node}
+	}
+	for _, step := range e.steps {
+		nodes = step.apply(c.ev, nodes)
+	}
+	return nodes
+}
+
+type xpathStep struct {
+	axis  string
+	test  xpathNodeTest
+	preds []xpathExpr
+}
+
+func (s *xpathStep) apply(ev *xpathEvaluator, input []XPathNode) []XPathNode {
+	axis := xpathAxes[s.axis]
+	var result []XPathNode
+	for _, n := range input {
+		var selected []XPathNode
+		axis.walk(n, func(candidate XPathNode) {
+			if s.test.matches(candidate, axis.attribute) {
+				selected = append(selected, candidate)
+			}
+		})
+		for _, pred := range s.preds {
+			selected = ev.applyPredicate(selected, pred)
+		}
+		result = append(result, selected...)
+	}
+	if len(input) > 1 || axis.reverse {
+		result = ev.sortUnique(result)
+	}
+	return result
+}
+
+type xpathNodeTestKind int
+
+const (
+	xpathPrincipal xpathNodeTestKind = iota // * or a name test
+	xpathAnyNode
+	xpathTextNode
+	xpathCommentNode
+	xpathPINode
+)
+
+type xpathNodeTest struct {
+	kind   xpathNodeTestKind
+	prefix string // namespace prefix of a name test
+	name   string // local name of a name test, empty for *
+}
+
+func (t *xpathNodeTest) matches(n XPathNode, attributeAxis bool) bool {
+	switch t.kind {
+	case xpathAnyNode:
+		return true
+	case xpathTextNode:
+		return !n.IsAttr() && n.Node.Type == TextNode
+	case xpathCommentNode:
+		return !n.IsAttr() && n.Node.Type == CommentNode
+	case xpathPINode:
+		return false
+	}
+	if n.IsAttr() != attributeAxis {
+		return false
+	}
+	if n.IsAttr() {
+		a := n.Attr
+		return (t.name == "" || a.Key == t.name) && (t.prefix == "" && t.name == "" || a.Namespace == t.prefix)
+	}
+	return n.Node.Type == ElementNode &&
+		(t.name == "" || n.Node.Data == t.name) && (t.prefix == "" && t.name == "" || n.Node.Namespace == t.prefix)
+}
+
+type xpathAxis struct {
+	attribute bool // the principal node type is attribute rather than element
+	reverse   bool
+	walk      func(n XPathNode, yield func(XPathNode))
+}
+
+// inXPathModel returns true if the node is visible in the XPath data model.
+func inXPathModel(n *Node) bool {
+	return n.Type != DoctypeNode && n.Type != ErrorNode
+}
+
+func walkChildren(n *Node, yield func(*Node)) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if inXPathModel(c) {
+			yield(c)
+		}
+	}
+}
+
+func walkDescendants(n *Node, yield func(*Node)) {
+	for cur := n.FirstChild; cur != nil; cur = nextInDocumentOrder(cur, n) {
+		if inXPathModel(cur) {
+			yield(cur)
+		}
+	}
+}
+
+// walkReverseDescendants yields the descendants of n in reverse document order.
+func walkReverseDescendants(n *Node, yield func(*Node)) {
+	for cur := lastDescendant(n); cur != n; cur = prevInDocumentOrder(cur, n) {
+		if inXPathModel(cur) {
+			yield(cur)
+		}
+	}
+}
+
+// yieldTree adapts yield to the functions walking the nodes of the tree.
+func yieldTree(yield func(XPathNode)) func(*Node) {
+	return func(n *Node) {
+		yield(XPathNode{Node: n})
+	}
+}
+
+var xpathAxes = map[string]*xpathAxis{
+	"child": {false, false, func(n XPathNode, yield func(XPathNode)) {
+		if !n.IsAttr() {
+			walkChildren(n.Node, yieldTree(yield))
+		}
+	}},
+	"descendant": {false, false, func(n XPathNode, yield func(XPathNode)) {
+		if !n.IsAttr() {
+			walkDescendants(n.Node, yieldTree(yield))
+		}
+	}},
+	"descendant-or-self": {false, false, func(n XPathNode, yield func(XPathNode)) {
+		yield(n)
+		if !n.IsAttr() {
+			walkDescendants(n.Node, yieldTree(yield))
+		}
+	}},
+	"self": {false, false, func(n XPathNode, yield func(XPathNode)) {
+		yield(n)
+	}},
+	"parent": {false, true, func(n XPathNode, yield func(XPathNode)) {
+		// the parent of an attribute is its owner element
+		if n.IsAttr() {
+			yield(XPathNode{Node: n.Node})
+		} else if n.Node.Parent != nil {
+			yield(XPathNode{Node: n.Node.Parent})
+		}
+	}},
+	"ancestor": {false, true, func(n XPathNode, yield func(XPathNode)) {
+		p := n.Node.Parent
+		if n.IsAttr() {
+			p = n.Node
+		}
+		for ; p != nil; p = p.Parent {
+			yield(XPathNode{Node: p})
+		}
+	}},
+	"ancestor-or-self": {false, true, func(n XPathNode, yield func(XPathNode)) {
+		if n.IsAttr() {
+			yield(n)
+		}
+		for p := n.Node; p != nil; p = p.Parent {
+			yield(XPathNode{Node: p})
+		}
+	}},
+	"following-sibling": {false, false, func(n XPathNode, yield func(XPathNode)) {
+		if n.IsAttr() {
+			return
+		}
+		for s := n.Node.NextSibling; s != nil; s = s.NextSibling {
+			if inXPathModel(s) {
+				yield(XPathNode{Node: s})
+			}
+		}
+	}},
+	"preceding-sibling": {false, true, func(n XPathNode, yield func(XPathNode)) {
+		if n.IsAttr() {
+			return
+		}
+		for s := n.Node.PrevSibling; s != nil; s = s.PrevSibling {
+			if inXPathModel(s) {
+				yield(XPathNode{Node: s})
+			}
+		}
+	}},
+	"following": {false, false, func(n XPathNode, yield func(XPathNode)) {
+		tree := yieldTree(yield)
+		if n.IsAttr() {
+			// the descendants of the owner element follow its attributes
+			walkDescendants(n.Node, tree)
+		}
+		for p := n.Node; p != nil; p = p.Parent {
+			for s := p.NextSibling; s != nil; s = s.NextSibling {
+				if inXPathModel(s) {
+					tree(s)
+					walkDescendants(s, tree)
+				}
+			}
+		}
+	}},
+	"preceding": {false, true, func(n XPathNode, yield func(XPathNode)) {
+		tree := yieldTree(yield)
+		for p := n.Node; p != nil; p = p.Parent {
+			for s := p.PrevSibling; s != nil; s = s.PrevSibling {
+				if inXPathModel(s) {
+					walkReverseDescendants(s, tree)
+					tree(s)
+				}
+			}
+		}
+	}},
+	"attribute": {true, false, func(n XPathNode, yield func(XPathNode)) {
+		if n.IsAttr() || n.Node.Type != ElementNode {
+			return
+		}
+		for i, a := range n.Node.Attrs {
+			yield(XPathNode{n.Node, a, i + 1})
+		}
+	}},
+	"namespace": {true, false, func(n XPathNode, yield func(XPathNode)) {
+		// namespace nodes are not part of gosoup trees
+	}},
+}
+
+//
+// Conversions and comparisons
+//
+
+// xpathStringValue returns the string-value of a node, as defined by the XPath
+// data model.
+func xpathStringValue(n XPathNode) string {
+	if n.IsAttr() {
+		return n.Attr.Val
+	}
+	switch n.Node.Type {
+	case TextNode, CommentNode:
+		return n.Node.Data
+	case ElementNode, DocumentNode:
+		var b strings.Builder
+		walkDescendants(n.Node, func(d *Node) {
+			if d.Type == TextNode {
+				b.WriteString(d.Data)
+			}
+		})
+		return b.String()
+	}
+	return ""
+}
+
+func xpathBool(v interface{}) bool {
+	switch v := v.(type) {
+	case bool:
+		return v
+	case float64:
+		return v != 0 && !math.IsNaN(v)
+	case string:
+		return v != ""
+	case []XPathNode:
+		return len(v) > 0
+	}
+	return false
+}
+
+func xpathNumber(v interface{}) float64 {
+	switch v := v.(type) {
+	case bool:
+		if v {
+			return 1
+		}
+		return 0
+	case float64:
+		return v
+	case string:
+		return xpathParseNumber(v)
+	case []XPathNode:
+		return xpathParseNumber(xpathString(v))
+	}
+	return math.NaN()
+}
+
+func xpathString(v interface{}) string {
+	switch v := v.(type) {
+	case bool:
+		if v {
+			return "true"
+		}
+		return "false"
+	case float64:
+		return xpathFormatNumber(v)
+	case string:
+		return v
+	case []XPathNode:
+		if len(v) == 0 {
+			return ""
+		}
+		return xpathStringValue(v[0])
+	}
+	return ""
+}
+
+// xpathParseNumber parses a string following the XPath Number production,
+// surrounded by optional whitespace. It returns NaN for any other string.
+func xpathParseNumber(s string) float64 {
+	s = strings.Trim(s, " \t\r\n")
+	digits := strings.TrimPrefix(s, "-")
+	if digits == "" || digits == "." {
+		return math.NaN()
+	}
+	dot := false
+	for i := 0; i < len(digits); i++ {
+		switch c := digits[i]; {
+		case c == '.' && !dot:
+			dot = true
+		case !isDigit(c):
+			return math.NaN()
+		}
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return math.NaN()
+	}
+	return f
+}
+
+func xpathFormatNumber(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	case f == 0:
+		return "0"
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func xpathCompare(op string, left, right interface{}) bool {
+	lnodes, lok := left.([]XPathNode)
+	rnodes, rok := right.([]XPathNode)
+	switch {
+	case lok && rok:
+		for _, l := range lnodes {
+			lv := xpathStringValue(l)
+			for _, r := range rnodes {
+				if xpathCompareAtoms(op, lv, xpathStringValue(r)) {
+					return true
+				}
+			}
+		}
+		return false
+	case lok:
+		if b, ok := right.(bool); ok {
+			return xpathCompareAtoms(op, len(lnodes) > 0, b)
+		}
+		for _, l := range lnodes {
+			if xpathCompareAtoms(op, xpathStringValue(l), right) {
+				return true
+			}
+		}
+		return false
+	case rok:
+		if b, ok := left.(bool); ok {
+			return xpathCompareAtoms(op, b, len(rnodes) > 0)
+		}
+		for _, r := range rnodes {
+			if xpathCompareAtoms(op, left, xpathStringValue(r)) {
+				return true
+			}
+		}
+		return false
+	}
+	return xpathCompareAtoms(op, left, right)
+}
+
+// xpathCompareAtoms compares two values that are not node-sets.
+func xpathCompareAtoms(op string, left, right interface{}) bool {
+	if op == "=" || op == "!=" {
+		var equal bool
+		_, lbool := left.(bool)
+		_, rbool := right.(bool)
+		_, lnum := left.(float64)
+		_, rnum := right.(float64)
+		switch {
+		case lbool || rbool:
+			equal = xpathBool(left) == xpathBool(right)
+		case lnum || rnum:
+			equal = xpathNumber(left) == xpathNumber(right)
+		default:
+			equal = xpathString(left) == xpathString(right)
+		}
+		return equal == (op == "=")
+	}
+	l, r := xpathNumber(left), xpathNumber(right)
+	switch op {
+	case "<":
+		return l < r
+	case "<=":
+		return l <= r
+	case ">":
+		return l > r
+	default: // >=
+		return l >= r
+	}
+}
+
+//
+// Core function library
+//
+
+type xpathFunction struct {
+	minArgs, maxArgs int // maxArgs is -1 for variadic functions
+	call             func(c *xpathContext, e *xpathCall) interface{}
+}
+
+type xpathCall struct {
+	name string
+	fn   *xpathFunction
+	args []xpathExpr
+	pos  int
+}
+
+func (e *xpathCall) eval(c *xpathContext) interface{} {
+	return e.fn.call(c, e)
+}
+
+func (e *xpathCall) str(c *xpathContext, i int) string {
+	return xpathString(e.args[i].eval(c))
+}
+
+func (e *xpathCall) num(c *xpathContext, i int) float64 {
+	return xpathNumber(e.args[i].eval(c))
+}
+
+// strOrContext returns the string value of the first argument, or of the context
+// node if there is no argument.
+func (e *xpathCall) strOrContext(c *xpathContext) string {
+	if len(e.args) == 0 {
+		return xpathStringValue(c.node)
+	}
+	return e.str(c, 0)
+}
+
+// nodeOrContext returns the first node of the node-set passed as first argument,
+// or the context node if there is no argument. It returns nil for an empty set.
+func (e *xpathCall) nodeOrContext(c *xpathContext) (XPathNode, bool) {
+	if len(e.args) == 0 {
+		return c.node, true
+	}
+	nodes := c.evalNodeSet(e.args[0], e.pos)
+	if len(nodes) == 0 {
+		return XPathNode{}, false
+	}
+	return nodes[0], true
+}
+
+func xpathRound(f float64) float64 {
+	if math.IsNaN(f) || math.IsInf(f, 0) || f == 0 {
+		return f
+	}
+	if f < 0 && f >= -0.5 {
+		return math.Copysign(0, -1)
+	}
+	return math.Floor(f + 0.5)
+}
+
+var xpathNamespaceURIs = map[string]string{
+	"svg":   "http://www.w3.org/2000/svg",
+	"math":  "http://www.w3.org/1998/Math/MathML",
+	"xlink": "http://www.w3.org/1999/xlink",
+	"xml":   "http://www.w3.org/XML/1998/namespace",
+	"xmlns": "http://www.w3.org/2000/xmlns/",
+}
+
+func xpathLocalName(n XPathNode) string {
+	if n.IsAttr() {
+		return n.Attr.Key
+	}
+	if n.Node.Type == ElementNode {
+		return n.Node.Data
+	}
+	return ""
+}
+
+var xpathFunctions = map[string]*xpathFunction{
+	// node-set functions
+	"last": {0, 0, func(c *xpathContext, e *xpathCall) interface{} {
+		return float64(c.size)
+	}},
+	"position": {0, 0, func(c *xpathContext, e *xpathCall) interface{} {
+		return float64(c.pos)
+	}},
+	"count": {1, 1, func(c *xpathContext, e *xpathCall) interface{} {
+		return float64(len(c.evalNodeSet(e.args[0], e.pos)))
+	}},
+	"id": {1, 1, func(c *xpathContext, e *xpathCall) interface{} {
+		var ids []string
+		if nodes, ok := e.args[0].eval(c).([]XPathNode); ok {
+			for _, n := range nodes {
+				ids = append(ids, xpathFields(xpathStringValue(n))...)
+			}
+		} else {
+			ids = xpathFields(e.str(c, 0))
+		}
+		var found []XPathNode
+		walkDescendants(c.node.Node.Root(), func(n *Node) {
+			if n.Type != ElementNode || !n.HasAttr("id") {
+				return
+			}
+			for _, id := range ids {
+				if n.Attr("id") == id {
+					found = append(found, XPathNode{Node: n})
+					return
+				}
+			}
+		})
+		return found
+	}},
+	"local-name": {0, 1, func(c *xpathContext, e *xpathCall) interface{} {
+		if n, ok := e.nodeOrContext(c); ok {
+			return xpathLocalName(n)
+		}
+		return ""
+	}},
+	"namespace-uri": {0, 1, func(c *xpathContext, e *xpathCall) interface{} {
+		n, ok := e.nodeOrContext(c)
+		switch {
+		case !ok:
+			return ""
+		case n.IsAttr():
+			return xpathNamespaceURIs[n.Attr.Namespace]
+		case n.Node.Type == ElementNode:
+			return xpathNamespaceURIs[n.Node.Namespace]
+		}
+		return ""
+	}},
+	"name": {0, 1, func(c *xpathContext, e *xpathCall) interface{} {
+		n, ok := e.nodeOrContext(c)
+		if !ok {
+			return ""
+		}
+		name := xpathLocalName(n)
+		if n.IsAttr() && n.Attr.Namespace != "" {
+			name = n.Attr.Namespace + ":" + name
+		}
+		return name
+	}},
+
+	// string functions
+	"string": {0, 1, func(c *xpathContext, e *xpathCall) interface{} {
+		return e.strOrContext(c)
+	}},
+	"concat": {2, -1, func(c *xpathContext, e *xpathCall) interface{} {
+		var b strings.Builder
+		for i := range e.args {
+			b.WriteString(e.str(c, i))
+		}
+		return b.String()
+	}},
+	"starts-with": {2, 2, func(c *xpathContext, e *xpathCall) interface{} {
+		return strings.HasPrefix(e.str(c, 0), e.str(c, 1))
+	}},
+	"contains": {2, 2, func(c *xpathContext, e *xpathCall) interface{} {
+		return strings.Contains(e.str(c, 0), e.str(c, 1))
+	}},
+	"substring-before": {2, 2, func(c *xpathContext, e *xpathCall) interface{} {
+		s, sep := e.str(c, 0), e.str(c, 1)
+		if i := strings.Index(s, sep); i >= 0 {
+			return s[:i]
+		}
+		return ""
+	}},
+	"substring-after": {2, 2, func(c *xpathContext, e *xpathCall) interface{} {
+		s, sep := e.str(c, 0), e.str(c, 1)
+		if i := strings.Index(s, sep); i >= 0 {
+			return s[i+len(sep):]
+		}
+		return ""
+	}},
+	"substring": {2, 3, func(c *xpathContext, e *xpathCall) interface{} {
+		runes := []rune(e.str(c, 0))
+		start := xpathRound(e.num(c, 1))
+		end := math.Inf(1)
+		if len(e.args) == 3 {
+			end = start + xpathRound(e.num(c, 2))
+		}
+		var b strings.Builder
+		for i, r := range runes {
+			if p := float64(i + 1); p >= start && p < end {
+				b.WriteRune(r)
+			}
+		}
+		return b.String()
+	}},
+	"string-length": {0, 1, func(c *xpathContext, e *xpathCall) interface{} {
+		return float64(utf8.RuneCountInString(e.strOrContext(c)))
+	}},
+	"normalize-space": {0, 1, func(c *xpathContext, e *xpathCall) interface{} {
+		return strings.Join(xpathFields(e.strOrContext(c)), " ")
+	}},
+	"translate": {3, 3, func(c *xpathContext, e *xpathCall) interface{} {
+		from, to := []rune(e.str(c, 1)), []rune(e.str(c, 2))
+		mapping := make(map[rune]rune, len(from))
+		for i, r := range from {
+			if _, ok := mapping[r]; ok {
+				continue
+			}
+			if i < len(to) {
+				mapping[r] = to[i]
+			} else {
+				mapping[r] = -1
+			}
+		}
+		return strings.Map(func(r rune) rune {
+			if m, ok := mapping[r]; ok {
+				return m
+			}
+			return r
+		}, e.str(c, 0))
+	}},
+
+	// boolean functions
+	"boolean": {1, 1, func(c *xpathContext, e *xpathCall) interface{} {
+		return xpathBool(e.args[0].eval(c))
+	}},
+	"not": {1, 1, func(c *xpathContext, e *xpathCall) interface{} {
+		return !xpathBool(e.args[0].eval(c))
+	}},
+	"true": {0, 0, func(c *xpathContext, e *xpathCall) interface{} {
+		return true
+	}},
+	"false": {0, 0, func(c *xpathContext, e *xpathCall) interface{} {
+		return false
+	}},
+	"lang": {1, 1, func(c *xpathContext, e *xpathCall) interface{} {
+		want := strings.ToLower(e.str(c, 0))
+		// the language of an attribute is the one of its owner element
+		for n := c.node.Node; n != nil; n = n.Parent {
+			if n.Type != ElementNode {
+				continue
+			}
+			for _, a := range n.Attrs {
+				if a.Key == "lang" && (a.Namespace == "" || a.Namespace == "xml") {
+					lang := strings.ToLower(a.Val)
+					return lang == want || strings.HasPrefix(lang, want+"-")
+				}
+			}
+		}
+		return false
+	}},
+
+	// number functions
+	"number": {0, 1, func(c *xpathContext, e *xpathCall) interface{} {
+		if len(e.args) == 0 {
+			return xpathParseNumber(xpathStringValue(c.node))
+		}
+		return e.num(c, 0)
+	}},
+	"sum": {1, 1, func(c *xpathContext, e *xpathCall) interface{} {
+		sum := 0.0
+		for _, n := range c.evalNodeSet(e.args[0], e.pos) {
+			sum += xpathParseNumber(xpathStringValue(n))
+		}
+		return sum
+	}},
+	"floor": {1, 1, func(c *xpathContext, e *xpathCall) interface{} {
+		return math.Floor(e.num(c, 0))
+	}},
+	"ceiling": {1, 1, func(c *xpathContext, e *xpathCall) interface{} {
+		return math.Ceil(e.num(c, 0))
+	}},
+	"round": {1, 1, func(c *xpathContext, e *xpathCall) interface{} {
+		return xpathRound(e.num(c, 0))
+	}},
+}
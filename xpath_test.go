@@ -0,0 +1,229 @@
+package gosoup
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+func assertXPath(t *testing.T, context *Node, expr string, ids ...string) {
+	res, err := context.EvaluateXPath(expr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert(t, res.Type() == XPathNodeSet, "xpath '", expr, "': expected a node-set")
+	var got []string
+	for _, n := range res.NodeSet() {
+		switch {
+		case n.IsAttr():
+			got = append(got, n.Attr.Val)
+		case n.Node.Type == ElementNode:
+			got = append(got, n.Node.AttrOrDefault("id", n.Node.Data))
+		default:
+			got = append(got, n.Node.Data)
+		}
+	}
+	assert(t, strings.Join(got, "|") == strings.Join(ids, "|"),
+		"xpath '", expr, "': expected ", ids, ", got ", got)
+}
+
+func evalXPath(t *testing.T, context *Node, expr string) XPathResult {
+	res, err := context.EvaluateXPath(expr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func TestXPathLocationPaths(t *testing.T) {
+	doc, err := Parse(strings.NewReader(HTML_SELECTORS))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertXPath(t, doc, "/html/head/title", "title")
+	assertXPath(t, doc, "//div[@class='article']/p", "p4")
+	assertXPath(t, doc, "//div[contains(@class, 'article')]/p[2]", "p2")
+	assertXPath(t, doc, "//p[@id='p1']/a/text()", "secure")
+	assertXPath(t, doc, "//a/@href", "https://example.com", "http://example.com")
+	assertXPath(t, doc, "//li[last()]", "l5")
+	assertXPath(t, doc, "//li[position() > 3]", "l4", "l5")
+	assertXPath(t, doc, "(//p)[3]", "p3")
+	assertXPath(t, doc, "//ul/li[@class][2]", "l5")
+	assertXPath(t, doc, "//h2 | //span | //h2", "s1", "h1")
+	assertXPath(t, doc, "//*[@id='l3']/..", "u1")
+	assertXPath(t, doc, "//body/*", "d1", "d2", "f1")
+	assertXPath(t, doc, "id('p2 l1')", "p2", "l1")
+	assertXPath(t, doc, "//html/@lang", "en-US")
+	assertXPath(t, doc, "//comment()")
+
+	// unprefixed name tests only match elements without namespace
+	svg, err := Parse(strings.NewReader(`<svg id="s"><rect id="r"/></svg>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertXPath(t, svg, "//svg")
+	assertXPath(t, svg, "//svg:svg/svg:rect", "r")
+}
+
+func TestXPathAxes(t *testing.T) {
+	doc, err := Parse(strings.NewReader(HTML_SELECTORS))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l3, err := doc.XPathFirst("//li[@id='l3']")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertXPath(t, l3, "ancestor::*", "html", "body", "d2", "u1")
+	assertXPath(t, l3, "ancestor::*[1]", "u1")
+	assertXPath(t, l3, "ancestor-or-self::li", "l3")
+	assertXPath(t, l3, "following-sibling::li", "l4", "l5")
+	assertXPath(t, l3, "following-sibling::li[1]", "l4")
+	assertXPath(t, l3, "preceding-sibling::li", "l1", "l2")
+	assertXPath(t, l3, "preceding-sibling::li[1]", "l2")
+	assertXPath(t, l3, "following::input", "in1", "in2")
+	assertXPath(t, l3, "preceding::p", "p1", "p2", "p3", "p4")
+	assertXPath(t, l3, "preceding::p[1]", "p4")
+	assertXPath(t, l3, "self::li", "l3")
+	assertXPath(t, l3, "self::p")
+	assertXPath(t, l3, "parent::ul/child::li[1]", "l1")
+	assertXPath(t, l3, "/descendant::h2", "h1")
+	assertXPath(t, l3, "attribute::*", "l3", "x")
+	assertXPath(t, l3, "@id/parent::*", "l3")
+	assertXPath(t, l3, "@id/following::li[1]", "l4")
+	assertXPath(t, l3, "namespace::*")
+	assertXPath(t, l3, "@id/ancestor-or-self::*[1]", "l3")
+	assertXPath(t, l3, "@id/self::node()", "l3")
+	assertXPath(t, l3, "@id/child::node()")
+}
+
+func TestXPathAttributes(t *testing.T) {
+	doc, err := Parse(strings.NewReader(HTML_SELECTORS))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := evalXPath(t, doc, "//li[@id='l3']/@* | //li[@id='l3']")
+	nodes := res.NodeSet()
+	assertEquals(t, 3, len(nodes))
+	l3 := byID(doc, "l3")
+	assert(t, !nodes[0].IsAttr() && nodes[0].Node == l3, "expected the element before its attributes")
+	for i, a := range l3.Attrs {
+		assert(t, nodes[i+1].IsAttr() && nodes[i+1].Node == l3, "expected an attribute of the element")
+		assertEquals(t, a, nodes[i+1].Attr)
+	}
+	assertEquals(t, "l3", res.Nodes().First().Attr("id"))
+	assertEquals(t, 1, len(res.Nodes().All()))
+
+	// attributes are not nodes of the tree
+	_, err = doc.XPath("//li/@id")
+	var xerr *XPathError
+	assert(t, errors.As(err, &xerr), "expected an XPath error, got ", err)
+	_, err = doc.XPathFirst("//li[@id] | //li/@id")
+	assert(t, errors.As(err, &xerr), "expected an XPath error, got ", err)
+}
+
+func TestXPathScalars(t *testing.T) {
+	doc, err := Parse(strings.NewReader(HTML_SELECTORS))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := evalXPath(t, doc, "count(//li)")
+	assert(t, res.Type() == XPathNumber && res.Number() == 5, "expected 5, got ", res)
+	res = evalXPath(t, doc, "sum(//li) div 3")
+	assert(t, res.Number() == 5, "expected 5, got ", res)
+	res = evalXPath(t, doc, "string(//h2)")
+	assert(t, res.Type() == XPathString && res.String() == "Title", "expected Title, got ", res)
+	res = evalXPath(t, doc, "//p[@id='p3']/@title = 'Hello World'")
+	assert(t, res.Type() == XPathBoolean && res.Bool(), "expected true, got ", res)
+	res = evalXPath(t, doc, "//li > 4")
+	assert(t, res.Bool(), "expected true, got ", res)
+	res = evalXPath(t, doc, "//li > 5")
+	assert(t, !res.Bool(), "expected false, got ", res)
+	res = evalXPath(t, doc, "//li")
+	assert(t, res.Type() == XPathNodeSet && res.String() == "1" && res.Number() == 1, "expected 1, got ", res)
+
+	cases := map[string]string{
+		"concat('a', 'b', 1 + 1)":                 "ab2",
+		"substring('12345', 1.5, 2.6)":            "234",
+		"substring('12345', 0, 3)":                "12",
+		"substring('12345', 0 div 0, 3)":          "",
+		"substring-before('1999/04/01', '/')":     "1999",
+		"substring-after('1999/04/01', '/')":      "04/01",
+		"normalize-space('  a \n b  ')":           "a b",
+		"normalize-space(' a\u00a0 b ')":          "a\u00a0 b",
+		"translate('--aaa--', 'abc-', 'ABC')":     "AAA",
+		"string-length('héllo')":                  "5",
+		"10 mod 3":                                "1",
+		"-3 * 2":                                  "-6",
+		"7 div 2":                                 "3.5",
+		"1 div 0":                                 "Infinity",
+		"round(-2.5)":                             "-2",
+		"round(2.5)":                              "3",
+		"floor(2.7) + ceiling(2.1)":               "5",
+		"number('  12.5 ')":                       "12.5",
+		"number('1e3')":                           "NaN",
+		"true() and not(false())":                 "true",
+		"starts-with('gosoup', 'go')":             "true",
+		"1 = '1.0'":                               "true",
+		"'a' != 'a' or 2 < 1":                     "false",
+		"local-name(//p[1]/@id)":                  "id",
+		"name(//ul)":                              "ul",
+		"normalize-space(//p[@id='p1'])":          "First secure",
+		"boolean(//p[@id='p4'][lang('en')])":      "true",
+		"boolean(//p[@id='p4'][lang('fr')])":      "false",
+		"count(//a[starts-with(@href, 'https')])": "1",
+	}
+	for expr, expected := range cases {
+		res := evalXPath(t, doc, expr)
+		assert(t, res.String() == expected, "xpath '", expr, "': expected ", expected, ", got ", res.String())
+	}
+	assert(t, math.IsNaN(evalXPath(t, doc, "number('x')").Number()), "expected NaN")
+}
+
+func TestXPathErrors(t *testing.T) {
+	cases := []struct {
+		expr   string
+		offset int
+	}{
+		{"", 0},
+		{"//", 2},
+		{"//div[", 6},
+		{"//div[@class='x'", 16},
+		{"foo::div", 0},
+		{"//div/unknown()", 6},
+		{"count()", 0},
+		{"'unterminated", 0},
+		{"//div !", 6},
+		{"$var", 0},
+		{"//p)", 3},
+		{"1 2", 2},
+	}
+	for _, c := range cases {
+		_, err := CompileXPath(c.expr)
+		xerr, ok := err.(*XPathError)
+		assert(t, ok, "expected an XPathError for '", c.expr, "', got ", err)
+		assert(t, xerr.Offset == c.offset,
+			"xpath '", c.expr, "': expected error at offset ", c.offset, ", got ", xerr)
+	}
+
+	doc, err := Parse(strings.NewReader(HTML_SELECTORS))
+	if err != nil {
+		t.Fatal(err)
+	}
+	it, err := doc.XPath("count(//p)")
+	assert(t, err != nil, "expected an error for a non node-set result")
+	assertEquals(t, 0, len(it.All()))
+	it, err = doc.XPath("//div[")
+	assert(t, err != nil, "expected an error for an invalid expression")
+	assertEquals(t, 0, len(it.All()))
+	_, err = doc.EvaluateXPath("count('x')")
+	xerr, ok := err.(*XPathError)
+	assert(t, ok && xerr.Expr == "count('x')", "expected a runtime XPathError, got ", err)
+	_, err = doc.EvaluateXPath("'x' | //p")
+	assert(t, err != nil, "expected an error for a union of strings")
+}